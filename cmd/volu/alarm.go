@@ -0,0 +1,269 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/riclib/volu/internal/alarm"
+	"github.com/riclib/volu/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// Alarm commands
+
+var (
+	alarmPlay         string
+	alarmVolume       int
+	alarmRamp         time.Duration
+	alarmSkipHolidays bool
+	alarmName         string
+	alarmJSON         bool
+)
+
+var alarmCmd = &cobra.Command{
+	Use:   "alarm",
+	Short: "Schedule playback",
+	Long: `Schedule playback at set times. Alarms are stored in the config file and
+run by 'volu daemon', which must be running on a machine that stays on
+(the Volumio box itself works well).`,
+}
+
+var alarmAddCmd = &cobra.Command{
+	Use:   "add <when>",
+	Short: "Add an alarm",
+	Long: `Add an alarm. <when> is a time with optional days, or a date and time
+for a one-shot:
+
+  "07:00"                 every day
+  "07:00 mon-fri"         Monday to Friday (also: weekdays, weekends, sat,sun)
+  "2026-12-24 09:00"      once
+  "tomorrow 07:00"        once, also "today 18:00" and "07:00 once"
+
+--play takes playlist:<name>, preset:<n> (a web radio preset), a stream URL
+or <uri>|<service>.
+
+Example: volu alarm add "07:00 mon-fri" --play playlist:Morning --volume 15 --ramp 10m
+         volu alarm add "08:30 weekdays" --play preset:1 --skip-holidays
+         volu alarm add "tomorrow 06:00" --play http://stream.example.com/office`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		when, err := alarm.Resolve(args[0], time.Now())
+		if err != nil {
+			return err
+		}
+		if _, err := alarm.ParseTarget(alarmPlay, cfg.WebRadio.Presets); err != nil {
+			return err
+		}
+		if alarmVolume < 0 || alarmVolume > 100 {
+			return fmt.Errorf("volume must be between 0 and 100 (got: %d)", alarmVolume)
+		}
+
+		a := config.Alarm{
+			Name:         alarmName,
+			When:         when,
+			Play:         alarmPlay,
+			Volume:       alarmVolume,
+			Ramp:         alarmRamp,
+			SkipHolidays: alarmSkipHolidays,
+		}
+		cfg.Schedule.Alarms = append(cfg.Schedule.Alarms, a)
+		if err := saveAlarms(); err != nil {
+			return err
+		}
+
+		fmt.Printf("Added alarm %d: %s\n", len(cfg.Schedule.Alarms), describeAlarm(a))
+		if next, ok := alarm.NextFire(a, holidays(), time.Now()); ok {
+			fmt.Printf("Next: %s\n", next.Format("Mon 2 Jan 15:04"))
+		}
+		return nil
+	},
+}
+
+var alarmLsCmd = &cobra.Command{
+	Use:     "ls",
+	Aliases: []string{"list"},
+	Short:   "List alarms with their next time",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if alarmJSON {
+			return json.NewEncoder(os.Stdout).Encode(cfg.Schedule.Alarms)
+		}
+		if len(cfg.Schedule.Alarms) == 0 {
+			fmt.Println("No alarms yet, add one with 'volu alarm add <when> --play <what>'")
+			return nil
+		}
+
+		fired := firedAlarms()
+		now := time.Now()
+		for i, a := range cfg.Schedule.Alarms {
+			next := "disabled"
+			if !a.Disabled {
+				next = "done"
+				if t, ok := alarm.NextFire(a, holidays(), now); ok {
+					next = t.Format("Mon 2 Jan 15:04")
+				} else if at, ok := fired[alarm.FiredKey(a)]; ok {
+					next = "fired " + at.Format("Mon 2 Jan 15:04")
+				}
+			}
+			fmt.Printf("%2d. %s\n    next: %s\n", i+1, describeAlarm(a), next)
+		}
+		return nil
+	},
+}
+
+var alarmRmCmd = &cobra.Command{
+	Use:   "rm <n>",
+	Short: "Remove alarm number n",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		n, err := alarmNumber(args[0])
+		if err != nil {
+			return err
+		}
+		a := cfg.Schedule.Alarms[n-1]
+		cfg.Schedule.Alarms = append(cfg.Schedule.Alarms[:n-1], cfg.Schedule.Alarms[n:]...)
+		if err := saveAlarms(); err != nil {
+			return err
+		}
+		fmt.Printf("Removed alarm %d: %s\n", n, describeAlarm(a))
+		return nil
+	},
+}
+
+var alarmEnableCmd = &cobra.Command{
+	Use:   "enable <n>",
+	Short: "Enable alarm number n",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setAlarmDisabled(args[0], false)
+	},
+}
+
+var alarmDisableCmd = &cobra.Command{
+	Use:   "disable <n>",
+	Short: "Disable alarm number n without removing it",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setAlarmDisabled(args[0], true)
+	},
+}
+
+func init() {
+	alarmAddCmd.Flags().StringVar(&alarmPlay, "play", "", "What to play: playlist:<name>, preset:<n>, a stream URL or <uri>|<service>")
+	alarmAddCmd.Flags().IntVar(&alarmVolume, "volume", 0, "Volume to play at (default: unchanged)")
+	alarmAddCmd.Flags().DurationVar(&alarmRamp, "ramp", 0, "Fade in from silence over this long")
+	alarmAddCmd.Flags().BoolVar(&alarmSkipHolidays, "skip-holidays", false, "Don't fire on the days in schedule.holidays")
+	alarmAddCmd.Flags().StringVar(&alarmName, "name", "", "Label shown in lists and notifications")
+	alarmAddCmd.MarkFlagRequired("play")
+	alarmLsCmd.Flags().BoolVar(&alarmJSON, "json", false, "Output alarms as JSON")
+
+	alarmCmd.AddCommand(alarmAddCmd, alarmLsCmd, alarmRmCmd, alarmEnableCmd, alarmDisableCmd)
+}
+
+// alarmNumber parses a 1-based alarm number.
+func alarmNumber(arg string) (int, error) {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 || n > len(cfg.Schedule.Alarms) {
+		return 0, fmt.Errorf("no alarm %s (%d alarms, see 'volu alarm ls')", arg, len(cfg.Schedule.Alarms))
+	}
+	return n, nil
+}
+
+// setAlarmDisabled enables or disables an alarm and saves the config.
+func setAlarmDisabled(arg string, disabled bool) error {
+	n, err := alarmNumber(arg)
+	if err != nil {
+		return err
+	}
+	cfg.Schedule.Alarms[n-1].Disabled = disabled
+	if err := saveAlarms(); err != nil {
+		return err
+	}
+	state := "Enabled"
+	if disabled {
+		state = "Disabled"
+	}
+	fmt.Printf("%s alarm %d: %s\n", state, n, describeAlarm(cfg.Schedule.Alarms[n-1]))
+	return nil
+}
+
+// saveAlarms saves the config, dropping the one-shot alarms the daemon
+// has fired since they were added.
+func saveAlarms() error {
+	log, err := alarm.DefaultFiredLog()
+	if err != nil {
+		return err
+	}
+	fired := firedAlarms()
+	var keep []config.Alarm
+	now := time.Now()
+	for _, a := range cfg.Schedule.Alarms {
+		if _, ok := fired[alarm.FiredKey(a)]; ok {
+			if _, pending := alarm.NextFire(a, nil, now); !pending {
+				continue
+			}
+		}
+		keep = append(keep, a)
+	}
+	cfg.Schedule.Alarms = keep
+
+	if err := saveConfig(); err != nil {
+		return err
+	}
+	if err := log.Forget(cfg.Schedule.Alarms); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	return nil
+}
+
+// firedAlarms returns when the daemon fired one-shot alarms, or none if
+// its log can't be read.
+func firedAlarms() map[string]time.Time {
+	log, err := alarm.DefaultFiredLog()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return nil
+	}
+	fired, err := log.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	return fired
+}
+
+// describeAlarm formats an alarm as e.g.
+// "Wake up: 07:00 mon-fri → playlist:Morning (volume 15, ramp 10m)".
+func describeAlarm(a config.Alarm) string {
+	s := a.When + " → " + a.Play
+	if a.Name != "" {
+		s = a.Name + ": " + s
+	}
+
+	var opts []string
+	if a.Volume > 0 {
+		opts = append(opts, fmt.Sprintf("volume %d", a.Volume))
+	}
+	if a.Ramp > 0 {
+		opts = append(opts, "ramp "+formatRemaining(a.Ramp))
+	}
+	if a.SkipHolidays {
+		opts = append(opts, "skips holidays")
+	}
+	if len(opts) > 0 {
+		s += " (" + strings.Join(opts, ", ") + ")"
+	}
+	return s
+}
+
+// holidays returns the configured holidays, or none if the list is invalid.
+func holidays() alarm.Holidays {
+	h, err := alarm.ParseHolidays(cfg.Schedule.Holidays)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return nil
+	}
+	return h
+}
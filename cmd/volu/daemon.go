@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/riclib/volu/internal/alarm"
+	"github.com/riclib/volu/internal/config"
+	"github.com/riclib/volu/internal/notifications"
+	"github.com/spf13/cobra"
+)
+
+// Daemon command
+
+// daemonPoll is the longest the daemon sleeps before re-reading the
+// config, so alarms added or removed while it runs take effect.
+const daemonPoll = time.Minute
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Run scheduled alarms in the foreground",
+	Long: `Run the alarms from 'volu alarm' until interrupted. The config file is
+re-read every minute, so alarms and volume, connection and notification
+settings can be changed without restarting the daemon. The daemon never writes the config: fired one-shot
+alarms are logged in $XDG_STATE_HOME/volu and dropped from the config the
+next time 'volu alarm' changes it.
+
+Run it as a systemd user service on a machine that stays on, e.g.:
+
+  [Service]
+  ExecStart=%h/go/bin/volu daemon
+  Restart=on-failure`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+		defer stop()
+
+		daemonLog("Running %d alarms for %s", len(cfg.Schedule.Alarms), volumioHost)
+		var running sync.WaitGroup
+		defer running.Wait()
+
+		last := time.Now()
+		for {
+			wait := daemonPoll
+			if next, ok := nextAlarm(last); ok && time.Until(next) < wait {
+				wait = time.Until(next)
+			}
+			select {
+			case <-ctx.Done():
+				daemonLog("Stopping")
+				return nil
+			case <-time.After(wait):
+			}
+
+			reloadConfig()
+			now := time.Now()
+			due := alarm.Due(cfg.Schedule.Alarms, holidays(), last, now)
+			// Alarms get their own client and notifier, as the next
+			// reload may replace the global ones while they ramp
+			player, n := client, getNotifier()
+			for _, i := range due {
+				a := cfg.Schedule.Alarms[i]
+				target, err := alarm.ParseTarget(a.Play, cfg.WebRadio.Presets)
+				if err != nil {
+					alarmFailed(n, a, err)
+					continue
+				}
+				running.Add(1)
+				go func() {
+					defer running.Done()
+					fireAlarm(ctx, player, n, a, target)
+				}()
+			}
+			recordFiredOneShots(due, now)
+			last = now
+		}
+	},
+}
+
+// nextAlarm returns when the next enabled alarm fires after after.
+func nextAlarm(after time.Time) (time.Time, bool) {
+	var next time.Time
+	h := holidays()
+	for _, a := range cfg.Schedule.Alarms {
+		if t, ok := alarm.NextFire(a, h, after); ok && (next.IsZero() || t.Before(next)) {
+			next = t
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// fireAlarm plays an alarm's target on p, ramping the volume if set,
+// and notifies through n. It reads no globals, so it can run while the
+// config is reloaded.
+func fireAlarm(ctx context.Context, p alarm.Player, n notifications.Notifier, a config.Alarm, target alarm.Target) {
+	daemonLog("Alarm %s: playing %s", alarmLabel(a), target.Label)
+	notifyWith(n, "Volumio Alarm", alarmLabel(a)+": playing "+target.Label, "alarm-clock", false)
+	if err := alarm.Fire(ctx, p, a, target); err != nil && ctx.Err() == nil {
+		alarmFailed(n, a, err)
+	}
+}
+
+// alarmFailed logs and notifies a failed alarm.
+func alarmFailed(n notifications.Notifier, a config.Alarm, err error) {
+	daemonLog("Alarm %s failed: %v", alarmLabel(a), err)
+	notifyWith(n, "Volumio Alarm", alarmLabel(a)+" failed: "+err.Error(), "error", true)
+}
+
+// alarmLabel returns the alarm's name, or its schedule if unnamed.
+func alarmLabel(a config.Alarm) string {
+	if a.Name != "" {
+		return a.Name
+	}
+	return a.When
+}
+
+// recordFiredOneShots logs the one-shot alarms among the fired indices,
+// leaving the config file to the alarm commands.
+func recordFiredOneShots(fired []int, at time.Time) {
+	log, err := alarm.DefaultFiredLog()
+	if err != nil {
+		daemonLog("Could not record one-shot alarms: %v", err)
+		return
+	}
+	for _, i := range fired {
+		a := cfg.Schedule.Alarms[i]
+		if s, err := alarm.Parse(a.When); err != nil || !s.OneShot() {
+			continue
+		}
+		if err := log.Add(a, at); err != nil {
+			daemonLog("Could not record one-shot alarm %s: %v", alarmLabel(a), err)
+		}
+	}
+}
+
+// reloadConfig re-reads the config file, keeping the current one if it
+// cannot be loaded. When it changed, the client and notifier are rebuilt
+// from it; alarms already firing keep the ones they started with.
+func reloadConfig() {
+	loaded, err := config.Load()
+	if err != nil {
+		daemonLog("Keeping previous config: %v", err)
+		return
+	}
+	if reflect.DeepEqual(loaded, cfg) {
+		return
+	}
+	cfg = loaded
+	client = newClient()
+	notifier = nil
+}
+
+// daemonLog writes a timestamped line to stderr.
+func daemonLog(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "%s %s\n", time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/riclib/volu/internal/config"
+)
+
+func TestReloadConfigRebuildsClient(t *testing.T) {
+	useFake(t)
+	if err := saveConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloadConfig()
+	unchanged := client
+	reloadConfig()
+	if client != unchanged {
+		t.Error("client rebuilt although the config did not change")
+	}
+
+	// Edit the file behind the daemon's back
+	edited := *cfg
+	edited.Volume.MaxVolume = 40
+	if err := config.Save(&edited); err != nil {
+		t.Fatal(err)
+	}
+	reloadConfig()
+	if cfg.Volume.MaxVolume != 40 {
+		t.Errorf("max volume after reload = %d, want 40", cfg.Volume.MaxVolume)
+	}
+	if client == unchanged {
+		t.Error("client not rebuilt after the config changed")
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/riclib/volu/internal/volumiofake"
+	"github.com/spf13/cobra"
+)
+
+// Development commands
+
+var (
+	fakeServerListen  string
+	fakeServerLibrary string
+	fakeServerPrint   bool
+)
+
+var devCmd = &cobra.Command{
+	Use:   "dev",
+	Short: "Tools for developing volu",
+}
+
+var devFakeServerCmd = &cobra.Command{
+	Use:   "fake-server",
+	Short: "Run a fake Volumio to try volu without a player",
+	Long: `Run a stateful fake of the Volumio REST API with a small music library,
+web radio stations and playlists. It keeps a queue and a playback clock,
+so tracks advance in real time and every command changes what getState
+returns.
+
+Volumio serves on port 3000, so with the default address any volu
+command can talk to the fake by host:
+
+  volu dev fake-server &
+  volu -H 127.0.0.1 radio asot 3
+  volu -H 127.0.0.1 status
+
+Use --library with a JSON fixture to serve your own library;
+--print-library prints the built-in one as a starting point.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		lib := volumiofake.DefaultLibrary()
+		if fakeServerLibrary != "" {
+			var err error
+			if lib, err = volumiofake.LoadLibrary(fakeServerLibrary); err != nil {
+				return err
+			}
+		}
+		if fakeServerPrint {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(lib)
+		}
+
+		fake := volumiofake.New(lib)
+		server := &http.Server{Addr: fakeServerListen, Handler: fake, ReadHeaderTimeout: 10 * time.Second}
+
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+		defer stop()
+		go fake.RunClock(ctx)
+		go func() {
+			<-ctx.Done()
+			shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			server.Shutdown(shutdown)
+		}()
+
+		daemonLog("Serving a fake Volumio with %d albums on %s", len(lib.Albums), fakeServerListen)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("failed to serve fake Volumio: %w", err)
+		}
+		daemonLog("Stopping")
+		return nil
+	},
+}
+
+func init() {
+	devFakeServerCmd.Flags().StringVar(&fakeServerListen, "listen", "127.0.0.1:3000", "Address to serve the fake Volumio on")
+	devFakeServerCmd.Flags().StringVar(&fakeServerLibrary, "library", "", "JSON library fixture to serve instead of the built-in one")
+	devFakeServerCmd.Flags().BoolVar(&fakeServerPrint, "print-library", false, "Print the library as JSON and exit")
+	devCmd.AddCommand(devFakeServerCmd)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/riclib/volu/internal/exporter"
+	"github.com/spf13/cobra"
+)
+
+// Exporter command
+
+var exporterListen string
+
+var exporterCmd = &cobra.Command{
+	Use:   "exporter",
+	Short: "Serve Volumio metrics for Prometheus",
+	Long: `Serve Prometheus metrics for the Volumio player at /metrics: volume,
+mute, playback status, position and duration, queue length, and the
+latency and errors of Volumio API requests by endpoint. The player is
+queried on every scrape, and volumio_up is 0 while it doesn't answer.
+
+Example scrape config:
+
+  scrape_configs:
+    - job_name: volumio
+      static_configs:
+        - targets: ["localhost:9797"]`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		source := newClient()
+		exp := exporter.New(source, volumioHost)
+		source.SetObserver(exp.Observe)
+
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", exp)
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/" {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprintf(w, "<html><body><h1>volu exporter</h1><p>Volumio %s: <a href=\"/metrics\">metrics</a></p></body></html>\n", volumioHost)
+		})
+		server := &http.Server{Addr: exporterListen, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+		defer stop()
+		go func() {
+			<-ctx.Done()
+			shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			server.Shutdown(shutdown)
+		}()
+
+		daemonLog("Serving metrics for %s on %s", volumioHost, exporterListen)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("failed to serve metrics: %w", err)
+		}
+		daemonLog("Stopping")
+		return nil
+	},
+}
+
+func init() {
+	exporterCmd.Flags().StringVar(&exporterListen, "listen", ":9797", "Address to serve metrics on")
+}
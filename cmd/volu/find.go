@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/riclib/volu/internal/volumio"
+	"github.com/spf13/cobra"
+)
+
+// Find command
+
+var (
+	findLimit int
+	findJSON  bool
+	findPlay  int
+	findQueue int
+)
+
+var findCmd = &cobra.Command{
+	Use:   "find <query>",
+	Short: "Fuzzy search the local library index",
+	Long: `Search albums, artists and tracks in the local library index without
+contacting Volumio. Matching tolerates typos and partial words and ranks
+titles above artists above albums.
+
+Requires 'volu library sync' to have been run.
+
+Example: volu find daft punk
+         volu find asot 1090 --play 1
+         volu find discovery --json`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		idx := loadLibrary()
+		if idx == nil {
+			return fmt.Errorf("library not indexed yet, run 'volu library sync'")
+		}
+
+		query := strings.Join(args, " ")
+		results := idx.Find(query, findLimit)
+
+		items := make([]volumio.BrowseItem, len(results))
+		for i, result := range results {
+			items[i] = result.Item()
+		}
+
+		if findPlay > 0 || findQueue > 0 {
+			n, play := findQueue, false
+			if findPlay > 0 {
+				n, play = findPlay, true
+			}
+			if n > len(items) {
+				return fmt.Errorf("result %d out of range (%d results for %q)", n, len(items), query)
+			}
+			item := items[n-1]
+			if play {
+				if err := client.ReplaceAndPlay(item.URI, item.Service); err != nil {
+					notify("Volumio Error", "Could not play "+item.DisplayName(), "error", true)
+					return err
+				}
+				notify("Volumio", "Playing "+item.DisplayName(), "media-playback-start", false)
+				return nil
+			}
+			if err := client.AddToQueue(item.URI, item.Service); err != nil {
+				notify("Volumio Error", "Could not queue "+item.DisplayName(), "error", true)
+				return err
+			}
+			notify("Volumio", "Queued "+item.DisplayName(), "list-add", false)
+			return nil
+		}
+
+		if findJSON {
+			return json.NewEncoder(os.Stdout).Encode(items)
+		}
+
+		if len(results) == 0 {
+			fmt.Printf("No matches for %q\n", query)
+			return nil
+		}
+
+		for i, result := range results {
+			line := fmt.Sprintf("%2d. [%s] %s", i+1, result.Entry.Kind, result.Entry.Title)
+			if result.Entry.Artist != "" && result.Entry.Kind != "artist" {
+				line += " - " + result.Entry.Artist
+			}
+			fmt.Println(line)
+		}
+		return nil
+	},
+}
+
+func init() {
+	findCmd.Flags().IntVarP(&findLimit, "limit", "n", 20, "Maximum number of results (0 for all)")
+	findCmd.Flags().BoolVar(&findJSON, "json", false, "Print results as JSON browse items")
+	findCmd.Flags().IntVar(&findPlay, "play", 0, "Replace the queue with result N and play it")
+	findCmd.Flags().IntVar(&findQueue, "queue", 0, "Add result N to the queue")
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/riclib/volu/internal/history"
+	"github.com/spf13/cobra"
+)
+
+// History commands
+
+var (
+	historySince    string
+	historyLimit    int
+	historyInterval time.Duration
+	statsTop        string
+	statsPeriod     string
+	statsLimit      int
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show recently played tracks",
+	Long: `Show the tracks recorded by 'volu history record', most recent last.
+
+Examples:
+  volu history               # the last 7 days
+  volu history --since 24h
+  volu history --since 4w --limit 100`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		age, err := history.ParseAge(historySince)
+		if err != nil {
+			return err
+		}
+		log, err := history.DefaultLog(volumioHost)
+		if err != nil {
+			return err
+		}
+		entries, err := log.Read(time.Now().Add(-age))
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			fmt.Printf("Nothing played since %s\n", time.Now().Add(-age).Format("2006-01-02 15:04"))
+			return nil
+		}
+
+		if historyLimit > 0 && len(entries) > historyLimit {
+			entries = entries[len(entries)-historyLimit:]
+		}
+		for _, e := range entries {
+			track := e.Title
+			if e.Artist != "" {
+				track = e.Artist + " - " + e.Title
+			}
+			if e.Album != "" {
+				track += " (" + e.Album + ")"
+			}
+			fmt.Printf("%s  %s  %d:%02d\n", e.Time.Local().Format("2006-01-02 15:04"), track, e.Listened/60, e.Listened%60)
+		}
+		return nil
+	},
+}
+
+var historyRecordCmd = &cobra.Command{
+	Use:   "record",
+	Short: "Record played tracks in the foreground",
+	Long: `Watch Volumio and add every track played for at least 10 seconds to the
+history in ~/.local/share/volu/history-<host>.jsonl, with the time it
+started and how long it actually played.
+
+Run it as a systemd user service, e.g.:
+
+  [Service]
+  ExecStart=%h/go/bin/volu history record
+  Restart=on-failure`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		log, err := history.DefaultLog(volumioHost)
+		if err != nil {
+			return err
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+		defer stop()
+
+		ticker := time.NewTicker(historyInterval)
+		defer ticker.Stop()
+
+		record := func(e *history.Entry) {
+			if e == nil {
+				return
+			}
+			if err := log.Append(*e); err != nil {
+				daemonLog("Could not record %s: %v", e.Title, err)
+			}
+		}
+
+		daemonLog("Recording %s to %s", volumioHost, log.Path)
+		var recorder history.Recorder
+		for {
+			select {
+			case <-ctx.Done():
+				record(recorder.Flush(time.Now()))
+				daemonLog("Stopping")
+				return nil
+			case <-ticker.C:
+			}
+
+			state, err := client.GetState()
+			if err != nil {
+				// Count nothing as played while Volumio is unreachable,
+				// but keep the track so a blip doesn't split the play
+				recorder.Pause(time.Now())
+				continue
+			}
+			record(recorder.Update(state, time.Now()))
+		}
+	},
+}
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show the most played artists, albums or tracks",
+	Long: `Show the most played artists, albums or tracks from the history
+recorded by 'volu history record'.
+
+Examples:
+  volu stats                              # top artists this month
+  volu stats --top albums --period year
+  volu stats --top tracks --period week --limit 20`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		since, err := history.PeriodStart(statsPeriod, time.Now())
+		if err != nil {
+			return err
+		}
+		log, err := history.DefaultLog(volumioHost)
+		if err != nil {
+			return err
+		}
+		entries, err := log.Read(since)
+		if err != nil {
+			return err
+		}
+		top, err := history.Top(entries, statsTop, statsLimit)
+		if err != nil {
+			return err
+		}
+		if len(top) == 0 {
+			fmt.Println("Nothing played in this period")
+			return nil
+		}
+
+		for i, c := range top {
+			fmt.Printf("%3d. %s  (%d plays, %s)\n", i+1, c.Name, c.Plays, formatListened(c.Listened))
+		}
+		return nil
+	},
+}
+
+// formatListened formats a listening time as "1h05m" or "12m".
+func formatListened(d time.Duration) string {
+	d = d.Round(time.Minute)
+	if d >= time.Hour {
+		return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return fmt.Sprintf("%dm", int(d.Minutes()))
+}
+
+// historyPlays returns how often each album was played, or nil if there
+// is no history.
+func historyPlays() map[string]int {
+	log, err := history.DefaultLog(volumioHost)
+	if err != nil {
+		return nil
+	}
+	entries, err := log.Read(time.Time{})
+	if err != nil || len(entries) == 0 {
+		return nil
+	}
+	return history.AlbumPlays(entries)
+}
+
+func init() {
+	historyCmd.Flags().StringVar(&historySince, "since", "7d", "How far back to go (e.g. 24h, 7d, 4w)")
+	historyCmd.Flags().IntVar(&historyLimit, "limit", 0, "Show at most this many of the most recent tracks")
+	historyRecordCmd.Flags().DurationVar(&historyInterval, "interval", 5*time.Second, "How often to check the player state")
+	historyCmd.AddCommand(historyRecordCmd)
+
+	statsCmd.Flags().StringVar(&statsTop, "top", "artists", "What to rank: artists, albums or tracks")
+	statsCmd.Flags().StringVar(&statsPeriod, "period", "month", "day, week, month, year or all")
+	statsCmd.Flags().IntVar(&statsLimit, "limit", 10, "How many to show")
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/riclib/volu/internal/config"
+	"github.com/riclib/volu/internal/hooks"
+	"github.com/riclib/volu/internal/volumio"
+	"github.com/spf13/cobra"
+)
+
+// Hooks commands
+
+// hookBacklog is how many events may wait for earlier hooks to finish
+// before new ones are dropped.
+const hookBacklog = 64
+
+var hooksInterval time.Duration
+
+var hooksCmd = &cobra.Command{
+	Use:   "hooks",
+	Short: "Run commands and webhooks on player events",
+	Long: `Run the commands and webhooks set in the hooks section of the config
+when something happens on Volumio:
+
+  track_change, play, pause, stop, volume_change, queue_end,
+  disconnect, reconnect
+
+Commands run with sh -c and get the player state as VOLU_* environment
+variables (VOLU_EVENT, VOLU_TITLE, VOLU_ARTIST, VOLU_VOLUME, ...) and as
+JSON on stdin. Webhooks get the same JSON, or a body rendered from their
+template.`,
+}
+
+var hooksRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Watch Volumio and run hooks in the foreground",
+	Long: `Watch Volumio and run hooks until interrupted. Hooks run one at a time,
+in the order the events happened.
+
+Run it as a systemd user service, e.g.:
+
+  [Service]
+  ExecStart=%h/go/bin/volu hooks run
+  Restart=on-failure`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkHooks(); err != nil {
+			return err
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+		defer stop()
+
+		events := make(chan hooks.Payload, hookBacklog)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for p := range events {
+				runHooks(ctx, p)
+			}
+		}()
+		defer func() {
+			close(events)
+			<-done
+		}()
+
+		detector := &hooks.Detector{QueueLength: func() (int, error) {
+			queue, err := client.GetQueue()
+			return len(queue), err
+		}}
+		ticker := time.NewTicker(hooksInterval)
+		defer ticker.Stop()
+
+		daemonLog("Running hooks for %s", volumioHost)
+		var prev *volumio.PlayerState
+		for {
+			select {
+			case <-ctx.Done():
+				daemonLog("Stopping")
+				return nil
+			case <-ticker.C:
+			}
+
+			state, err := client.GetState()
+			p := hooks.Payload{Host: volumioHost, Time: time.Now(), State: state, Previous: prev}
+			for _, event := range detector.Update(state, err) {
+				if len(cfg.Hooks[event]) == 0 {
+					continue
+				}
+				p.Event = event
+				select {
+				case events <- p:
+				default:
+					daemonLog("Hooks are falling behind, skipping %s", event)
+				}
+			}
+			if err == nil {
+				prev = state
+			}
+		}
+	},
+}
+
+// runHooks runs the hooks for p's event one after another.
+func runHooks(ctx context.Context, p hooks.Payload) {
+	for _, hook := range cfg.Hooks[p.Event] {
+		if err := hooks.Run(ctx, hook, p); err != nil && ctx.Err() == nil {
+			daemonLog("Hook for %s: %v", p.Event, err)
+		}
+	}
+}
+
+// checkHooks rejects unknown events and hooks with nothing to run.
+func checkHooks() error {
+	for event, list := range cfg.Hooks {
+		if !hooks.Valid(event) {
+			return fmt.Errorf("unknown hook event %q (use one of %v)", event, hooks.Events)
+		}
+		for _, hook := range list {
+			if hook.Command == "" && hook.Webhook == "" {
+				return fmt.Errorf("hook for %s needs a command or a webhook", event)
+			}
+		}
+	}
+	return nil
+}
+
+var hooksLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List configured hooks",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(cfg.Hooks) == 0 {
+			fmt.Println("No hooks configured")
+			return nil
+		}
+		events := make([]string, 0, len(cfg.Hooks))
+		for event := range cfg.Hooks {
+			events = append(events, event)
+		}
+		sort.Strings(events)
+		for _, event := range events {
+			fmt.Printf("%s:\n", event)
+			for _, hook := range cfg.Hooks[event] {
+				fmt.Printf("  %s\n", describeHook(hook))
+			}
+		}
+		return checkHooks()
+	},
+}
+
+var hooksTestCmd = &cobra.Command{
+	Use:   "test <event>",
+	Short: "Run an event's hooks now with the current player state",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		event := args[0]
+		if !hooks.Valid(event) {
+			return fmt.Errorf("unknown hook event %q (use one of %v)", event, hooks.Events)
+		}
+		if len(cfg.Hooks[event]) == 0 {
+			return fmt.Errorf("no hooks configured for %s", event)
+		}
+		state, err := client.GetState()
+		if err != nil && event != hooks.Disconnect {
+			return fmt.Errorf("failed to get player state: %w", err)
+		}
+
+		p := hooks.Payload{Event: event, Host: volumioHost, Time: time.Now(), State: state}
+		failed := 0
+		for _, hook := range cfg.Hooks[event] {
+			if err := hooks.Run(context.Background(), hook, p); err != nil {
+				fmt.Printf("✗ %s: %v\n", describeHook(hook), err)
+				failed++
+				continue
+			}
+			fmt.Printf("✓ %s\n", describeHook(hook))
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d hooks failed", failed, len(cfg.Hooks[event]))
+		}
+		return nil
+	},
+}
+
+// describeHook returns the command or webhook a hook runs.
+func describeHook(hook config.Hook) string {
+	if hook.Command != "" {
+		return hook.Command
+	}
+	return "POST " + hook.Webhook
+}
+
+func init() {
+	hooksRunCmd.Flags().DurationVar(&hooksInterval, "interval", 2*time.Second, "How often to check the player state")
+	hooksCmd.AddCommand(hooksRunCmd, hooksLsCmd, hooksTestCmd)
+}
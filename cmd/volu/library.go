@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/riclib/volu/internal/library"
+	"github.com/spf13/cobra"
+)
+
+// Library commands
+
+var (
+	libraryFull   bool
+	libraryMaxAge time.Duration
+)
+
+var libraryCmd = &cobra.Command{
+	Use:   "library",
+	Short: "Manage the local library index",
+	Long: `Maintain a local copy of the Volumio music library so searches and radio
+selection run offline and see the full catalogue.
+
+The index is stored in ~/.cache/volu/library-<host>.json.`,
+}
+
+var librarySyncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Build or refresh the local library index",
+	Long: `Walk the Volumio library and update the local index.
+
+By default the sync is incremental: folders containing subfolders are
+browsed to find new artists and albums, while album folders indexed less
+than --max-age ago (7 days by default) are reused. Volumio doesn't report
+when a folder changed, so tracks added to or retagged in a reused album
+show up once it is older than --max-age. Use --full to re-browse
+everything, or --max-age 0 to never refresh known albums.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := library.DefaultPath(volumioHost)
+		if err != nil {
+			return err
+		}
+
+		idx, err := library.Load(path)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				fmt.Fprintf(os.Stderr, "Warning: %v, rebuilding index\n", err)
+			}
+			idx = library.New(volumioHost)
+		}
+		idx.Host = volumioHost
+
+		start := time.Now()
+		stats, err := idx.Sync(client, library.SyncOptions{
+			Roots:  cfg.Library.Roots,
+			Full:   libraryFull,
+			MaxAge: libraryMaxAge,
+			Progress: func(uri string) {
+				fmt.Fprintf(os.Stderr, "\rBrowsing %-70.70s", uri)
+			},
+		})
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return fmt.Errorf("library sync failed: %w", err)
+		}
+
+		if err := idx.Save(path); err != nil {
+			return err
+		}
+
+		fmt.Printf("Indexed %d albums, %d artists, %d tracks in %s\n",
+			len(idx.Albums()), len(idx.Artists()), len(idx.Tracks()),
+			time.Since(start).Round(time.Millisecond))
+		fmt.Printf("Browsed %d folders, reused %d, removed %d\n",
+			stats.Browsed, stats.Reused, stats.Removed)
+		return nil
+	},
+}
+
+var libraryStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show local library index information",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := library.DefaultPath(volumioHost)
+		if err != nil {
+			return err
+		}
+
+		idx, err := library.Load(path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("library not indexed yet, run 'volu library sync'")
+			}
+			return err
+		}
+
+		fmt.Printf("Index: %s\n", path)
+		fmt.Printf("Host: %s\n", idx.Host)
+		fmt.Printf("Roots: %v\n", idx.Roots)
+		fmt.Printf("Synced: %s (%s ago)\n",
+			idx.SyncedAt.Format(time.RFC1123), time.Since(idx.SyncedAt).Round(time.Second))
+		fmt.Printf("Folders: %d\n", len(idx.Folders))
+		fmt.Printf("Albums: %d\n", len(idx.Albums()))
+		fmt.Printf("Artists: %d\n", len(idx.Artists()))
+		fmt.Printf("Tracks: %d\n", len(idx.Tracks()))
+		return nil
+	},
+}
+
+func init() {
+	librarySyncCmd.Flags().BoolVar(&libraryFull, "full", false, "Re-browse every folder instead of an incremental refresh")
+	librarySyncCmd.Flags().DurationVar(&libraryMaxAge, "max-age", library.DefaultMaxAge, "Refresh album folders indexed longer ago than this (0: never)")
+
+	libraryCmd.AddCommand(librarySyncCmd)
+	libraryCmd.AddCommand(libraryStatusCmd)
+}
+
+// loadLibrary returns the local library index for the current host,
+// or nil if the library has not been synced.
+func loadLibrary() *library.Index {
+	path, err := library.DefaultPath(volumioHost)
+	if err != nil {
+		return nil
+	}
+	idx, err := library.Load(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintf(os.Stderr, "Warning: Could not load library index: %v\n", err)
+		}
+		return nil
+	}
+	return idx
+}
@@ -1,14 +1,20 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"os"
-	"os/exec"
+	"os/signal"
+	"regexp"
 	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/riclib/volu/internal/config"
 	"github.com/riclib/volu/internal/elephant"
+	"github.com/riclib/volu/internal/launcher"
 	"github.com/riclib/volu/internal/radio"
 	"github.com/riclib/volu/internal/volumio"
 	"github.com/riclib/volu/internal/walker"
@@ -17,9 +23,12 @@ import (
 )
 
 var (
-	volumioHost string
-	client      *volumio.Client
-	cfg         *config.Config
+	volumioHost    string
+	recordCassette string
+	debugRequests  bool
+	client         volumio.Player
+	cfg            *config.Config
+	cfgLoaded      bool // false when the config file exists but failed to load
 )
 
 func main() {
@@ -35,6 +44,7 @@ func main() {
 				fmt.Fprintf(os.Stderr, "Warning: Could not load config: %v\n", err)
 				cfg = config.DefaultConfig()
 			}
+			cfgLoaded = err == nil
 
 			// Priority: flag → config file → env var → default
 			if volumioHost == "" {
@@ -46,7 +56,7 @@ func main() {
 					}
 				}
 			}
-			client = volumio.NewClientWithHost(volumioHost)
+			client = newClient()
 		},
 		// Allow direct radio series invocation: volu <series> [count]
 		Args: cobra.MaximumNArgs(2),
@@ -77,6 +87,8 @@ func main() {
 	}
 
 	rootCmd.PersistentFlags().StringVarP(&volumioHost, "host", "H", "", "Volumio host (default: $VOLUMIO_HOST or volumio.local)")
+	rootCmd.PersistentFlags().StringVar(&recordCassette, "record", "", "Record Volumio API exchanges to a cassette file for tests")
+	rootCmd.PersistentFlags().BoolVar(&debugRequests, "debug", false, "Log Volumio API requests to stderr")
 
 	// Playback commands
 	rootCmd.AddCommand(playCmd)
@@ -88,6 +100,7 @@ func main() {
 	rootCmd.AddCommand(skipCmd) // Alias for next
 
 	// Volume commands
+	volumeCmd.AddCommand(volumeFadeCmd)
 	rootCmd.AddCommand(volumeCmd)
 
 	// Status command
@@ -100,37 +113,164 @@ func main() {
 	// Radio command
 	rootCmd.AddCommand(radioCmd)
 
+	// Sleep timer
+	rootCmd.AddCommand(sleepCmd)
+
+	// Alarms
+	rootCmd.AddCommand(alarmCmd)
+	rootCmd.AddCommand(daemonCmd)
+
+	// Web radio commands
+	rootCmd.AddCommand(webradioCmd)
+
+	// Library index commands
+	rootCmd.AddCommand(libraryCmd)
+	rootCmd.AddCommand(findCmd)
+
 	// Waybar command
 	rootCmd.AddCommand(waybarCmd)
 
 	// Walker command
 	rootCmd.AddCommand(walkerCmd)
 
-	// Elephant provider (placeholder)
+	// Elephant provider
 	rootCmd.AddCommand(elephantCmd)
 
+	// Line-based launcher menus (rofi, dmenu, fuzzel, wofi, fzf)
+	rootCmd.AddCommand(menuCmd)
+
+	// Notifications
+	rootCmd.AddCommand(notifyTrackCmd)
+	rootCmd.AddCommand(notifydCmd)
+
+	// Scrobbling
+	rootCmd.AddCommand(scrobbleCmd)
+
+	// Listening history
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(statsCmd)
+
+	// Event hooks
+	rootCmd.AddCommand(hooksCmd)
+
+	// Prometheus exporter
+	rootCmd.AddCommand(exporterCmd)
+
+	// Development
+	rootCmd.AddCommand(devCmd)
+
+	rootCmd.SetArgs(volumeArgs(os.Args[1:]))
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 }
 
-// Helper function to send notifications
-func notify(title, message, icon string, urgent bool) {
-	args := []string{"-t", "2000", "-i", icon, title, message}
-	if urgent {
-		args = append([]string{"-u", "critical"}, args...)
+// volumeArgs lets "volu volume -5" through as a relative volume rather
+// than an unknown flag by inserting "--" before it
+func volumeArgs(args []string) []string {
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "-H" || arg == "--host" || arg == "--record":
+			i++ // skip the value
+		case strings.HasPrefix(arg, "-"):
+		case arg == "volume" && i+1 < len(args) && negativeVolume.MatchString(args[i+1]):
+			return append(append(append([]string{}, args[:i+1]...), "--"), args[i+1:]...)
+		default:
+			return args
+		}
+	}
+	return args
+}
+
+var negativeVolume = regexp.MustCompile(`^-\d+%?$`)
+
+// saveConfig writes cfg back to the config file. It refuses when the file
+// failed to load, since cfg then holds defaults and saving would replace
+// the user's settings.
+func saveConfig() error {
+	if !cfgLoaded {
+		path, _ := config.GetConfigPath()
+		return fmt.Errorf("not saving: %s failed to load, fix it first", path)
 	}
-	exec.Command("notify-send", args...).Run()
+	return config.Save(cfg)
+}
+
+// newClient creates a client for the selected host with the configured
+// volume limits, timeouts, retries, circuit breaker and fade memory
+func newClient() *volumio.Client {
+	limits := cfg.Volume.LimitsFor(volumioHost)
+	opts := []volumio.Option{
+		volumio.WithUserAgent("volu"),
+		volumio.WithVolumeLimits(limits.MinVolume, limits.MaxVolume),
+	}
+
+	conn := cfg.Connection
+	if conn.Timeout > 0 {
+		opts = append(opts, volumio.WithTimeout(conn.Timeout))
+	}
+	if conn.ConnectTimeout > 0 {
+		opts = append(opts, volumio.WithConnectTimeout(conn.ConnectTimeout))
+	}
+	if conn.Retries != 0 {
+		opts = append(opts, volumio.WithRetries(max(conn.Retries, 0), volumio.DefaultRetryBackoff))
+	}
+	opts = append(opts, volumio.WithFadeMemory(volumio.DefaultFadeMemory(volumioHost)))
+	if conn.BreakerThreshold >= 0 {
+		if breaker, err := volumio.DefaultBreaker(volumioHost); err == nil {
+			breaker.Threshold = conn.BreakerThreshold
+			breaker.Cooldown = conn.BreakerCooldown
+			opts = append(opts, volumio.WithBreaker(breaker))
+		}
+	}
+	if recordCassette != "" {
+		opts = append(opts, volumio.WithTransport(volumio.NewRecorder(recordCassette)))
+	}
+	if debugRequests {
+		opts = append(opts, volumio.WithLogger(daemonLog))
+	}
+	return volumio.NewClientWithHost(volumioHost, opts...)
+}
+
+// newRouter creates a launcher router with the configured presets
+func newRouter() *launcher.Router {
+	router := launcher.NewRouter(client)
+	router.Presets = launcherPresets()
+	router.Fade = transportFade()
+	if cfg != nil && cfg.Volume.Step > 0 {
+		router.VolumeStep = cfg.Volume.Step
+	}
+	return router
+}
+
+// transportFade returns the configured play and pause fades
+func transportFade() volumio.TransportFade {
+	if cfg == nil {
+		return volumio.TransportFade{}
+	}
+	curve, err := volumio.ParseCurve(cfg.Volume.Curve)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	return volumio.TransportFade{Out: cfg.Volume.FadeOnPause, In: cfg.Volume.FadeOnPlay, Curve: curve}
 }
 
 // Playback commands
 
+// runTransport runs a play, pause, toggle or stop command. SIGINT and
+// SIGTERM stop a fade early and restore the volume rather than leaving the
+// player silent.
+func runTransport(command string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	return newRouter().RunContext(ctx, launcher.Action{Kind: launcher.KindCommand, Value: command})
+}
+
 var playCmd = &cobra.Command{
 	Use:   "play",
 	Short: "Start playback",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if err := client.Play(); err != nil {
+		if err := runTransport("play"); err != nil {
 			notify("Volumio Error", "Could not start playback", "error", true)
 			return err
 		}
@@ -143,7 +283,7 @@ var pauseCmd = &cobra.Command{
 	Use:   "pause",
 	Short: "Pause playback",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if err := client.Pause(); err != nil {
+		if err := runTransport("pause"); err != nil {
 			notify("Volumio Error", "Could not pause playback", "error", true)
 			return err
 		}
@@ -156,7 +296,7 @@ var toggleCmd = &cobra.Command{
 	Use:   "toggle",
 	Short: "Toggle play/pause",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if err := client.TogglePlayPause(); err != nil {
+		if err := runTransport("toggle"); err != nil {
 			notify("Volumio Error", "Could not toggle playback", "error", true)
 			return err
 		}
@@ -164,15 +304,11 @@ var toggleCmd = &cobra.Command{
 		time.Sleep(300 * time.Millisecond)
 		state, err := client.GetState()
 		if err == nil {
-			status := "Playing"
 			if state.Status == "pause" {
-				status = "Paused"
-			}
-			trackInfo := state.Title
-			if state.Artist != "" {
-				trackInfo = state.Artist + " - " + state.Title
+				notifyTrack("Paused", "media-playback-pause")
+			} else {
+				notifyTrack("Playing", "media-playback-start")
 			}
-			notify("Volumio", fmt.Sprintf("%s\n%s", status, trackInfo), "media-playback-start", false)
 		}
 		return nil
 	},
@@ -182,7 +318,7 @@ var stopCmd = &cobra.Command{
 	Use:   "stop",
 	Short: "Stop playback",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if err := client.Stop(); err != nil {
+		if err := runTransport("stop"); err != nil {
 			notify("Volumio Error", "Could not stop playback", "error", true)
 			return err
 		}
@@ -203,11 +339,7 @@ var nextCmd = &cobra.Command{
 		time.Sleep(500 * time.Millisecond)
 		state, err := client.GetState()
 		if err == nil && state.Title != "" {
-			trackInfo := state.Title
-			if state.Artist != "" {
-				trackInfo = state.Artist + " - " + state.Title
-			}
-			notify("Volumio - Next Track", trackInfo, "media-skip-forward", false)
+			notifyTrack("Next Track", "media-skip-forward")
 		} else {
 			notify("Volumio", "Next track", "media-skip-forward", false)
 		}
@@ -227,11 +359,7 @@ var prevCmd = &cobra.Command{
 		time.Sleep(500 * time.Millisecond)
 		state, err := client.GetState()
 		if err == nil && state.Title != "" {
-			trackInfo := state.Title
-			if state.Artist != "" {
-				trackInfo = state.Artist + " - " + state.Title
-			}
-			notify("Volumio - Previous Track", trackInfo, "media-skip-backward", false)
+			notifyTrack("Previous Track", "media-skip-backward")
 		} else {
 			notify("Volumio", "Previous track", "media-skip-backward", false)
 		}
@@ -248,29 +376,37 @@ var skipCmd = &cobra.Command{
 // Volume commands
 
 var volumeCmd = &cobra.Command{
-	Use:   "volume [up|down|<level>]",
+	Use:   "volume [up|down|<level>|+N|-N]",
 	Short: "Control volume",
-	Args:  cobra.ExactArgs(1),
+	Long: `Change the volume. up and down change it by volume.step from the config
+(default 10); +N and -N by N. Levels are kept within the configured
+min_volume and max_volume.
+
+Example: volu volume up
+         volu volume +3
+         volu volume -5
+         volu volume 50%`,
+	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		action := args[0]
 
 		switch action {
 		case "up":
-			if err := client.VolumeUp(10); err != nil {
+			if err := newRouter().RunString(launcher.Command("volup")); err != nil {
 				notify("Volumio Error", "Could not change volume", "error", true)
 				return err
 			}
 		case "down":
-			if err := client.VolumeDown(10); err != nil {
+			if err := newRouter().RunString(launcher.Command("voldown")); err != nil {
 				notify("Volumio Error", "Could not change volume", "error", true)
 				return err
 			}
 		default:
-			var level int
-			if _, err := fmt.Sscanf(action, "%d", &level); err != nil {
-				return fmt.Errorf("invalid volume level: %s (use 'up', 'down', or 0-100)", action)
+			level, relative, err := volumio.ParseVolumeChange(action)
+			if err != nil {
+				return fmt.Errorf("invalid volume level: %s (use 'up', 'down', 0-100, +N or -N)", action)
 			}
-			if err := client.SetVolume(level); err != nil {
+			if err := client.ChangeVolume(level, relative); err != nil {
 				notify("Volumio Error", "Could not set volume", "error", true)
 				return err
 			}
@@ -279,12 +415,58 @@ var volumeCmd = &cobra.Command{
 		time.Sleep(300 * time.Millisecond)
 		state, err := client.GetState()
 		if err == nil {
-			notify("Volumio Volume", fmt.Sprintf("%d%%", state.Volume), "audio-volume-high", false)
+			notifyVolume(state.Volume)
 		}
 		return nil
 	},
 }
 
+var (
+	volumeFadeOver  time.Duration
+	volumeFadeCurve string
+)
+
+var volumeFadeCmd = &cobra.Command{
+	Use:   "fade <level>",
+	Short: "Fade the volume to a level",
+	Long: `Fade the volume smoothly to a level instead of jumping there.
+
+Example: volu volume fade 20 --over 5s
+         volu volume fade 0 --over 1m --curve log`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var level int
+		if _, err := fmt.Sscanf(args[0], "%d", &level); err != nil || level < 0 || level > 100 {
+			return fmt.Errorf("invalid volume level: %s (use 0-100)", args[0])
+		}
+		name := volumeFadeCurve
+		if name == "" {
+			name = cfg.Volume.Curve
+		}
+		curve, err := volumio.ParseCurve(name)
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if err := client.FadeTo(ctx, level, volumeFadeOver, curve); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			notify("Volumio Error", "Could not fade volume", "error", true)
+			return err
+		}
+		notifyVolume(level)
+		return nil
+	},
+}
+
+func init() {
+	volumeFadeCmd.Flags().DurationVar(&volumeFadeOver, "over", 3*time.Second, "How long the fade takes")
+	volumeFadeCmd.Flags().StringVar(&volumeFadeCurve, "curve", "", "Fade curve: linear or log (default: volume.curve from config)")
+}
+
 // Status command
 
 var statusCmd = &cobra.Command{
@@ -396,6 +578,9 @@ var waybarCmd = &cobra.Command{
 		}
 
 		output := waybar.CreateOutput(state, client.GetAlbumArtURL(""))
+		if timer, _ := loadSleepTimer(); timer != nil {
+			output = waybar.WithSleepTimer(output, timer.Remaining(time.Now()))
+		}
 		return waybar.PrintJSON(output)
 	},
 }
@@ -405,87 +590,50 @@ var waybarCmd = &cobra.Command{
 var walkerCmd = &cobra.Command{
 	Use:   "walker [action]",
 	Short: "Walker plugin interface",
-	Long:  `Walker plugin for browsing and controlling Volumio. Pass action from stdin or as argument.`,
+	Long: `Walker plugin for browsing and controlling Volumio. Pass action from stdin or as argument.
+
+Browse menus remember where you came from: "Back" returns to the parent
+folder and "Main Menu" to the top. The navigation stack is kept under
+$XDG_RUNTIME_DIR/volu/walker and starts afresh whenever the main menu
+opens.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		state, err := client.GetState()
+		router := newRouter()
+
+		dir, err := walker.SessionDir()
 		if err != nil {
-			// Show error but still output menu
-			state = nil
+			return err
 		}
+		session := walker.LoadSession(dir, walker.UserSession)
 
-		// Default: show main menu
+		// Default: show main menu, starting a new session
 		if len(args) == 0 {
-			items := walker.CreateMainMenu(state)
-			return walker.PrintItems(items)
-		}
-
-		action := args[0]
-
-		// Handle actions
-		if len(action) > 7 && action[:7] == "action:" {
-			return handleWalkerAction(action[7:])
-		}
-
-		// Handle browse
-		if len(action) > 7 && action[:7] == "browse:" {
-			uri := action[7:]
-			items, err := client.Browse(uri)
+			session.Navigator = launcher.Navigator{}
+		} else {
+			done, err := session.Select(router, args[0])
 			if err != nil {
-				return fmt.Errorf("failed to browse: %w", err)
+				return err
 			}
-			walkerItems := walker.CreateBrowseMenu(items, uri)
-			return walker.PrintItems(walkerItems)
-		}
-
-		// Handle play
-		if len(action) > 5 && action[:5] == "play:" {
-			// Parse uri|service
-			data := action[5:]
-			uri := data
-			service := ""
-			for i, ch := range data {
-				if ch == '|' {
-					uri = data[:i]
-					service = data[i+1:]
-					break
-				}
+			if done {
+				return nil
 			}
-			return client.ReplaceAndPlay(uri, service)
 		}
 
-		return fmt.Errorf("unknown action: %s", action)
+		items, err := session.Menu(router)
+		if err != nil {
+			// Stay in the parent folder when a browse fails
+			session.Select(router, "nav:back")
+			session.Returned = ""
+			if items, err = session.Menu(router); err != nil {
+				return err
+			}
+		}
+		if err := session.Save(); err != nil {
+			return err
+		}
+		return walker.PrintItems(walker.Render(items))
 	},
 }
 
-func handleWalkerAction(action string) error {
-	switch action {
-	case "toggle":
-		return client.TogglePlayPause()
-	case "play":
-		return client.Play()
-	case "pause":
-		return client.Pause()
-	case "stop":
-		return client.Stop()
-	case "next":
-		return client.Next()
-	case "prev":
-		return client.Previous()
-	case "volup":
-		return client.VolumeUp(10)
-	case "voldown":
-		return client.VolumeDown(10)
-	case "mute":
-		return client.ToggleMute()
-	case "shuffle":
-		return client.ToggleRandom()
-	case "repeat":
-		return client.ToggleRepeat()
-	default:
-		return fmt.Errorf("unknown action: %s", action)
-	}
-}
-
 // Helper function to play radio series
 func playRadioSeries(seriesName string, count int) error {
 	// Get series config
@@ -494,8 +642,15 @@ func playRadioSeries(seriesName string, count int) error {
 		return fmt.Errorf("unknown radio series: %s (check your config file at ~/.config/volu/config.yaml)", seriesName)
 	}
 
-	// Create radio player
+	// Create radio player, using the local library index when available
+	// and preferring less played episodes when there is a history
 	player := radio.NewPlayer(client)
+	if idx := loadLibrary(); idx != nil {
+		player.UseLibrary(idx)
+	}
+	if plays := historyPlays(); plays != nil {
+		player.UseHistory(plays)
+	}
 
 	// Show search notification
 	notify("Volumio Radio",
@@ -548,12 +703,36 @@ Example: volu radio asot 3
 
 // Elephant provider
 
+var elephantSocket string
+
 var elephantCmd = &cobra.Command{
 	Use:   "elephant",
-	Short: "Start elephant provider",
-	Long:  `Start the elephant provider for integration with https://github.com/abenz1267/elephant`,
+	Short: "Start the experimental Elephant bridge",
+	Long: `Start an experimental bridge towards https://github.com/abenz1267/elephant
+
+Elephant can't talk to it directly: native Elephant support is still to
+come. The bridge is long-lived and serves volu's launcher menus in a
+JSON-lines protocol of its own, answering query and activate requests over
+stdin/stdout (or a unix socket with --socket) and telling the peer to
+refresh whenever the player state changes.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		provider := elephant.NewProvider(volumioHost)
-		return provider.Run()
+		provider := elephant.NewProvider(client)
+		provider.UsePresets(launcherPresets())
+		provider.UseTransportFade(transportFade())
+		provider.UseVolumeStep(newRouter().VolumeStep)
+		if elephantSocket == "" {
+			return provider.Run()
+		}
+
+		conn, err := net.Dial("unix", elephantSocket)
+		if err != nil {
+			return fmt.Errorf("failed to connect to bridge peer: %w", err)
+		}
+		defer conn.Close()
+		return provider.Serve(conn, conn)
 	},
 }
+
+func init() {
+	elephantCmd.Flags().StringVar(&elephantSocket, "socket", "", "Connect to a bridge peer over this unix socket instead of stdin/stdout")
+}
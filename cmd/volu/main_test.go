@@ -0,0 +1,162 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/riclib/volu/internal/config"
+	"github.com/riclib/volu/internal/volumio"
+	"github.com/riclib/volu/internal/volumiofake"
+	"github.com/riclib/volu/internal/volumiotest"
+)
+
+// useFake points the CLI at a fake Volumio, with its files in temporary
+// directories and notifications off.
+func useFake(t *testing.T) (*volumiotest.Server, volumiofake.Library) {
+	t.Helper()
+	for _, env := range []string{"HOME", "XDG_CONFIG_HOME", "XDG_CACHE_HOME", "XDG_DATA_HOME", "XDG_STATE_HOME", "XDG_RUNTIME_DIR"} {
+		t.Setenv(env, t.TempDir())
+	}
+
+	lib := volumiofake.DefaultLibrary()
+	server := volumiotest.NewServer(lib)
+	t.Cleanup(server.Close)
+
+	cfg = config.DefaultConfig()
+	cfg.Notifications.Backend = "off"
+	cfgLoaded = true
+	notifier = nil
+	client = volumio.NewClient(server.URL, volumio.WithRetries(0, 0))
+	return server, lib
+}
+
+// captureStdout returns what fn prints to stdout.
+func captureStdout(t *testing.T, fn func() error) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	runErr := fn()
+	os.Stdout = stdout
+	w.Close()
+	out, _ := io.ReadAll(r)
+	if runErr != nil {
+		t.Fatalf("command failed: %v", runErr)
+	}
+	return string(out)
+}
+
+func TestTransportCommands(t *testing.T) {
+	server, lib := useFake(t)
+	migration := lib.Albums[len(lib.Albums)-1]
+	server.Load(migration.Tracks, 0, "stop", 0)
+
+	steps := []struct {
+		name     string
+		run      func() error
+		status   string
+		position int
+	}{
+		{"play", func() error { return playCmd.RunE(playCmd, nil) }, "play", 0},
+		{"next", func() error { return nextCmd.RunE(nextCmd, nil) }, "play", 1},
+		{"pause", func() error { return pauseCmd.RunE(pauseCmd, nil) }, "pause", 1},
+		{"toggle", func() error { return toggleCmd.RunE(toggleCmd, nil) }, "play", 1},
+		{"prev", func() error { return prevCmd.RunE(prevCmd, nil) }, "play", 0},
+		{"stop", func() error { return stopCmd.RunE(stopCmd, nil) }, "stop", 0},
+	}
+	for _, step := range steps {
+		if err := step.run(); err != nil {
+			t.Fatalf("volu %s: %v", step.name, err)
+		}
+		if state := server.State(); state.Status != step.status || state.Position != step.position {
+			t.Errorf("after volu %s: %s at %d, want %s at %d", step.name, state.Status, state.Position, step.status, step.position)
+		}
+	}
+}
+
+func TestPauseFadeRestoresVolume(t *testing.T) {
+	server, lib := useFake(t)
+	server.Load(lib.Albums[0].Tracks, 0, "play", 0)
+	cfg.Volume.FadeOnPause = 300 * time.Millisecond
+
+	if err := pauseCmd.RunE(pauseCmd, nil); err != nil {
+		t.Fatalf("volu pause: %v", err)
+	}
+	state := server.State()
+	if state.Status != "pause" || state.Volume != 40 {
+		t.Errorf("after a faded pause: %s at volume %d, want pause at 40", state.Status, state.Volume)
+	}
+	if !strings.Contains(strings.Join(server.Commands(), " "), "volume pause volume") {
+		t.Errorf("commands = %v, want a fade before pausing and the volume restored", server.Commands())
+	}
+}
+
+func TestVolumeCommand(t *testing.T) {
+	server, _ := useFake(t)
+	client = volumio.NewClient(server.URL, volumio.WithVolumeLimits(0, 55))
+
+	for _, step := range []struct {
+		arg  string
+		want int
+	}{{"up", 50}, {"-5", 45}, {"+20", 55}, {"30%", 30}} {
+		if err := volumeCmd.RunE(volumeCmd, []string{step.arg}); err != nil {
+			t.Fatalf("volu volume %s: %v", step.arg, err)
+		}
+		if got := server.State().Volume; got != step.want {
+			t.Errorf("after volu volume %s: %d, want %d", step.arg, got, step.want)
+		}
+	}
+}
+
+func TestRadioSeries(t *testing.T) {
+	server, _ := useFake(t)
+	cfg.Radio["asot"] = config.RadioSeries{Name: "A State of Trance", SearchQuery: "ASOT", Pattern: `^ASOT\s+\d+`}
+
+	if err := playRadioSeries("asot", 2); err != nil {
+		t.Fatalf("volu radio asot 2: %v", err)
+	}
+	state := server.State()
+	if state.Status != "play" || !strings.HasPrefix(state.Album, "ASOT ") {
+		t.Errorf("state = %s %q, want an ASOT episode playing", state.Status, state.Album)
+	}
+	albums := map[string]bool{}
+	for _, track := range server.Queue() {
+		albums[track.Album] = true
+	}
+	if len(albums) != 2 {
+		t.Errorf("queued episodes = %v, want 2", albums)
+	}
+}
+
+func TestWalkerBrowse(t *testing.T) {
+	server, lib := useFake(t)
+	migration := lib.Albums[len(lib.Albums)-1]
+
+	menu := captureStdout(t, func() error { return walkerCmd.RunE(walkerCmd, nil) })
+	if !strings.Contains(menu, "browse:") {
+		t.Fatalf("main menu has no browse entries:\n%s", menu)
+	}
+
+	album := captureStdout(t, func() error { return walkerCmd.RunE(walkerCmd, []string{"browse:" + migration.URI}) })
+	for _, track := range migration.Tracks {
+		if !strings.Contains(album, track.Title) {
+			t.Errorf("album menu lacks %q:\n%s", track.Title, album)
+		}
+	}
+	if !strings.Contains(album, "nav:back") {
+		t.Errorf("album menu has no way back:\n%s", album)
+	}
+
+	captureStdout(t, func() error {
+		return walkerCmd.RunE(walkerCmd, []string{"play:" + migration.URI + "|mpd"})
+	})
+	if state := server.State(); state.Status != "play" || state.Album != migration.Title {
+		t.Errorf("state = %s %q, want %q playing", state.Status, state.Album, migration.Title)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/riclib/volu/internal/launcher"
+	"github.com/spf13/cobra"
+)
+
+// Menu command
+
+var menuLauncher string
+
+var menuCmd = &cobra.Command{
+	Use:   "menu [selection]",
+	Short: "Browse and control Volumio from rofi, dmenu, fuzzel, wofi or fzf",
+	Long: `Show the same main and browse menus as the Walker plugin in a line-based launcher.
+
+With --launcher rofi, volu runs rofi in script mode (and is re-invoked by rofi
+for each selection). The other launchers are started in a loop until an
+action is chosen or the menu is dismissed. Typing text that matches no entry
+searches the library.
+
+Example: volu menu --launcher rofi
+         volu menu --launcher fuzzel
+         rofi -show volu -modi "volu:volu menu --launcher rofi"`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		router := newRouter()
+
+		if menuLauncher == "rofi" {
+			if os.Getenv("ROFI_RETV") == "" {
+				return startRofi()
+			}
+			selection := ""
+			if len(args) == 1 {
+				selection = args[0]
+			}
+			return rofiStep(router, selection)
+		}
+
+		frontend, ok := launcher.Frontends[menuLauncher]
+		if !ok {
+			return fmt.Errorf("unknown launcher %q (choose from %s)", menuLauncher, strings.Join(launcher.FrontendNames(), ", "))
+		}
+		return runFrontend(router, frontend)
+	},
+}
+
+func init() {
+	menuCmd.Flags().StringVarP(&menuLauncher, "launcher", "l", "rofi", "Launcher to use: "+strings.Join(launcher.FrontendNames(), "|"))
+}
+
+// startRofi launches rofi with volu registered as a script mode.
+func startRofi() error {
+	self, err := os.Executable()
+	if err != nil {
+		self = "volu"
+	}
+	rofi := exec.Command("rofi", "-show", "volu", "-modi", "volu:"+rofiScript(self))
+	rofi.Stdin, rofi.Stdout, rofi.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := rofi.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			// rofi exits non-zero when dismissed
+			return nil
+		}
+		return fmt.Errorf("failed to run rofi: %w", err)
+	}
+	return nil
+}
+
+// rofiScript returns the shell command rofi runs for each step of the
+// script mode, passing on the persistent flags volu was started with.
+func rofiScript(self string) string {
+	args := []string{self, "--host", volumioHost}
+	if recordCassette != "" {
+		args = append(args, "--record", recordCassette)
+	}
+	if debugRequests {
+		args = append(args, "--debug")
+	}
+	args = append(args, "menu", "--launcher", "rofi")
+
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// shellQuote quotes s as a single shell word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// rofiStep handles one rofi script-mode invocation. The navigation stack
+// round-trips through $ROFI_DATA; the selected row's action arrives in
+// $ROFI_INFO and typed text in the argument.
+func rofiStep(router *launcher.Router, selection string) error {
+	nav := launcher.DecodeNavigator(os.Getenv("ROFI_DATA"))
+	message := ""
+
+	switch os.Getenv("ROFI_RETV") {
+	case "1": // Entry selected
+		if action := os.Getenv("ROFI_INFO"); action != "" {
+			done, err := nav.SelectTitled(router, action, launcher.TitleFromText(selection))
+			if err != nil {
+				message = err.Error()
+			} else if done {
+				// Print nothing so rofi closes
+				return nil
+			}
+		}
+	case "2": // Custom text entered
+		if query := strings.TrimSpace(selection); query != "" {
+			nav.Select(router, launcher.Search(query))
+		}
+	}
+
+	items, err := nav.Menu(router)
+	if err != nil {
+		message = err.Error()
+		nav.Select(router, "nav:back")
+		if items, err = nav.Menu(router); err != nil {
+			return err
+		}
+	}
+
+	return launcher.WriteRofi(os.Stdout, items, message, nav.Encode())
+}
+
+// runFrontend shows menus in a dmenu-style launcher until an action runs
+// or the user cancels.
+func runFrontend(router *launcher.Router, frontend launcher.Frontend) error {
+	nav := &launcher.Navigator{}
+
+	for {
+		items, err := nav.Menu(router)
+		if err != nil {
+			if nav.Current() == "" {
+				return err
+			}
+			notify("Volumio Error", err.Error(), "error", true)
+			nav.Select(router, "nav:back")
+			continue
+		}
+
+		selected, query, err := frontend.Choose(items)
+		if errors.Is(err, launcher.ErrCancelled) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		action := selected.Action
+		if query != "" {
+			action = launcher.Search(query)
+		}
+		if action == "" {
+			// Separator or info line, show the menu again
+			continue
+		}
+
+		done, err := nav.SelectTitled(router, action, selected.Label)
+		if err != nil {
+			notify("Volumio Error", err.Error(), "error", true)
+			return err
+		}
+		if done {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestRofiScript(t *testing.T) {
+	host, record, debug := volumioHost, recordCassette, debugRequests
+	t.Cleanup(func() { volumioHost, recordCassette, debugRequests = host, record, debug })
+	volumioHost = "living room's pi"
+	recordCassette = "/tmp/my cassette.json"
+	debugRequests = true
+
+	// Let the shell split the script back into words
+	script := rofiScript("/opt/my apps/volu")
+	out, err := exec.Command("sh", "-c", `printf '%s\n' `+script).Output()
+	if err != nil {
+		t.Fatalf("sh failed on %s: %v", script, err)
+	}
+	want := []string{"/opt/my apps/volu", "--host", "living room's pi", "--record", "/tmp/my cassette.json", "--debug", "menu", "--launcher", "rofi"}
+	if got := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n"); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("script %s splits into %q, want %q", script, got, want)
+	}
+}
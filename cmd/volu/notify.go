@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/riclib/volu/internal/config"
+	"github.com/riclib/volu/internal/launcher"
+	"github.com/riclib/volu/internal/notifications"
+	"github.com/riclib/volu/internal/volumio"
+	"github.com/spf13/cobra"
+)
+
+// Notifications
+
+// actionWait caps how long a track notification's buttons stay usable.
+const actionWait = time.Minute
+
+var notifier notifications.Notifier
+
+// getNotifier returns the notifier configured in the config file.
+func getNotifier() notifications.Notifier {
+	if notifier == nil {
+		var settings config.NotificationConfig
+		if cfg != nil {
+			settings = cfg.Notifications
+		}
+		notifier = notifications.New(settings)
+	}
+	return notifier
+}
+
+// Helper function to send notifications. Info notifications usually
+// confirm something volu started playing, so 'volu notifyd' leaves the
+// resulting track change alone.
+func notify(title, message, icon string, urgent bool) {
+	notifyWith(getNotifier(), title, message, icon, urgent)
+}
+
+// notifyWith is notify through n, for goroutines that must not touch the
+// global notifier while the config is reloaded.
+func notifyWith(n notifications.Notifier, title, message, icon string, urgent bool) {
+	category := notifications.CategoryInfo
+	if urgent {
+		category = notifications.CategoryError
+	} else {
+		notifications.MarkSelf()
+	}
+	n.Notify(notifications.Notification{Category: category, Title: title, Body: message, Icon: icon})
+}
+
+// notifyVolume shows the volume, replacing the previous volume notification.
+func notifyVolume(volume int) {
+	getNotifier().Notify(notifications.Notification{
+		Category: notifications.CategoryVolume,
+		Title:    "Volumio Volume",
+		Body:     fmt.Sprintf("%d%%", volume),
+		Icon:     "audio-volume-high",
+	})
+}
+
+// notifyTrack shows the current track with its album art and Skip and
+// Pause buttons. Clicks are handled by a detached 'volu notify-track'
+// process, as the notification server reports them only to the process
+// that showed the notification.
+func notifyTrack(heading, icon string) {
+	notifications.MarkSelf()
+	if !notifications.SupportsActions(getNotifier()) {
+		state, err := client.GetState()
+		if err != nil {
+			return
+		}
+		getNotifier().Notify(trackNotification(heading, icon, state))
+		return
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return
+	}
+	wait := exec.Command(self, "--host", volumioHost, "notify-track", "--heading", heading, "--icon", icon)
+	wait.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if wait.Start() == nil {
+		wait.Process.Release()
+	}
+}
+
+// trackNotification builds the now playing notification for state.
+func trackNotification(heading, icon string, state *volumio.PlayerState) notifications.Notification {
+	n := notifications.Notification{
+		Category: notifications.CategoryTrack,
+		Title:    "Volumio - " + heading,
+		Body:     state.Title,
+		Icon:     icon,
+		Actions:  []notifications.Action{{Key: "next", Label: "Skip"}},
+	}
+	if state.Artist != "" {
+		n.Body = state.Artist + " - " + state.Title
+	}
+	if state.Album != "" {
+		n.Body += "\n" + state.Album
+	}
+
+	if state.Status == "play" {
+		n.Actions = append(n.Actions, notifications.Action{Key: "toggle", Label: "Pause"})
+	} else {
+		n.Actions = append(n.Actions, notifications.Action{Key: "toggle", Label: "Play"})
+	}
+
+	if state.AlbumArt != "" {
+		if path, err := notifications.CacheImage(client.GetAlbumArtURL(state.AlbumArt)); err == nil {
+			n.Image = path
+		}
+	}
+	return n
+}
+
+var (
+	notifyHeading string
+	notifyIcon    string
+)
+
+// notifyTrackCmd is the background process started by notifyTrack.
+var notifyTrackCmd = &cobra.Command{
+	Use:    "notify-track",
+	Short:  "Show the current track and handle its buttons",
+	Hidden: true,
+	Args:   cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		bus, err := notifications.NewDBus(cfg.Notifications.Timeout)
+		if err != nil {
+			return err
+		}
+		state, err := client.GetState()
+		if err != nil {
+			return err
+		}
+
+		if trackDisabled() {
+			return nil
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), actionWait)
+		defer cancel()
+		return showTrack(ctx, bus, trackNotification(notifyHeading, notifyIcon, state))
+	},
+}
+
+// trackDisabled reports whether track notifications are turned off.
+func trackDisabled() bool {
+	filter, ok := getNotifier().(notifications.Filter)
+	return ok && filter.Disables(notifications.CategoryTrack)
+}
+
+// showTrack shows a track notification and runs the command of the
+// button clicked, if any, before ctx is done.
+func showTrack(ctx context.Context, bus *notifications.DBus, n notifications.Notification) error {
+	key, err := bus.NotifyAndWait(ctx, n)
+	if err != nil || key == "" {
+		return err
+	}
+	return newRouter().RunString(launcher.Command(key))
+}
+
+func init() {
+	notifyTrackCmd.Flags().StringVar(&notifyHeading, "heading", "Now Playing", "Notification title after \"Volumio - \"")
+	notifyTrackCmd.Flags().StringVar(&notifyIcon, "icon", "media-playback-start", "Icon name")
+}
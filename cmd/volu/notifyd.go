@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/riclib/volu/internal/notifications"
+	"github.com/spf13/cobra"
+)
+
+// Notification daemon command
+
+var (
+	notifydInterval time.Duration
+	notifydDebounce time.Duration
+)
+
+var notifydCmd = &cobra.Command{
+	Use:   "notifyd",
+	Short: "Show a notification whenever the track changes",
+	Long: `Watch Volumio and show the title, artist, album and cover of every new
+track, including tracks that start on their own at the end of the previous
+one. A new track is announced once it has played for --debounce, so
+skipping through several tracks shows only the last. Changes made with
+volu itself (next, prev, radio, ...) are left alone, as those commands
+show their own notification.
+
+Run it as a systemd user service, e.g.:
+
+  [Service]
+  ExecStart=%h/go/bin/volu notifyd
+  Restart=on-failure`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+		defer stop()
+
+		// Buttons need a D-Bus connection that stays open while they can
+		// be clicked
+		var bus *notifications.DBus
+		if notifications.SupportsActions(getNotifier()) {
+			bus, _ = notifications.NewDBus(cfg.Notifications.Timeout)
+		}
+
+		watcher := &notifications.TrackWatcher{Debounce: notifydDebounce}
+		hideButtons := context.CancelFunc(func() {})
+		defer func() { hideButtons() }()
+
+		ticker := time.NewTicker(notifydInterval)
+		defer ticker.Stop()
+
+		daemonLog("Watching %s for track changes", volumioHost)
+		failing := false
+		for {
+			select {
+			case <-ctx.Done():
+				daemonLog("Stopping")
+				return nil
+			case <-ticker.C:
+			}
+
+			state, err := client.GetState()
+			if err != nil {
+				if !failing {
+					daemonLog("Could not get player state: %v", err)
+					failing = true
+				}
+				continue
+			}
+			if failing {
+				daemonLog("Connected to %s again", volumioHost)
+				failing = false
+			}
+
+			if !watcher.Update(state, time.Now()) || trackDisabled() || notifications.TriggeredBySelf(watcher.Since()) {
+				continue
+			}
+
+			n := trackNotification("Now Playing", "media-playback-start", state)
+			if bus == nil {
+				getNotifier().Notify(n)
+				continue
+			}
+
+			// The new notification replaces the previous one, whose
+			// buttons stop working
+			hideButtons()
+			shown, cancel := context.WithTimeout(ctx, actionWait)
+			hideButtons = cancel
+			go func() {
+				defer cancel()
+				if err := showTrack(shown, bus, n); err != nil {
+					daemonLog("Notification failed: %v", err)
+				}
+			}()
+		}
+	},
+}
+
+func init() {
+	notifydCmd.Flags().DurationVar(&notifydInterval, "interval", time.Second, "How often to check the player state")
+	notifydCmd.Flags().DurationVar(&notifydDebounce, "debounce", 2*time.Second, "How long a new track must play before it is announced")
+}
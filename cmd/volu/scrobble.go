@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/riclib/volu/internal/config"
+	"github.com/riclib/volu/internal/scrobble"
+	"github.com/spf13/cobra"
+)
+
+// Scrobble commands
+
+// scrobbleRetry is how often queued listens are retried.
+const scrobbleRetry = time.Minute
+
+var scrobbleInterval time.Duration
+
+var scrobbleCmd = &cobra.Command{
+	Use:   "scrobble",
+	Short: "Submit what Volumio plays to ListenBrainz and Last.fm",
+	Long: `Watch Volumio and submit every track played to the services set up in
+the scrobble section of the config. Each new track is sent as now playing,
+and scrobbled once half of it, or 4 minutes, has played. Tracks shorter
+than 30 seconds are not scrobbled.
+
+Listens that cannot be submitted, e.g. while offline, are queued on disk
+and retried every minute, including after a restart.
+
+Run it as a systemd user service, e.g.:
+
+  [Service]
+  ExecStart=%h/go/bin/volu scrobble
+  Restart=on-failure`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		services := scrobbleServices()
+		if len(services) == 0 {
+			return fmt.Errorf("no scrobbling service configured; add listenbrainz or lastfm to the scrobble section of %s", configPathOrDefault())
+		}
+		queues := make(map[string]*scrobble.Queue)
+		for _, s := range services {
+			q, err := scrobble.DefaultQueue(s.Name())
+			if err != nil {
+				return err
+			}
+			queues[s.Name()] = q
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+		defer stop()
+
+		poll := time.NewTicker(scrobbleInterval)
+		defer poll.Stop()
+		retry := time.NewTicker(scrobbleRetry)
+		defer retry.Stop()
+
+		for _, s := range services {
+			submitListens(s, queues[s.Name()])
+		}
+		daemonLog("Scrobbling %s to %s", volumioHost, serviceNames(services))
+
+		var tracker scrobble.Tracker
+		for {
+			select {
+			case <-ctx.Done():
+				daemonLog("Stopping")
+				return nil
+			case <-retry.C:
+				for _, s := range services {
+					submitListens(s, queues[s.Name()])
+				}
+				continue
+			case <-poll.C:
+			}
+
+			state, err := client.GetState()
+			if err != nil {
+				// Count nothing as played while Volumio is unreachable
+				tracker.Pause(time.Now())
+				continue
+			}
+			nowPlaying, listen := tracker.Update(state, time.Now())
+			for _, s := range services {
+				if nowPlaying != nil {
+					if err := s.NowPlaying(*nowPlaying); err != nil {
+						daemonLog("%s: now playing failed: %v", s.Name(), err)
+					}
+				}
+				if listen != nil {
+					submitListens(s, queues[s.Name()], *listen)
+				}
+			}
+			if listen != nil {
+				daemonLog("Scrobbled %s - %s", listen.Artist, listen.Title)
+			}
+		}
+	},
+}
+
+// scrobbleServices returns the services set up in the config.
+func scrobbleServices() []scrobble.Service {
+	var services []scrobble.Service
+	if lb := cfg.Scrobble.ListenBrainz; lb.Token != "" {
+		services = append(services, scrobble.NewListenBrainz(lb.URL, lb.Token))
+	}
+	if fm := cfg.Scrobble.LastFM; fm.APIKey != "" {
+		if key := lastFMSessionKey(); key != "" {
+			services = append(services, scrobble.NewLastFM(fm.URL, fm.APIKey, fm.APISecret, key))
+		}
+	}
+	return services
+}
+
+// lastFMSessionKey returns the session key saved by 'volu scrobble login',
+// or the one from the config if set there.
+func lastFMSessionKey() string {
+	if key := cfg.Scrobble.LastFM.SessionKey; key != "" {
+		return key
+	}
+	path, err := scrobble.DefaultSessionPath()
+	if err != nil {
+		return ""
+	}
+	key, err := scrobble.LoadSessionKey(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	return key
+}
+
+// submitListens sends queued listens and listens to s, logging failures.
+func submitListens(s scrobble.Service, q *scrobble.Queue, listens ...scrobble.Listen) {
+	sent, err := scrobble.Submit(s, q, listens...)
+	if err != nil {
+		daemonLog("%s: %v", s.Name(), err)
+	}
+	if queued := sent - len(listens); queued > 0 {
+		daemonLog("%s: submitted %d queued listens", s.Name(), queued)
+	}
+}
+
+// serviceNames lists the services' names for messages.
+func serviceNames(services []scrobble.Service) string {
+	names := make([]string, len(services))
+	for i, s := range services {
+		names[i] = s.Name()
+	}
+	return strings.Join(names, ", ")
+}
+
+// configPathOrDefault returns the config file path for messages.
+func configPathOrDefault() string {
+	if path, err := config.GetConfigPath(); err == nil {
+		return path
+	}
+	return "the config file"
+}
+
+var scrobbleStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show scrobbling services and queued listens",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		services := scrobbleServices()
+		if len(services) == 0 {
+			fmt.Println("No scrobbling service configured")
+			return nil
+		}
+		for _, s := range services {
+			q, err := scrobble.DefaultQueue(s.Name())
+			if err != nil {
+				return err
+			}
+			queued, err := q.Load()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s: %d queued\n", s.Name(), len(queued))
+		}
+		return nil
+	},
+}
+
+var scrobbleLoginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "Connect volu to your Last.fm account",
+	Long: `Get a Last.fm session key and save it in
+~/.local/state/volu/lastfm-session, readable only by you. Create an API
+account at https://www.last.fm/api/account/create and put its key and
+secret in the config first:
+
+  scrobble:
+    lastfm:
+      api_key: ...
+      api_secret: ...
+
+ListenBrainz needs no login; set scrobble.listenbrainz.token to the user
+token from https://listenbrainz.org/settings/.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		settings := cfg.Scrobble.LastFM
+		if settings.APIKey == "" || settings.APISecret == "" {
+			return fmt.Errorf("set scrobble.lastfm.api_key and api_secret in %s first", configPathOrDefault())
+		}
+		fm := scrobble.NewLastFM(settings.URL, settings.APIKey, settings.APISecret, "")
+
+		token, err := fm.Token()
+		if err != nil {
+			return fmt.Errorf("failed to start Last.fm login: %w", err)
+		}
+		fmt.Printf("Allow volu to scrobble at:\n\n  %s\n\nthen press Enter.", fm.AuthURL(token))
+		bufio.NewReader(os.Stdin).ReadString('\n')
+
+		name, key, err := fm.Session(token)
+		if err != nil {
+			return fmt.Errorf("failed to get Last.fm session: %w", err)
+		}
+		path, err := scrobble.DefaultSessionPath()
+		if err != nil {
+			return err
+		}
+		if err := scrobble.SaveSessionKey(path, key); err != nil {
+			return err
+		}
+		fmt.Printf("Scrobbling to Last.fm as %s\n", name)
+		return nil
+	},
+}
+
+func init() {
+	scrobbleCmd.Flags().DurationVar(&scrobbleInterval, "interval", 5*time.Second, "How often to check the player state")
+	scrobbleCmd.AddCommand(scrobbleStatusCmd)
+	scrobbleCmd.AddCommand(scrobbleLoginCmd)
+}
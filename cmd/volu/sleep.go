@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/riclib/volu/internal/sleep"
+	"github.com/spf13/cobra"
+)
+
+// Sleep timer commands
+
+var (
+	sleepFade  time.Duration
+	sleepUntil string
+)
+
+var sleepCmd = &cobra.Command{
+	Use:   "sleep <duration>",
+	Short: "Stop playback after a while, fading out first",
+	Long: `Start a sleep timer. Playback stops after the given duration; with --fade
+the volume is lowered gradually over the last part of it. The original
+volume is restored after stopping so the next play isn't silent.
+
+The timer runs in the background and survives the terminal closing. Setting
+a new timer replaces the running one.
+
+Example: volu sleep 30m
+         volu sleep 1h --fade 5m
+         volu sleep status
+         volu sleep cancel`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		duration, err := time.ParseDuration(args[0])
+		if err != nil || duration <= 0 {
+			return fmt.Errorf("invalid duration %q (use e.g. 30m or 1h30m)", args[0])
+		}
+		if sleepFade > duration {
+			return fmt.Errorf("fade (%s) is longer than the timer (%s)", sleepFade, duration)
+		}
+
+		path, err := sleep.DefaultPath(volumioHost)
+		if err != nil {
+			return err
+		}
+		if running, err := sleep.Load(path); err == nil {
+			running.Cancel()
+		}
+
+		deadline := time.Now().Add(duration)
+		self, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("failed to locate volu: %w", err)
+		}
+		run := exec.Command(self, "--host", volumioHost, "sleep", "run",
+			"--until", deadline.Format(time.RFC3339Nano), "--fade", sleepFade.String())
+		run.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+		if err := run.Start(); err != nil {
+			return fmt.Errorf("failed to start sleep timer: %w", err)
+		}
+
+		timer := &sleep.Timer{PID: run.Process.Pid, Host: volumioHost, Deadline: deadline, Fade: sleepFade}
+		if err := timer.Save(path); err != nil {
+			return err
+		}
+		run.Process.Release()
+
+		message := fmt.Sprintf("Stopping in %s", formatRemaining(duration))
+		if sleepFade > 0 {
+			message += fmt.Sprintf(", fading over the last %s", formatRemaining(sleepFade))
+		}
+		fmt.Println(message)
+		notify("Volumio Sleep Timer", message, "media-playback-stop", false)
+		return nil
+	},
+}
+
+var sleepStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the remaining time",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		timer, err := loadSleepTimer()
+		if err != nil {
+			return err
+		}
+		if timer == nil {
+			fmt.Println("No sleep timer")
+			return nil
+		}
+		fmt.Printf("Stopping in %s (at %s)\n", formatRemaining(timer.Remaining(time.Now())), timer.Deadline.Format("15:04"))
+		return nil
+	},
+}
+
+var sleepCancelCmd = &cobra.Command{
+	Use:   "cancel",
+	Short: "Cancel the sleep timer",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		timer, err := loadSleepTimer()
+		if err != nil {
+			return err
+		}
+		if timer == nil {
+			fmt.Println("No sleep timer")
+			return nil
+		}
+		if err := timer.Cancel(); err != nil {
+			return err
+		}
+		fmt.Println("Sleep timer cancelled")
+		notify("Volumio Sleep Timer", "Cancelled", "media-playback-start", false)
+		return nil
+	},
+}
+
+// sleepRunCmd is the background process started by sleepCmd.
+var sleepRunCmd = &cobra.Command{
+	Use:    "run",
+	Short:  "Run a sleep timer in the foreground",
+	Hidden: true,
+	Args:   cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		deadline, err := time.Parse(time.RFC3339Nano, sleepUntil)
+		if err != nil {
+			return fmt.Errorf("invalid --until: %w", err)
+		}
+		path, err := sleep.DefaultPath(volumioHost)
+		if err != nil {
+			return err
+		}
+
+		timer := &sleep.Timer{PID: os.Getpid(), Host: volumioHost, Deadline: deadline, Fade: sleepFade}
+		if err := timer.Save(path); err != nil {
+			return err
+		}
+		defer timer.Release(path)
+
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+		defer stop()
+		if err := timer.Run(ctx, client); err != nil {
+			if errors.Is(err, context.Canceled) {
+				return nil
+			}
+			notify("Volumio Sleep Timer", err.Error(), "error", true)
+			return err
+		}
+		return nil
+	},
+}
+
+func init() {
+	sleepCmd.Flags().DurationVar(&sleepFade, "fade", 0, "Fade the volume out over this long before stopping")
+	sleepRunCmd.Flags().StringVar(&sleepUntil, "until", "", "Stop time (RFC 3339)")
+	sleepRunCmd.Flags().DurationVar(&sleepFade, "fade", 0, "Fade duration")
+
+	sleepCmd.AddCommand(sleepStatusCmd, sleepCancelCmd, sleepRunCmd)
+}
+
+// loadSleepTimer returns the running sleep timer for the current host, or
+// nil if there is none.
+func loadSleepTimer() (*sleep.Timer, error) {
+	path, err := sleep.DefaultPath(volumioHost)
+	if err != nil {
+		return nil, err
+	}
+	timer, err := sleep.Load(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return timer, err
+}
+
+// formatRemaining formats a duration as e.g. "1h05m", "12m30s" or "45s".
+func formatRemaining(d time.Duration) string {
+	d = d.Round(time.Second)
+	h, m, s := int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60
+	switch {
+	case h > 0:
+		return fmt.Sprintf("%dh%02dm", h, m)
+	case m > 0 && s > 0:
+		return fmt.Sprintf("%dm%02ds", m, s)
+	case m > 0:
+		return fmt.Sprintf("%dm", m)
+	}
+	return fmt.Sprintf("%ds", s)
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/riclib/volu/internal/config"
+	"github.com/riclib/volu/internal/launcher"
+	"github.com/spf13/cobra"
+)
+
+// Web radio commands
+
+var (
+	webradioJSON bool
+	webradioPlay int
+	presetArt    string
+)
+
+var webradioCmd = &cobra.Command{
+	Use:     "webradio",
+	Aliases: []string{"wr"},
+	Short:   "Find, play and save web radio stations",
+	Long: `Search Volumio's web radio directory, play stations and keep numbered
+presets in the config file. Presets also appear numbered in the Walker,
+Elephant and launcher menus.`,
+}
+
+var webradioSearchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Search web radio stations",
+	Long: `Search web radio stations by name.
+
+Example: volu webradio search radio paradise
+         volu webradio search fip --play 1`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		query := strings.Join(args, " ")
+		stations, err := client.SearchWebRadio(query)
+		if err != nil {
+			return fmt.Errorf("failed to search web radio: %w", err)
+		}
+
+		if webradioPlay > 0 {
+			if webradioPlay > len(stations) {
+				return fmt.Errorf("station %d out of range (%d stations for %q)", webradioPlay, len(stations), query)
+			}
+			station := stations[webradioPlay-1]
+			return playStation(station.DisplayName(), station.URI)
+		}
+
+		if webradioJSON {
+			return json.NewEncoder(os.Stdout).Encode(stations)
+		}
+
+		if len(stations) == 0 {
+			fmt.Printf("No stations for %q\n", query)
+			return nil
+		}
+		for i, station := range stations {
+			fmt.Printf("%2d. %s\n    %s\n", i+1, station.DisplayName(), station.URI)
+		}
+		return nil
+	},
+}
+
+var webradioPlayCmd = &cobra.Command{
+	Use:   "play <url|query>",
+	Short: "Play a stream URL or the best matching station",
+	Long: `Play a web radio stream. A URL is played directly; anything else is
+searched and the first matching station is played.
+
+Example: volu webradio play http://stream.radioparadise.com/flac
+         volu webradio play fip`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		target := strings.Join(args, " ")
+		if isStreamURL(target) {
+			return playStation(target, target)
+		}
+
+		stations, err := client.SearchWebRadio(target)
+		if err != nil {
+			return fmt.Errorf("failed to search web radio: %w", err)
+		}
+		if len(stations) == 0 {
+			return fmt.Errorf("no stations for %q", target)
+		}
+		return playStation(stations[0].DisplayName(), stations[0].URI)
+	},
+}
+
+var presetCmd = &cobra.Command{
+	Use:   "preset",
+	Short: "Manage numbered web radio presets",
+}
+
+var presetAddCmd = &cobra.Command{
+	Use:   "add <name> [url]",
+	Short: "Save a station as a preset",
+	Long: `Save a web radio station as the next numbered preset. Without a URL, the
+station currently playing is saved.
+
+Example: volu webradio preset add "Office Radio" http://stream.example.com/office
+         volu webradio preset add FIP`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		preset := config.Preset{Name: args[0], AlbumArt: presetArt}
+
+		if len(args) == 2 {
+			preset.URI = args[1]
+		} else {
+			state, err := client.GetState()
+			if err != nil {
+				return fmt.Errorf("failed to get current station: %w", err)
+			}
+			if state.Service != "webradio" || state.URI == "" {
+				return fmt.Errorf("no web radio station playing, pass the stream URL")
+			}
+			preset.URI = state.URI
+			if preset.AlbumArt == "" && strings.HasPrefix(state.AlbumArt, "http") {
+				preset.AlbumArt = state.AlbumArt
+			}
+		}
+
+		cfg.WebRadio.Presets = append(cfg.WebRadio.Presets, preset)
+		if err := saveConfig(); err != nil {
+			return err
+		}
+		fmt.Printf("Saved preset %d: %s\n", len(cfg.WebRadio.Presets), preset.Name)
+		return nil
+	},
+}
+
+var presetLsCmd = &cobra.Command{
+	Use:     "ls",
+	Aliases: []string{"list"},
+	Short:   "List presets",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if webradioJSON {
+			return json.NewEncoder(os.Stdout).Encode(cfg.WebRadio.Presets)
+		}
+		if len(cfg.WebRadio.Presets) == 0 {
+			fmt.Println("No presets yet, add one with 'volu webradio preset add <name> [url]'")
+			return nil
+		}
+		for i, preset := range cfg.WebRadio.Presets {
+			fmt.Printf("%2d. %s\n    %s\n", i+1, preset.Name, preset.URI)
+		}
+		return nil
+	},
+}
+
+var presetPlayCmd = &cobra.Command{
+	Use:   "play <n>",
+	Short: "Play preset number n",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		preset, err := presetAt(args[0])
+		if err != nil {
+			return err
+		}
+		return playStation(preset.Name, preset.URI)
+	},
+}
+
+var presetRmCmd = &cobra.Command{
+	Use:   "rm <n>",
+	Short: "Remove preset number n",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		preset, err := presetAt(args[0])
+		if err != nil {
+			return err
+		}
+		n, _ := strconv.Atoi(args[0])
+		cfg.WebRadio.Presets = append(cfg.WebRadio.Presets[:n-1], cfg.WebRadio.Presets[n:]...)
+		if err := saveConfig(); err != nil {
+			return err
+		}
+		fmt.Printf("Removed preset %d: %s\n", n, preset.Name)
+		return nil
+	},
+}
+
+func init() {
+	webradioSearchCmd.Flags().BoolVar(&webradioJSON, "json", false, "Output stations as JSON")
+	webradioSearchCmd.Flags().IntVar(&webradioPlay, "play", 0, "Play station number N of the results")
+	presetLsCmd.Flags().BoolVar(&webradioJSON, "json", false, "Output presets as JSON")
+	presetAddCmd.Flags().StringVar(&presetArt, "art", "", "Logo URL to show in menus")
+
+	presetCmd.AddCommand(presetAddCmd, presetLsCmd, presetPlayCmd, presetRmCmd)
+	webradioCmd.AddCommand(webradioSearchCmd, webradioPlayCmd, presetCmd)
+}
+
+// presetAt returns the preset with the given 1-based number.
+func presetAt(arg string) (config.Preset, error) {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 || n > len(cfg.WebRadio.Presets) {
+		return config.Preset{}, fmt.Errorf("no preset %s (%d presets, see 'volu webradio preset ls')", arg, len(cfg.WebRadio.Presets))
+	}
+	return cfg.WebRadio.Presets[n-1], nil
+}
+
+// playStation replaces the queue with a web radio stream.
+func playStation(name, uri string) error {
+	if err := client.ReplaceAndPlay(uri, "webradio"); err != nil {
+		notify("Volumio Error", "Could not play "+name, "error", true)
+		return err
+	}
+	notify("Volumio", "Playing "+name, "media-playback-start", false)
+	return nil
+}
+
+// isStreamURL reports whether s looks like a stream URL rather than a query.
+func isStreamURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+// launcherPresets converts the configured presets for the launcher menus.
+func launcherPresets() []launcher.Preset {
+	if cfg == nil {
+		return nil
+	}
+	presets := make([]launcher.Preset, len(cfg.WebRadio.Presets))
+	for i, preset := range cfg.WebRadio.Presets {
+		presets[i] = launcher.Preset{Name: preset.Name, URI: preset.URI, AlbumArt: preset.AlbumArt}
+	}
+	return presets
+}
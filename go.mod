@@ -3,6 +3,7 @@ module github.com/riclib/volu
 go 1.25.3
 
 require (
+	github.com/godbus/dbus/v5 v5.2.2
 	github.com/spf13/cobra v1.10.1
 	gopkg.in/yaml.v3 v3.0.1
 )
@@ -10,4 +11,5 @@ require (
 require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.9 // indirect
+	golang.org/x/sys v0.27.0 // indirect
 )
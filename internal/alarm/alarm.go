@@ -0,0 +1,293 @@
+package alarm
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/riclib/volu/internal/config"
+	"github.com/riclib/volu/internal/volumio"
+)
+
+// MissedGrace is how late an alarm may still fire, e.g. after the machine
+// running the daemon wakes from suspend. Older alarms are skipped.
+const MissedGrace = 10 * time.Minute
+
+// dateLayout is the date format of one-shot alarms and holidays.
+const dateLayout = "2006-01-02"
+
+var dayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// Schedule is a parsed alarm time: either a time of day on some days of
+// the week, or a single date and time.
+type Schedule struct {
+	Hour, Minute int
+	Days         [7]bool   // indexed by time.Weekday, for recurring alarms
+	Date         time.Time // midnight of the day, for one-shots
+}
+
+// OneShot returns true if the schedule fires only once.
+func (s Schedule) OneShot() bool {
+	return !s.Date.IsZero()
+}
+
+// Parse parses a schedule such as "07:00" (every day), "07:00 mon-fri",
+// "18:30 sat,sun", "08:00 weekdays" or "2026-12-24 09:00" (once).
+func Parse(when string) (Schedule, error) {
+	fields := strings.Fields(strings.ToLower(when))
+	if len(fields) == 0 || len(fields) > 2 {
+		return Schedule{}, fmt.Errorf("invalid schedule %q (use e.g. \"07:00 mon-fri\" or \"2026-12-24 09:00\")", when)
+	}
+
+	var s Schedule
+	if date, err := time.ParseInLocation(dateLayout, fields[0], time.Local); err == nil {
+		if len(fields) != 2 {
+			return Schedule{}, fmt.Errorf("missing time in %q", when)
+		}
+		s.Date = date
+		fields = fields[1:]
+	}
+
+	clock, err := time.Parse("15:04", fields[0])
+	if err != nil {
+		return Schedule{}, fmt.Errorf("invalid time %q (use HH:MM)", fields[0])
+	}
+	s.Hour, s.Minute = clock.Hour(), clock.Minute()
+
+	if s.OneShot() {
+		return s, nil
+	}
+	if len(fields) == 1 {
+		fields = append(fields, "daily")
+	}
+	if s.Days, err = parseDays(fields[1]); err != nil {
+		return Schedule{}, err
+	}
+	return s, nil
+}
+
+// parseDays parses a comma separated list of day names, ranges such as
+// mon-fri, and the keywords daily, weekdays and weekends.
+func parseDays(spec string) ([7]bool, error) {
+	var days [7]bool
+	for _, part := range strings.Split(spec, ",") {
+		switch part {
+		case "daily", "everyday":
+			part = "sun-sat"
+		case "weekdays":
+			part = "mon-fri"
+		case "weekends":
+			days[time.Saturday], days[time.Sunday] = true, true
+			continue
+		}
+
+		from, to, isRange := strings.Cut(part, "-")
+		first, ok := dayNames[from]
+		if !ok {
+			return days, fmt.Errorf("unknown day %q", from)
+		}
+		last := first
+		if isRange {
+			if last, ok = dayNames[to]; !ok {
+				return days, fmt.Errorf("unknown day %q", to)
+			}
+		}
+		// Ranges may wrap around the week, e.g. fri-mon
+		for d := first; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+// Resolve validates when and turns relative one-shots into dated ones:
+// "tomorrow 07:00", "today 18:00" and "07:00 once" (the next 07:00 after
+// now). Other schedules are returned unchanged.
+func Resolve(when string, now time.Time) (string, error) {
+	fields := strings.Fields(strings.ToLower(when))
+	var day time.Time
+	switch {
+	case len(fields) == 2 && fields[0] == "today":
+		day, fields = now, fields[1:]
+	case len(fields) == 2 && fields[0] == "tomorrow":
+		day, fields = now.AddDate(0, 0, 1), fields[1:]
+	case len(fields) == 2 && fields[1] == "once":
+		s, err := Parse(fields[0])
+		if err != nil {
+			return "", err
+		}
+		next, _ := s.Next(now, nil)
+		return next.Format(dateLayout + " 15:04"), nil
+	default:
+		_, err := Parse(when)
+		return when, err
+	}
+
+	resolved := day.Format(dateLayout) + " " + fields[0]
+	if _, err := Parse(resolved); err != nil {
+		return "", err
+	}
+	return resolved, nil
+}
+
+// Holidays is a set of days alarms can skip.
+type Holidays map[string]bool
+
+// ParseHolidays parses dates as "2026-12-25", or "12-25" for the same day
+// every year.
+func ParseHolidays(dates []string) (Holidays, error) {
+	holidays := make(Holidays, len(dates))
+	for _, date := range dates {
+		if _, err := time.Parse(dateLayout, date); err != nil {
+			if _, err := time.Parse("01-02", date); err != nil {
+				return nil, fmt.Errorf("invalid holiday %q (use YYYY-MM-DD or MM-DD)", date)
+			}
+		}
+		holidays[date] = true
+	}
+	return holidays, nil
+}
+
+// Contains reports whether t falls on a holiday.
+func (h Holidays) Contains(t time.Time) bool {
+	return h[t.Format(dateLayout)] || h[t.Format("01-02")]
+}
+
+// Next returns the first time after after the schedule fires, skipping
+// holidays if given. It returns false for a one-shot that has passed.
+func (s Schedule) Next(after time.Time, holidays Holidays) (time.Time, bool) {
+	at := func(day time.Time) time.Time {
+		return time.Date(day.Year(), day.Month(), day.Day(), s.Hour, s.Minute, 0, 0, after.Location())
+	}
+
+	if s.OneShot() {
+		t := at(s.Date)
+		return t, t.After(after)
+	}
+
+	// A year and a week covers any mix of days and holidays
+	for i := 0; i < 372; i++ {
+		t := at(after.AddDate(0, 0, i))
+		if t.After(after) && s.Days[t.Weekday()] && !holidays.Contains(t) {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// NextFire returns when a configured alarm fires next after after.
+func NextFire(a config.Alarm, holidays Holidays, after time.Time) (time.Time, bool) {
+	if a.Disabled {
+		return time.Time{}, false
+	}
+	s, err := Parse(a.When)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if !a.SkipHolidays {
+		holidays = nil
+	}
+	return s.Next(after, holidays)
+}
+
+// Due returns the indices of the alarms that fire in (from, to], leaving
+// out any that are more than MissedGrace late.
+func Due(alarms []config.Alarm, holidays Holidays, from, to time.Time) []int {
+	var due []int
+	for i, a := range alarms {
+		t, ok := NextFire(a, holidays, from)
+		if ok && !t.After(to) && to.Sub(t) <= MissedGrace {
+			due = append(due, i)
+		}
+	}
+	return due
+}
+
+// Target is what an alarm plays.
+type Target struct {
+	Playlist string // stored playlist name, or
+	URI      string // a single item
+	Service  string
+	Label    string // for notifications
+}
+
+// ParseTarget parses an alarm's play setting: playlist:<name>,
+// preset:<n> (a web radio preset), <uri>|<service> or a bare URI.
+// Stream URLs play as web radio, other URIs from mpd.
+func ParseTarget(play string, presets []config.Preset) (Target, error) {
+	kind, value, _ := strings.Cut(play, ":")
+	switch kind {
+	case "playlist":
+		if value == "" {
+			return Target{}, fmt.Errorf("missing playlist name in %q", play)
+		}
+		return Target{Playlist: value, Label: value}, nil
+	case "preset":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > len(presets) {
+			return Target{}, fmt.Errorf("no web radio preset %q (%d presets)", value, len(presets))
+		}
+		preset := presets[n-1]
+		return Target{URI: preset.URI, Service: "webradio", Label: preset.Name}, nil
+	}
+
+	if play == "" {
+		return Target{}, fmt.Errorf("nothing to play")
+	}
+	uri, service, ok := strings.Cut(play, "|")
+	if !ok {
+		service = "mpd"
+		if strings.HasPrefix(uri, "http://") || strings.HasPrefix(uri, "https://") {
+			service = "webradio"
+		}
+	}
+	return Target{URI: uri, Service: service, Label: uri}, nil
+}
+
+// Player is the part of the Volumio client an alarm needs.
+type Player interface {
+	SetVolume(volume int) error
+	FadeIn(ctx context.Context, d time.Duration, curve volumio.Curve, action func() error) error
+	ReplaceAndPlay(uri, service string) error
+	PlayPlaylist(name string) error
+}
+
+// Fire starts playing the target at the alarm's volume (or the volume
+// before the alarm, if none is set). With a ramp, playback starts from
+// silence and the volume rises linearly over the ramp, going below the
+// client's minimum volume the way transport fades do. If something is
+// already playing, the target replaces it without a ramp.
+func Fire(ctx context.Context, p Player, a config.Alarm, target Target) error {
+	if a.Volume > 0 {
+		if err := p.SetVolume(a.Volume); err != nil {
+			return fmt.Errorf("failed to set volume: %w", err)
+		}
+	}
+
+	play := func() error {
+		var err error
+		if target.Playlist != "" {
+			err = p.PlayPlaylist(target.Playlist)
+		} else {
+			err = p.ReplaceAndPlay(target.URI, target.Service)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to play %s: %w", target.Label, err)
+		}
+		return nil
+	}
+	return p.FadeIn(ctx, a.Ramp, volumio.CurveLinear, play)
+}
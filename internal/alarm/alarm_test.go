@@ -0,0 +1,238 @@
+package alarm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/riclib/volu/internal/config"
+	"github.com/riclib/volu/internal/volumio"
+)
+
+// Friday 2026-10-16, 06:30 local time
+var friday = time.Date(2026, 10, 16, 6, 30, 0, 0, time.Local)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		when    string
+		days    string // weekdays that fire, Sunday first
+		oneShot bool
+		wantErr bool
+	}{
+		{when: "07:00", days: "SMTWTFS"},
+		{when: "07:00 mon-fri", days: ".MTWTF."},
+		{when: "07:00 weekdays", days: ".MTWTF."},
+		{when: "18:30 sat,sun", days: "S.....S"},
+		{when: "18:30 Weekends", days: "S.....S"},
+		{when: "22:00 fri-mon", days: "SM...FS"},
+		{when: "09:00 monday,wed", days: ".M.W..."},
+		{when: "2026-12-24 09:00", oneShot: true},
+		{when: "25:00", wantErr: true},
+		{when: "07:00 funday", wantErr: true},
+		{when: "2026-12-24", wantErr: true},
+		{when: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.when, func(t *testing.T) {
+			s, err := Parse(tt.when)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse(%q) error = %v, wantErr %v", tt.when, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if s.OneShot() != tt.oneShot {
+				t.Errorf("OneShot() = %v, want %v", s.OneShot(), tt.oneShot)
+			}
+			if tt.oneShot {
+				return
+			}
+			got := []byte("SMTWTFS")
+			for d, on := range s.Days {
+				if !on {
+					got[d] = '.'
+				}
+			}
+			if string(got) != tt.days {
+				t.Errorf("days = %s, want %s", got, tt.days)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	holidays, err := ParseHolidays([]string{"2026-10-19", "12-25"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		schedule string
+		after    time.Time
+		holidays Holidays
+		want     time.Time
+		ok       bool
+	}{
+		{"later today", "07:00 mon-fri", friday, nil, friday.Add(30 * time.Minute), true},
+		{"skips weekend", "07:00 mon-fri", friday.Add(time.Hour), nil, time.Date(2026, 10, 19, 7, 0, 0, 0, time.Local), true},
+		{"skips holiday", "07:00 mon-fri", friday.Add(time.Hour), holidays, time.Date(2026, 10, 20, 7, 0, 0, 0, time.Local), true},
+		{"yearly holiday", "07:00", time.Date(2026, 12, 24, 8, 0, 0, 0, time.Local), holidays, time.Date(2026, 12, 26, 7, 0, 0, 0, time.Local), true},
+		{"one-shot", "2026-10-17 09:00", friday, holidays, time.Date(2026, 10, 17, 9, 0, 0, 0, time.Local), true},
+		{"past one-shot", "2026-10-15 09:00", friday, nil, time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.schedule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, ok := s.Next(tt.after, tt.holidays)
+			if ok != tt.ok || (ok && !got.Equal(tt.want)) {
+				t.Errorf("Next() = %v, %v; want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	tests := map[string]string{
+		"tomorrow 07:00": "2026-10-17 07:00",
+		"today 18:00":    "2026-10-16 18:00",
+		"06:00 once":     "2026-10-17 06:00",
+		"07:00 once":     "2026-10-16 07:00",
+		"07:00 mon-fri":  "07:00 mon-fri",
+	}
+	for when, want := range tests {
+		got, err := Resolve(when, friday)
+		if err != nil || got != want {
+			t.Errorf("Resolve(%q) = %q, %v; want %q", when, got, err, want)
+		}
+	}
+	if _, err := Resolve("tomorrow 7am", friday); err == nil {
+		t.Error("expected error for invalid time")
+	}
+}
+
+func TestDue(t *testing.T) {
+	alarms := []config.Alarm{
+		{When: "07:00 mon-fri"},
+		{When: "07:00 mon-fri", Disabled: true},
+		{When: "07:00 sat,sun"},
+		{When: "06:55"},
+	}
+
+	due := Due(alarms, nil, friday, friday.Add(31*time.Minute))
+	if len(due) != 2 || due[0] != 0 || due[1] != 3 {
+		t.Errorf("Due() = %v, want [0 3]", due)
+	}
+
+	// Waking up long after the alarm should not blast music
+	late := Due(alarms, nil, friday, friday.Add(2*time.Hour))
+	if len(late) != 0 {
+		t.Errorf("Due() after 2h = %v, want none", late)
+	}
+}
+
+func TestParseTarget(t *testing.T) {
+	presets := []config.Preset{{Name: "FIP", URI: "http://icecast.radiofrance.fr/fip-hifi.aac"}}
+
+	tests := []struct {
+		play string
+		want Target
+	}{
+		{"playlist:Morning", Target{Playlist: "Morning", Label: "Morning"}},
+		{"preset:1", Target{URI: presets[0].URI, Service: "webradio", Label: "FIP"}},
+		{"http://stream.example.com/office", Target{URI: "http://stream.example.com/office", Service: "webradio", Label: "http://stream.example.com/office"}},
+		{"NAS/Music/Jazz|mpd", Target{URI: "NAS/Music/Jazz", Service: "mpd", Label: "NAS/Music/Jazz"}},
+		{"spotify:playlist:37i9|spop", Target{URI: "spotify:playlist:37i9", Service: "spop", Label: "spotify:playlist:37i9"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseTarget(tt.play, presets)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseTarget(%q) = %+v, %v; want %+v", tt.play, got, err, tt.want)
+		}
+	}
+
+	for _, play := range []string{"", "playlist:", "preset:2"} {
+		if _, err := ParseTarget(play, presets); err == nil {
+			t.Errorf("ParseTarget(%q) expected error", play)
+		}
+	}
+}
+
+// fakeVolumio records volume changes and what was played.
+type fakeVolumio struct {
+	mu       sync.Mutex
+	status   string
+	volume   int
+	volumes  []int
+	playlist string
+	uri      string
+}
+
+func (f *fakeVolumio) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.URL.Path == "/api/v1/replaceAndPlay":
+		var payload map[string]string
+		json.NewDecoder(r.Body).Decode(&payload)
+		f.uri = payload["uri"]
+		f.status = "play"
+	case r.URL.Query().Get("cmd") == "playplaylist":
+		f.playlist = r.URL.Query().Get("name")
+		f.status = "play"
+	case r.URL.Query().Get("cmd") == "volume":
+		f.volume, _ = strconv.Atoi(r.URL.Query().Get("volume"))
+		f.volumes = append(f.volumes, f.volume)
+	}
+	json.NewEncoder(w).Encode(volumio.PlayerState{Status: f.status, Volume: f.volume})
+}
+
+// newPlayer returns a client for fake with a minimum volume of 5.
+func newPlayer(t *testing.T, fake *fakeVolumio) *volumio.Client {
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return volumio.New(volumio.WithBaseURL(server.URL), volumio.WithVolumeLimits(5, 100))
+}
+
+func TestFireRamps(t *testing.T) {
+	fake := &fakeVolumio{status: "stop", volume: 60}
+	a := config.Alarm{Play: "playlist:Morning", Volume: 6, Ramp: 800 * time.Millisecond}
+
+	if err := Fire(context.Background(), newPlayer(t, fake), a, Target{Playlist: "Morning"}); err != nil {
+		t.Fatalf("Fire() error = %v", err)
+	}
+	if fake.playlist != "Morning" {
+		t.Errorf("playlist = %q, want Morning", fake.playlist)
+	}
+	// The alarm volume is set, then the ramp rises from silence, below
+	// the minimum volume
+	if len(fake.volumes) < 3 || fake.volumes[0] != 6 || fake.volumes[1] != 0 || fake.volume != 6 {
+		t.Fatalf("volumes = %v, want 6, then a ramp from 0 to 6", fake.volumes)
+	}
+	for i := 2; i < len(fake.volumes); i++ {
+		if fake.volumes[i] < fake.volumes[i-1] {
+			t.Errorf("volume fell during ramp: %v", fake.volumes)
+		}
+	}
+}
+
+func TestFireWithoutRamp(t *testing.T) {
+	fake := &fakeVolumio{status: "stop", volume: 60}
+	a := config.Alarm{Volume: 15}
+	if err := Fire(context.Background(), newPlayer(t, fake), a, Target{URI: "http://stream.example.com", Service: "webradio"}); err != nil {
+		t.Fatalf("Fire() error = %v", err)
+	}
+	if fake.uri != "http://stream.example.com" || len(fake.volumes) != 1 || fake.volume != 15 {
+		t.Errorf("played %q with volumes %v, want stream at 15", fake.uri, fake.volumes)
+	}
+}
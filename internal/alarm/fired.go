@@ -0,0 +1,96 @@
+package alarm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/riclib/volu/internal/atomicfile"
+	"github.com/riclib/volu/internal/config"
+)
+
+// FiredLog records when one-shot alarms fired. It is kept apart from the
+// config file so the daemon never rewrites the user's config; the alarm
+// commands drop fired one-shots from the config when they next save it.
+type FiredLog struct {
+	Path string
+}
+
+// DefaultFiredLog returns the fired alarm log in $XDG_STATE_HOME (or
+// ~/.local/state).
+func DefaultFiredLog() (*FiredLog, error) {
+	dir, err := config.StateDir()
+	if err != nil {
+		return nil, err
+	}
+	return &FiredLog{Path: filepath.Join(dir, "fired-alarms.json")}, nil
+}
+
+// FiredKey identifies an alarm in the log by its schedule, target and name.
+func FiredKey(a config.Alarm) string {
+	return a.When + "\x00" + a.Play + "\x00" + a.Name
+}
+
+// Load returns when each logged alarm fired, by FiredKey. A missing log is
+// empty.
+func (l *FiredLog) Load() (map[string]time.Time, error) {
+	fired := make(map[string]time.Time)
+	data, err := os.ReadFile(l.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fired, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fired alarms: %w", err)
+	}
+	if err := json.Unmarshal(data, &fired); err != nil {
+		return nil, fmt.Errorf("failed to parse fired alarms: %w", err)
+	}
+	return fired, nil
+}
+
+// Add records that a fired at at.
+func (l *FiredLog) Add(a config.Alarm, at time.Time) error {
+	fired, err := l.Load()
+	if err != nil {
+		fired = make(map[string]time.Time)
+	}
+	fired[FiredKey(a)] = at
+	return l.save(fired)
+}
+
+// Forget removes the alarms that are no longer in alarms from the log.
+func (l *FiredLog) Forget(alarms []config.Alarm) error {
+	fired, err := l.Load()
+	if err != nil {
+		return err
+	}
+	keep := make(map[string]time.Time)
+	for _, a := range alarms {
+		if at, ok := fired[FiredKey(a)]; ok {
+			keep[FiredKey(a)] = at
+		}
+	}
+	if len(keep) == len(fired) {
+		return nil
+	}
+	return l.save(keep)
+}
+
+// save writes the log through a temporary file, so the alarm commands
+// never read half of it.
+func (l *FiredLog) save(fired map[string]time.Time) error {
+	data, err := json.Marshal(fired)
+	if err != nil {
+		return fmt.Errorf("failed to encode fired alarms: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(l.Path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if err := atomicfile.WriteFile(l.Path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write fired alarms: %w", err)
+	}
+	return nil
+}
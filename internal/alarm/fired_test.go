@@ -0,0 +1,42 @@
+package alarm
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/riclib/volu/internal/config"
+)
+
+func TestFiredLog(t *testing.T) {
+	log := &FiredLog{Path: filepath.Join(t.TempDir(), "volu", "fired-alarms.json")}
+
+	fired, err := log.Load()
+	if err != nil || len(fired) != 0 {
+		t.Fatalf("Load() of a missing log = %v, %v; want empty", fired, err)
+	}
+
+	once := config.Alarm{When: "2026-12-24 09:00", Play: "playlist:Xmas"}
+	other := config.Alarm{When: "2026-12-31 23:55", Play: "preset:1"}
+	at := time.Date(2026, 12, 24, 9, 0, 0, 0, time.Local)
+	if err := log.Add(once, at); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+	if err := log.Add(other, at); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+
+	if err := log.Forget([]config.Alarm{other}); err != nil {
+		t.Fatalf("Forget() failed: %v", err)
+	}
+	fired, err = log.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fired[FiredKey(once)]; ok {
+		t.Error("Expected the removed alarm to be forgotten")
+	}
+	if got := fired[FiredKey(other)]; !got.Equal(at) {
+		t.Errorf("Expected the kept alarm fired at %v, got %v", at, got)
+	}
+}
@@ -1,10 +1,13 @@
 package config
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
+	"github.com/riclib/volu/internal/atomicfile"
 	"gopkg.in/yaml.v3"
 )
 
@@ -15,10 +18,129 @@ type RadioSeries struct {
 	Pattern     string `yaml:"pattern"`      // Regex pattern to match album names
 }
 
+// LibraryConfig defines how the local library index is built.
+type LibraryConfig struct {
+	Roots []string `yaml:"roots,omitempty"` // Browse URIs to index (default: music-library)
+}
+
+// Preset is a saved web radio station.
+type Preset struct {
+	Name     string `yaml:"name"`               // Display name (e.g., "Office Radio")
+	URI      string `yaml:"uri"`                // Stream URL
+	AlbumArt string `yaml:"albumart,omitempty"` // Optional logo URL
+}
+
+// WebRadioConfig holds web radio presets, in the order they are numbered.
+type WebRadioConfig struct {
+	Presets []Preset `yaml:"presets,omitempty"`
+}
+
+// VolumeLimits keeps the volume within a range; zero values mean no limit.
+type VolumeLimits struct {
+	MinVolume int `yaml:"min_volume,omitempty"` // Lowest volume volu will set
+	MaxVolume int `yaml:"max_volume,omitempty"` // Highest volume volu will set (safety cap)
+}
+
+// VolumeConfig defines volume steps, limits and how transport commands fade.
+type VolumeConfig struct {
+	Step         int                     `yaml:"step,omitempty"` // Step for volume up/down (default: 10)
+	VolumeLimits `yaml:",inline"`        // Limits for every device
+	Devices      map[string]VolumeLimits `yaml:"devices,omitempty"`       // Per-host limits, overriding the above
+	FadeOnPause  time.Duration           `yaml:"fade_on_pause,omitempty"` // Fade out over this long before pausing or stopping
+	FadeOnPlay   time.Duration           `yaml:"fade_on_play,omitempty"`  // Fade in over this long when playback starts
+	Curve        string                  `yaml:"curve,omitempty"`         // Fade curve: linear (default) or log
+}
+
+// LimitsFor returns the volume limits for host.
+func (v VolumeConfig) LimitsFor(host string) VolumeLimits {
+	limits := v.VolumeLimits
+	if device, ok := v.Devices[host]; ok {
+		if device.MinVolume > 0 {
+			limits.MinVolume = device.MinVolume
+		}
+		if device.MaxVolume > 0 {
+			limits.MaxVolume = device.MaxVolume
+		}
+	}
+	return limits
+}
+
+// NotificationConfig selects how desktop notifications are shown.
+type NotificationConfig struct {
+	Backend string        `yaml:"backend,omitempty"` // dbus (default), notify-send or off
+	Disable []string      `yaml:"disable,omitempty"` // Categories to silence: info, error, volume, track
+	Timeout time.Duration `yaml:"timeout,omitempty"` // How long notifications stay up (default: 2s)
+}
+
+// ListenBrainzConfig holds a ListenBrainz user token.
+type ListenBrainzConfig struct {
+	Token string `yaml:"token,omitempty"` // From https://listenbrainz.org/settings/
+	URL   string `yaml:"url,omitempty"`   // API root (default: https://api.listenbrainz.org)
+}
+
+// LastFMConfig holds Last.fm API credentials and the session key from
+// 'volu scrobble login'.
+type LastFMConfig struct {
+	APIKey     string `yaml:"api_key,omitempty"`
+	APISecret  string `yaml:"api_secret,omitempty"`
+	SessionKey string `yaml:"session_key,omitempty"`
+	URL        string `yaml:"url,omitempty"` // API root (default: https://ws.audioscrobbler.com/2.0/)
+}
+
+// ScrobbleConfig selects where 'volu scrobble' submits listens.
+type ScrobbleConfig struct {
+	ListenBrainz ListenBrainzConfig `yaml:"listenbrainz,omitempty"`
+	LastFM       LastFMConfig       `yaml:"lastfm,omitempty"`
+}
+
+// Hook is a shell command or webhook run on a player event.
+type Hook struct {
+	Command  string            `yaml:"command,omitempty"`  // Run with sh -c; gets the state as VOLU_* variables and JSON on stdin
+	Webhook  string            `yaml:"webhook,omitempty"`  // URL to POST the event to as JSON
+	Template string            `yaml:"template,omitempty"` // Webhook body as a Go template (default: the event JSON)
+	Headers  map[string]string `yaml:"headers,omitempty"`  // Extra webhook headers
+	Timeout  time.Duration     `yaml:"timeout,omitempty"`  // Give up after this long (default: 30s)
+}
+
+// ConnectionConfig tunes how volu talks to Volumio.
+type ConnectionConfig struct {
+	Timeout          time.Duration `yaml:"timeout,omitempty"`           // Per request attempt, including the response (default: 10s)
+	ConnectTimeout   time.Duration `yaml:"connect_timeout,omitempty"`   // Looking up and connecting to the player (default: 2s)
+	Retries          int           `yaml:"retries,omitempty"`           // Retries of requests that are safe to repeat (default: 2; -1 disables)
+	BreakerThreshold int           `yaml:"breaker_threshold,omitempty"` // Failed requests in a row before failing fast (default: 3; -1 disables)
+	BreakerCooldown  time.Duration `yaml:"breaker_cooldown,omitempty"`  // How long to fail fast before trying again (default: 30s)
+}
+
+// Alarm is scheduled playback run by 'volu daemon'.
+type Alarm struct {
+	Name         string        `yaml:"name,omitempty"`          // Optional label (e.g., "Office opening")
+	When         string        `yaml:"when"`                    // "07:00 mon-fri", "07:00" or "2026-12-24 09:00"
+	Play         string        `yaml:"play"`                    // playlist:<name>, preset:<n> or a URI
+	Volume       int           `yaml:"volume,omitempty"`        // Volume to play at (default: unchanged)
+	Ramp         time.Duration `yaml:"ramp,omitempty"`          // Fade in from silence over this long
+	SkipHolidays bool          `yaml:"skip_holidays,omitempty"` // Don't fire on schedule.holidays
+	Disabled     bool          `yaml:"disabled,omitempty"`
+}
+
+// ScheduleConfig holds the alarms and the holidays they may skip.
+type ScheduleConfig struct {
+	Alarms   []Alarm  `yaml:"alarms,omitempty"`
+	Holidays []string `yaml:"holidays,omitempty"` // "2026-12-25", or "12-25" for every year
+}
+
 // Config represents the volu configuration file structure.
 type Config struct {
-	Host  string                 `yaml:"host"`  // Volumio host (hostname or IP)
-	Radio map[string]RadioSeries `yaml:"radio"` // Radio series configurations
+	Host     string                 `yaml:"host"`     // Volumio host (hostname or IP)
+	Radio    map[string]RadioSeries `yaml:"radio"`    // Radio series configurations
+	Library  LibraryConfig          `yaml:"library"`  // Local library index settings
+	WebRadio WebRadioConfig         `yaml:"webradio"` // Web radio presets
+	Schedule ScheduleConfig         `yaml:"schedule"` // Alarms run by 'volu daemon'
+	Volume   VolumeConfig           `yaml:"volume"`   // Volume step, limits and fades
+
+	Notifications NotificationConfig `yaml:"notifications"` // Desktop notifications
+	Scrobble      ScrobbleConfig     `yaml:"scrobble"`      // ListenBrainz and Last.fm accounts
+	Hooks         map[string][]Hook  `yaml:"hooks"`         // Commands and webhooks per player event, run by 'volu hooks run'
+	Connection    ConnectionConfig   `yaml:"connection"`    // Timeouts, retries and circuit breaker
 }
 
 // DefaultConfig returns a Config with default values.
@@ -39,6 +161,20 @@ func GetConfigPath() (string, error) {
 	return filepath.Join(configDir, "volu", "config.yaml"), nil
 }
 
+// StateDir returns volu's directory for state it keeps between runs, in
+// $XDG_STATE_HOME (or ~/.local/state).
+func StateDir() (string, error) {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home directory: %w", err)
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "volu"), nil
+}
+
 // Load reads the config file and returns a Config.
 // If the config file doesn't exist, returns default config.
 // Returns error only for actual read/parse failures.
@@ -72,27 +208,105 @@ func Load() (*Config, error) {
 	return cfg, nil
 }
 
-// Save writes the config to the config file.
+// Save writes the config to the config file. Comments and key order in
+// an existing file are kept, and so is its mode.
 func Save(cfg *Config) error {
 	configPath, err := GetConfigPath()
 	if err != nil {
 		return fmt.Errorf("failed to get config path: %w", err)
 	}
+	return saveFile(configPath, cfg, 0644)
+}
 
+// saveFile writes cfg to path through a temporary file, so a failed write
+// never leaves a truncated config behind. A new file gets mode.
+func saveFile(path string, cfg *Config, mode os.FileMode) error {
 	// Create directory if it doesn't exist
-	configDir := filepath.Dir(configPath)
+	configDir := filepath.Dir(path)
 	if err := os.MkdirAll(configDir, 0755); err != nil {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
-	data, err := yaml.Marshal(cfg)
-	if err != nil {
+	var doc yaml.Node
+	if err := doc.Encode(cfg); err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+		if data, err := os.ReadFile(path); err == nil {
+			var old yaml.Node
+			if yaml.Unmarshal(data, &old) == nil && len(old.Content) == 1 {
+				mergeNode(old.Content[0], &doc)
+				doc = old
+			}
+		}
+	}
 
-	if err := os.WriteFile(configPath, data, 0644); err != nil {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	if err := atomicfile.WriteFile(path, buf.Bytes(), mode); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
 
 	return nil
 }
+
+// mergeNode updates dst to hold the values of src while keeping dst's
+// comments, quoting and key order. Keys and list items missing from src
+// are dropped and new ones appended.
+func mergeNode(dst, src *yaml.Node) {
+	if dst.Kind == yaml.SequenceNode && src.Kind == yaml.SequenceNode {
+		for i, item := range src.Content {
+			if i < len(dst.Content) {
+				mergeNode(dst.Content[i], item)
+			} else {
+				dst.Content = append(dst.Content, item)
+			}
+		}
+		dst.Content = dst.Content[:len(src.Content)]
+		return
+	}
+	if dst.Kind != yaml.MappingNode || src.Kind != yaml.MappingNode {
+		head, line, foot := dst.HeadComment, dst.LineComment, dst.FootComment
+		if dst.Kind == yaml.ScalarNode && src.Kind == yaml.ScalarNode && dst.ShortTag() == src.ShortTag() {
+			src.Style = dst.Style // keep quoting
+		}
+		*dst = *src
+		dst.HeadComment, dst.LineComment, dst.FootComment = head, line, foot
+		return
+	}
+
+	values := make(map[string]*yaml.Node, len(src.Content)/2)
+	var order []string
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		values[src.Content[i].Value] = src.Content[i+1]
+		order = append(order, src.Content[i].Value)
+	}
+
+	var content []*yaml.Node
+	kept := make(map[string]bool)
+	for i := 0; i+1 < len(dst.Content); i += 2 {
+		key, value := dst.Content[i], dst.Content[i+1]
+		next, ok := values[key.Value]
+		if !ok {
+			continue
+		}
+		mergeNode(value, next)
+		content = append(content, key, value)
+		kept[key.Value] = true
+	}
+	for i, key := range order {
+		if !kept[key] {
+			content = append(content, src.Content[2*i], src.Content[2*i+1])
+		}
+	}
+	dst.Content = content
+}
@@ -1,7 +1,11 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -108,3 +112,164 @@ func TestRadioSeriesStruct(t *testing.T) {
 		t.Errorf("Expected pattern '^test\\d+', got '%s'", series.Pattern)
 	}
 }
+
+func TestWebRadioPresets(t *testing.T) {
+	data := []byte(`
+webradio:
+  presets:
+    - name: Office Radio
+      uri: http://stream.example.com/office
+    - name: FIP
+      uri: https://icecast.radiofrance.fr/fip-hifi.aac
+      albumart: https://example.com/fip.png
+`)
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+
+	presets := cfg.WebRadio.Presets
+	if len(presets) != 2 {
+		t.Fatalf("Expected 2 presets, got %d", len(presets))
+	}
+	if presets[0].Name != "Office Radio" || presets[0].AlbumArt != "" {
+		t.Errorf("Unexpected first preset: %+v", presets[0])
+	}
+	if presets[1].AlbumArt != "https://example.com/fip.png" {
+		t.Errorf("Expected albumart on second preset, got %+v", presets[1])
+	}
+}
+
+func TestScheduleAlarms(t *testing.T) {
+	data := []byte(`
+schedule:
+  alarms:
+    - name: Office opening
+      when: 07:00 mon-fri
+      play: playlist:Morning
+      volume: 15
+      ramp: 10m
+      skip_holidays: true
+  holidays:
+    - "2026-12-24"
+    - "12-25"
+`)
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+
+	alarms := cfg.Schedule.Alarms
+	if len(alarms) != 1 {
+		t.Fatalf("Expected 1 alarm, got %d", len(alarms))
+	}
+	if alarms[0].Ramp != 10*time.Minute || alarms[0].Volume != 15 || !alarms[0].SkipHolidays {
+		t.Errorf("Unexpected alarm: %+v", alarms[0])
+	}
+	if len(cfg.Schedule.Holidays) != 2 {
+		t.Errorf("Expected 2 holidays, got %v", cfg.Schedule.Holidays)
+	}
+
+	// Durations must survive a save
+	out, err := yaml.Marshal(&cfg)
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+	var reloaded Config
+	if err := yaml.Unmarshal(out, &reloaded); err != nil || reloaded.Schedule.Alarms[0].Ramp != 10*time.Minute {
+		t.Errorf("Ramp lost in round trip: %s", out)
+	}
+}
+
+func TestVolumeLimitsFor(t *testing.T) {
+	data := []byte(`
+volume:
+  step: 5
+  max_volume: 80
+  min_volume: 5
+  devices:
+    office.local:
+      max_volume: 40
+`)
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+	if cfg.Volume.Step != 5 {
+		t.Errorf("Expected step 5, got %d", cfg.Volume.Step)
+	}
+	if got := cfg.Volume.LimitsFor("office.local"); got.MaxVolume != 40 || got.MinVolume != 5 {
+		t.Errorf("Unexpected office limits: %+v", got)
+	}
+	if got := cfg.Volume.LimitsFor("volumio.local"); got.MaxVolume != 80 || got.MinVolume != 5 {
+		t.Errorf("Unexpected default limits: %+v", got)
+	}
+}
+
+func TestSaveKeepsCommentsAndMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := []byte(`# Volumio on the living room Pi
+host: pi.local
+
+webradio:
+  # Stations for the office
+  presets:
+    - name: FIP
+      uri: https://icecast.radiofrance.fr/fip-hifi.aac
+`)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := DefaultConfig()
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		t.Fatal(err)
+	}
+	cfg.WebRadio.Presets = append(cfg.WebRadio.Presets, Preset{Name: "Office Radio", URI: "http://stream.example.com/office"})
+	if err := saveFile(path, cfg, 0644); err != nil {
+		t.Fatalf("saveFile() failed: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600 to be kept, got %o", info.Mode().Perm())
+	}
+
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, comment := range []string{"# Volumio on the living room Pi", "# Stations for the office"} {
+		if !strings.Contains(string(saved), comment) {
+			t.Errorf("Expected %q to be kept, got:\n%s", comment, saved)
+		}
+	}
+
+	var loaded Config
+	if err := yaml.Unmarshal(saved, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Host != "pi.local" || len(loaded.WebRadio.Presets) != 2 || loaded.WebRadio.Presets[1].Name != "Office Radio" {
+		t.Errorf("Unexpected saved config: %+v", loaded)
+	}
+}
+
+func TestSaveNewFileMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "volu", "config.yaml")
+	if err := saveFile(path, DefaultConfig(), 0644); err != nil {
+		t.Fatalf("saveFile() failed: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("Expected mode 0644, got %o", info.Mode().Perm())
+	}
+}
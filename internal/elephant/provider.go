@@ -1,34 +1,67 @@
 package elephant
 
 import (
+	"bufio"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/riclib/volu/internal/launcher"
 	"github.com/riclib/volu/internal/volumio"
 )
 
-// Provider implements the Elephant provider interface for Volumio
+// DefaultRefreshInterval is how often the provider polls Volumio for state changes.
+const DefaultRefreshInterval = 2 * time.Second
+
+// MinSearchLength is the shortest query that triggers a live library search.
+const MinSearchLength = 3
+
+// Provider serves volu's launcher menus to Elephant through an
+// experimental bridge. It speaks its own JSON-lines protocol, not
+// Elephant's provider protocol, so a peer has to translate between them
 type Provider struct {
-	client *volumio.Client
+	client volumio.Player
+	router *launcher.Router
+
+	// RefreshInterval controls state polling while serving. Zero disables polling.
+	RefreshInterval time.Duration
+
+	mu     sync.Mutex
+	state  *volumio.PlayerState
+	path   []string                   // view stack, innermost view action last
+	browse map[string][]launcher.Item // view menus by action
+	search map[string][]launcher.Item // search results by query
+
+	writeMu sync.Mutex
+	encoder *json.Encoder
 }
 
-// NewProvider creates a new Elephant provider
-func NewProvider(host string) *Provider {
+// NewProvider creates a new Elephant provider for the given player
+func NewProvider(client volumio.Player) *Provider {
 	return &Provider{
-		client: volumio.NewClientWithHost(host),
+		client:          client,
+		router:          launcher.NewRouter(client),
+		RefreshInterval: DefaultRefreshInterval,
 	}
 }
 
 // Entry represents an Elephant entry
 type Entry struct {
-	Label       string   `json:"label"`
-	Sub         string   `json:"sub,omitempty"`
-	Exec        string   `json:"exec,omitempty"`
-	Image       string   `json:"image,omitempty"`
-	Categories  []string `json:"categories,omitempty"`
-	Searchable  bool     `json:"searchable"`
-	Piped       string   `json:"piped,omitempty"`
+	Label      string   `json:"label"`
+	Sub        string   `json:"sub,omitempty"`
+	Exec       string   `json:"exec,omitempty"`
+	Image      string   `json:"image,omitempty"`
+	Categories []string `json:"categories,omitempty"`
+	Searchable bool     `json:"searchable"`
+	Piped      string   `json:"piped,omitempty"`
+
+	// Actions maps alternative action names (e.g. "play", "queue") to the
+	// identifiers to activate for them.
+	Actions map[string]string `json:"actions,omitempty"`
 }
 
 // Response represents an Elephant provider response
@@ -36,209 +69,361 @@ type Response struct {
 	Entries []Entry `json:"entries"`
 }
 
-// Run starts the Elephant provider
+// Message types exchanged with the bridge peer. Every message is a single
+// JSON object on its own line. This framing is volu's own; Elephant does
+// not speak it.
+const (
+	MsgQuery     = "query"     // peer → provider: list entries matching Query
+	MsgActivate  = "activate"  // peer → provider: run the entry's Identifier
+	MsgRefresh   = "refresh"   // both ways: re-fetch state / results are stale
+	MsgPing      = "ping"      // peer → provider: liveness check
+	MsgPong      = "pong"      // provider → peer: reply to ping
+	MsgItem      = "item"      // provider → peer: one result of a query
+	MsgDone      = "done"      // provider → peer: query finished
+	MsgActivated = "activated" // provider → peer: activation succeeded
+	MsgError     = "error"     // provider → peer: query or activation failed
+)
+
+// Request is a message received from the bridge peer.
+type Request struct {
+	Type       string `json:"type"`
+	QID        int    `json:"qid,omitempty"`
+	Query      string `json:"query,omitempty"`
+	Identifier string `json:"identifier,omitempty"`
+
+	// Piped is accepted for compatibility with the old one-shot protocol,
+	// where a selected entry was sent as {"piped": "..."}.
+	Piped string `json:"piped,omitempty"`
+}
+
+// Message is a message sent to the bridge peer.
+type Message struct {
+	Type  string `json:"type"`
+	QID   int    `json:"qid,omitempty"`
+	Entry *Entry `json:"entry,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// Run serves the provider protocol over stdin and stdout until stdin is closed.
+// Note: Do not log to stdout as it will break JSON parsing
 func (p *Provider) Run() error {
-	// Elephant providers work by reading JSON from stdin and outputting JSON to stdout
-	// Note: Do not log to stdout as it will break JSON parsing
+	return p.Serve(os.Stdin, os.Stdout)
+}
 
-	// Check if stdin has data available
-	stat, err := os.Stdin.Stat()
-	if err != nil {
-		return p.ShowMainMenu()
+// Serve runs the long-lived provider loop, reading requests from r and
+// writing results to w until r reaches EOF.
+//
+// Queries are answered from a cached player state so typing in the launcher
+// never waits on Volumio. The cache is refreshed after every activation and
+// polled every RefreshInterval; when it changes, a refresh message tells the
+// peer to re-run its query.
+func (p *Provider) Serve(r io.Reader, w io.Writer) error {
+	p.writeMu.Lock()
+	p.encoder = json.NewEncoder(w)
+	p.writeMu.Unlock()
+
+	p.refreshState()
+
+	done := make(chan struct{})
+	defer close(done)
+	if p.RefreshInterval > 0 {
+		go p.watch(done)
 	}
 
-	// If no pipe/input, show main menu immediately
-	if (stat.Mode() & os.ModeCharDevice) != 0 {
-		return p.ShowMainMenu()
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var req Request
+		if err := json.Unmarshal([]byte(line), &req); err != nil {
+			p.send(Message{Type: MsgError, Error: fmt.Sprintf("invalid request: %v", err)})
+			continue
+		}
+		if err := p.handle(req); err != nil {
+			return err
+		}
 	}
 
-	// Read input from stdin
-	var input map[string]interface{}
-	decoder := json.NewDecoder(os.Stdin)
-	if err := decoder.Decode(&input); err != nil {
-		// Invalid input - show main menu
-		return p.ShowMainMenu()
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read request: %w", err)
 	}
+	return nil
+}
 
-	// Handle piped input (selected item)
-	if piped, ok := input["piped"].(string); ok {
-		return p.HandleAction(piped)
+// handle dispatches a single request. Only write failures are returned,
+// since they mean the peer is gone.
+func (p *Provider) handle(req Request) error {
+	if req.Type == "" && req.Piped != "" {
+		req.Type = MsgActivate
+		req.Identifier = req.Piped
 	}
 
-	return p.ShowMainMenu()
+	switch req.Type {
+	case MsgQuery:
+		for _, entry := range p.Query(req.Query) {
+			entry := entry
+			if err := p.send(Message{Type: MsgItem, QID: req.QID, Entry: &entry}); err != nil {
+				return err
+			}
+		}
+		return p.send(Message{Type: MsgDone, QID: req.QID})
+
+	case MsgActivate:
+		if err := p.HandleAction(req.Identifier); err != nil {
+			return p.send(Message{Type: MsgError, QID: req.QID, Error: err.Error()})
+		}
+		if err := p.send(Message{Type: MsgActivated, QID: req.QID}); err != nil {
+			return err
+		}
+		// Navigation always changes the results; transport actions only
+		// when they change the player state
+		a, _ := launcher.ParseAction(req.Identifier)
+		if p.refreshState() || a.IsView() || a.Kind == launcher.KindNav {
+			return p.send(Message{Type: MsgRefresh})
+		}
+		return nil
+
+	case MsgRefresh:
+		p.refreshState()
+		p.mu.Lock()
+		p.browse = nil
+		p.search = nil
+		p.mu.Unlock()
+		return p.send(Message{Type: MsgRefresh})
+
+	case MsgPing:
+		return p.send(Message{Type: MsgPong, QID: req.QID})
+
+	default:
+		return p.send(Message{Type: MsgError, QID: req.QID, Error: fmt.Sprintf("unknown request type: %q", req.Type)})
+	}
 }
 
-// ShowMainMenu outputs the main menu entries
-func (p *Provider) ShowMainMenu() error {
+// send writes a message to the peer.
+func (p *Provider) send(msg Message) error {
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+	if err := p.encoder.Encode(msg); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	return nil
+}
+
+// watch polls the player state and notifies the peer when it changes.
+func (p *Provider) watch(done <-chan struct{}) {
+	ticker := time.NewTicker(p.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if p.refreshState() {
+				p.send(Message{Type: MsgRefresh})
+			}
+		}
+	}
+}
+
+// refreshState re-fetches the player state and reports whether it changed.
+// A failed fetch clears the cached state.
+func (p *Provider) refreshState() bool {
 	state, err := p.client.GetState()
 	if err != nil {
-		// Silently handle error - just show menu without state
 		state = nil
 	}
 
-	entries := []Entry{}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	changed := stateKey(p.state) != stateKey(state)
+	p.state = state
+	return changed
+}
 
-	// Now playing section
-	if state != nil && state.Title != "" {
-		statusIcon := "▶"
-		if state.Status == "pause" {
-			statusIcon = "⏸"
-		} else if state.Status == "stop" {
-			statusIcon = "⏹"
-		}
+// cachedState returns the last fetched player state, or nil.
+func (p *Provider) cachedState() *volumio.PlayerState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state
+}
+
+// stateKey summarises the parts of the state that appear in the menu.
+func stateKey(state *volumio.PlayerState) string {
+	if state == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s|%s|%s|%s|%d|%t|%t|%t",
+		state.Status, state.Title, state.Artist, state.Album,
+		state.Volume, state.Mute, state.Random, state.Repeat)
+}
+
+// Query returns the entries matching query.
+//
+// At the top level this is the main menu, followed by live library search
+// results once the query is at least MinSearchLength characters long.
+// While browsing, it is the current folder's contents. An empty query
+// returns everything; otherwise only searchable entries whose label or
+// subtitle contain every word of the query are returned.
+func (p *Provider) Query(query string) []Entry {
+	p.mu.Lock()
+	location, browsing := "", len(p.path) > 0
+	if browsing {
+		location = p.path[len(p.path)-1]
+	}
+	p.mu.Unlock()
 
-		artist := state.Artist
-		if artist == "" {
-			artist = "Unknown"
+	if browsing {
+		items, err := p.viewItems(location)
+		if err != nil {
+			items = []launcher.Item{{Label: "Could not browse", Sub: err.Error(), Icon: "❌", Action: "nav:back"}}
 		}
+		return Render(launcher.Filter(items, query))
+	}
+
+	items := launcher.Filter(launcher.MainMenu(p.cachedState(), p.router.MenuOptions()), query)
+	if len(strings.TrimSpace(query)) >= MinSearchLength {
+		items = append(items, p.searchItems(strings.TrimSpace(query))...)
+	}
+	return Render(items)
+}
+
+// viewItems builds the menu for a view action (a folder, an item's
+// actions or a playlist picker), using the cache when possible.
+func (p *Provider) viewItems(view string) ([]launcher.Item, error) {
+	p.mu.Lock()
+	items, ok := p.browse[view]
+	p.mu.Unlock()
+	if ok {
+		return items, nil
+	}
+
+	a, err := launcher.ParseAction(view)
+	if err != nil {
+		return nil, err
+	}
+	items, err = p.router.View(a)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.browse == nil {
+		p.browse = make(map[string][]launcher.Item)
+	}
+	p.browse[view] = items
+	return items, nil
+}
+
+// searchItems runs a live library search for query. Results are cached
+// per query; failures yield no items so the menu still works offline.
+func (p *Provider) searchItems(query string) []launcher.Item {
+	p.mu.Lock()
+	cached, ok := p.search[query]
+	p.mu.Unlock()
+	if ok {
+		return cached
+	}
+
+	items, err := p.router.Search(query)
+	if err != nil {
+		return nil
+	}
 
-		entries = append(entries, Entry{
-			Label:      fmt.Sprintf("%s %s - %s", statusIcon, artist, state.Title),
-			Sub:        fmt.Sprintf("%s | Vol: %d%%", state.Album, state.Volume),
-			Searchable: false,
-			Piped:      "action:toggle",
-		})
-
-		entries = append(entries, Entry{
-			Label:      "────────────────────────",
-			Searchable: false,
-		})
-	}
-
-	// Playback controls
-	entries = append(entries,
-		Entry{
-			Label:      "▶️ Play / Pause",
-			Sub:        "Toggle playback",
-			Searchable: true,
-			Piped:      "action:toggle",
-		},
-		Entry{
-			Label:      "⏭️ Next Track",
-			Sub:        "Skip to next track",
-			Searchable: true,
-			Piped:      "action:next",
-		},
-		Entry{
-			Label:      "⏮️ Previous Track",
-			Sub:        "Go to previous track",
-			Searchable: true,
-			Piped:      "action:prev",
-		},
-		Entry{
-			Label:      "⏹️ Stop",
-			Sub:        "Stop playback",
-			Searchable: true,
-			Piped:      "action:stop",
-		},
-	)
-
-	entries = append(entries, Entry{
-		Label:      "────────────────────────",
-		Searchable: false,
-	})
-
-	// Volume controls
-	if state != nil {
-		entries = append(entries,
-			Entry{
-				Label:      fmt.Sprintf("🔊 Volume: %d%%", state.Volume),
-				Sub:        "Current volume",
-				Searchable: false,
-			},
-			Entry{
-				Label:      "🔊 Volume Up",
-				Sub:        "Increase volume (+10%)",
-				Searchable: true,
-				Piped:      "action:volup",
-			},
-			Entry{
-				Label:      "🔉 Volume Down",
-				Sub:        "Decrease volume (-10%)",
-				Searchable: true,
-				Piped:      "action:voldown",
-			},
-			Entry{
-				Label:      "🔇 Toggle Mute",
-				Sub:        "Mute/unmute audio",
-				Searchable: true,
-				Piped:      "action:mute",
-			},
-		)
-	}
-
-	entries = append(entries, Entry{
-		Label:      "────────────────────────",
-		Searchable: false,
-	})
-
-	// Playback modes
-	shuffleStatus := "OFF"
-	repeatStatus := "OFF"
-	if state != nil {
-		if state.Random {
-			shuffleStatus = "ON"
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.search == nil {
+		p.search = make(map[string][]launcher.Item)
+	}
+	p.search[query] = items
+	return items
+}
+
+// Render converts launcher menu items into Elephant entries
+func Render(items []launcher.Item) []Entry {
+	entries := make([]Entry, 0, len(items))
+	for _, item := range items {
+		entry := Entry{
+			Label:      item.Text(),
+			Sub:        item.Sub,
+			Image:      item.Image,
+			Searchable: item.Searchable,
+			Piped:      item.Action,
+			Actions:    item.Actions,
 		}
-		if state.Repeat {
-			repeatStatus = "ON"
+		if item.Category != "" {
+			entry.Categories = []string{item.Category}
 		}
+		entries = append(entries, entry)
 	}
+	return entries
+}
+
+// MainMenu builds the main menu entries for the given state, which may be nil
+func MainMenu(state *volumio.PlayerState, opts launcher.MenuOptions) []Entry {
+	return Render(launcher.MainMenu(state, opts))
+}
+
+// UseTransportFade sets how play, pause, stop and toggle fade.
+func (p *Provider) UseTransportFade(fade volumio.TransportFade) {
+	p.router.Fade = fade
+}
+
+// UseVolumeStep sets the volume up/down step.
+func (p *Provider) UseVolumeStep(step int) {
+	p.router.VolumeStep = step
+}
+
+// UsePresets lists web radio presets in the main menu.
+func (p *Provider) UsePresets(presets []launcher.Preset) {
+	p.router.Presets = presets
+}
 
-	entries = append(entries,
-		Entry{
-			Label:      fmt.Sprintf("🔀 Shuffle: %s", shuffleStatus),
-			Sub:        "Toggle shuffle mode",
-			Searchable: true,
-			Piped:      "action:shuffle",
-		},
-		Entry{
-			Label:      fmt.Sprintf("🔁 Repeat: %s", repeatStatus),
-			Sub:        "Toggle repeat mode",
-			Searchable: true,
-			Piped:      "action:repeat",
-		},
-	)
-
-	response := Response{Entries: entries}
+// ShowMainMenu outputs the main menu entries as a single JSON response
+func (p *Provider) ShowMainMenu() error {
+	p.refreshState()
+	response := Response{Entries: MainMenu(p.cachedState(), p.router.MenuOptions())}
 	return json.NewEncoder(os.Stdout).Encode(response)
 }
 
 // HandleAction handles an action from a selected entry
 func (p *Provider) HandleAction(action string) error {
-	// Parse action type
-	if len(action) > 7 && action[:7] == "action:" {
-		cmd := action[7:]
-		return p.executeAction(cmd)
-	}
-
-	return fmt.Errorf("unknown action: %s", action)
-}
-
-func (p *Provider) executeAction(cmd string) error {
-	switch cmd {
-	case "toggle":
-		return p.client.TogglePlayPause()
-	case "play":
-		return p.client.Play()
-	case "pause":
-		return p.client.Pause()
-	case "stop":
-		return p.client.Stop()
-	case "next":
-		return p.client.Next()
-	case "prev":
-		return p.client.Previous()
-	case "volup":
-		return p.client.VolumeUp(10)
-	case "voldown":
-		return p.client.VolumeDown(10)
-	case "mute":
-		return p.client.ToggleMute()
-	case "shuffle":
-		return p.client.ToggleRandom()
-	case "repeat":
-		return p.client.ToggleRepeat()
-	default:
-		return fmt.Errorf("unknown command: %s", cmd)
+	a, err := launcher.ParseAction(action)
+	if err != nil {
+		return err
 	}
+
+	if a.IsView() {
+		if _, err := p.viewItems(a.String()); err != nil {
+			return err
+		}
+		p.mu.Lock()
+		p.path = append(p.path, a.String())
+		p.mu.Unlock()
+		return nil
+	}
+
+	switch a.Kind {
+	case launcher.KindNav:
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		switch a.Value {
+		case "back":
+			if len(p.path) > 0 {
+				p.path = p.path[:len(p.path)-1]
+			}
+		case "home":
+			p.path = nil
+		default:
+			return fmt.Errorf("unknown action: %s", action)
+		}
+		return nil
+	}
+
+	return p.router.Run(a)
 }
@@ -0,0 +1,351 @@
+package elephant
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/riclib/volu/internal/volumio"
+)
+
+// fakeVolumio serves getState, browse and search, and records commands.
+type fakeVolumio struct {
+	mu       sync.Mutex
+	state    volumio.PlayerState
+	commands []string
+	library  map[string][]volumio.BrowseItem
+	searches int
+}
+
+func (f *fakeVolumio) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/api/v1/getState":
+		json.NewEncoder(w).Encode(f.state)
+	case "/api/v1/commands/":
+		cmd := r.URL.Query().Get("cmd")
+		f.commands = append(f.commands, cmd)
+		if cmd == "toggle" {
+			if f.state.Status == "play" {
+				f.state.Status = "pause"
+			} else {
+				f.state.Status = "play"
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]string{"response": "ok"})
+	case "/api/v1/browse":
+		items, ok := f.library[r.URL.Query().Get("uri")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"navigation": map[string]interface{}{
+				"lists": []interface{}{map[string]interface{}{"items": items}},
+			},
+		})
+	case "/api/v1/search":
+		f.searches++
+		var response volumio.SearchResponse
+		if !strings.Contains(r.URL.Query().Get("query"), "daft") {
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+		response.Navigation.Lists = []volumio.SearchList{
+			{Title: "Artists", Items: []volumio.BrowseItem{{URI: "artists://Daft Punk", Title: "Daft Punk", Type: "folder", Service: "mpd"}}},
+			{Title: "Tracks", Items: []volumio.BrowseItem{{URI: "mnt/NAS/one.flac", Title: "One More Time", Type: "song", Service: "mpd", Artist: "Daft Punk"}}},
+		}
+		json.NewEncoder(w).Encode(response)
+	case "/api/v1/replaceAndPlay", "/api/v1/addToQueue":
+		var payload map[string]string
+		json.NewDecoder(r.Body).Decode(&payload)
+		f.commands = append(f.commands, r.URL.Path[len("/api/v1/"):]+" "+payload["uri"]+" "+payload["service"])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// fakePeer plays the peer side of the bridge protocol.
+type fakePeer struct {
+	t   *testing.T
+	in  *io.PipeWriter
+	dec *json.Decoder
+}
+
+func startProvider(t *testing.T, fake *fakeVolumio, interval time.Duration) (*fakePeer, <-chan error) {
+	t.Helper()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	provider := NewProvider(volumio.NewClient(server.URL))
+	provider.RefreshInterval = interval
+
+	reqR, reqW := io.Pipe()
+	respR, respW := io.Pipe()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- provider.Serve(reqR, respW)
+		respW.Close()
+	}()
+
+	return &fakePeer{t: t, in: reqW, dec: json.NewDecoder(respR)}, errc
+}
+
+func (p *fakePeer) send(req Request) {
+	p.t.Helper()
+	if err := json.NewEncoder(p.in).Encode(req); err != nil {
+		p.t.Fatalf("send: %v", err)
+	}
+}
+
+func (p *fakePeer) recv() Message {
+	p.t.Helper()
+	var msg Message
+	if err := p.dec.Decode(&msg); err != nil {
+		p.t.Fatalf("recv: %v", err)
+	}
+	return msg
+}
+
+// collect reads item messages until done for the given query id.
+func (p *fakePeer) collect(qid int) []Entry {
+	p.t.Helper()
+	var entries []Entry
+	for {
+		msg := p.recv()
+		switch msg.Type {
+		case MsgItem:
+			if msg.QID != qid {
+				p.t.Fatalf("item for qid %d, want %d", msg.QID, qid)
+			}
+			entries = append(entries, *msg.Entry)
+		case MsgDone:
+			return entries
+		case MsgRefresh:
+			// Unsolicited refreshes may interleave with results
+		default:
+			p.t.Fatalf("unexpected message %+v", msg)
+		}
+	}
+}
+
+func TestServeQuery(t *testing.T) {
+	fake := &fakeVolumio{state: volumio.PlayerState{Status: "play", Title: "Song", Artist: "Artist", Volume: 40}}
+	peer, errc := startProvider(t, fake, 0)
+
+	peer.send(Request{Type: MsgQuery, QID: 1})
+	all := peer.collect(1)
+	if len(all) == 0 || all[0].Label != "▶ Now Playing: Artist - Song" {
+		t.Fatalf("empty query returned %+v", all)
+	}
+
+	peer.send(Request{Type: MsgQuery, QID: 2, Query: "next"})
+	matches := peer.collect(2)
+	if len(matches) != 1 || matches[0].Piped != "action:next" {
+		t.Errorf("query 'next' returned %+v", matches)
+	}
+
+	peer.send(Request{Type: MsgQuery, QID: 3, Query: "volume up"})
+	matches = peer.collect(3)
+	if len(matches) != 1 || matches[0].Piped != "action:volup" {
+		t.Errorf("query 'volume up' returned %+v", matches)
+	}
+
+	peer.in.Close()
+	if err := <-errc; err != nil {
+		t.Errorf("Serve() error = %v", err)
+	}
+}
+
+func TestServeActivate(t *testing.T) {
+	fake := &fakeVolumio{state: volumio.PlayerState{Status: "play", Title: "Song"}}
+	peer, errc := startProvider(t, fake, 0)
+
+	peer.send(Request{Type: MsgActivate, QID: 7, Identifier: "action:toggle"})
+	if msg := peer.recv(); msg.Type != MsgActivated || msg.QID != 7 {
+		t.Fatalf("got %+v, want activated", msg)
+	}
+	// The toggle changed the state, so the peer is told to re-query
+	if msg := peer.recv(); msg.Type != MsgRefresh {
+		t.Fatalf("got %+v, want refresh", msg)
+	}
+
+	peer.send(Request{Type: MsgQuery, QID: 8})
+	entries := peer.collect(8)
+	if entries[0].Label != "⏸ Now Playing: Unknown - Song" {
+		t.Errorf("state not refreshed after activation: %q", entries[0].Label)
+	}
+
+	peer.send(Request{Type: MsgActivate, QID: 9, Identifier: "bogus"})
+	if msg := peer.recv(); msg.Type != MsgError || msg.QID != 9 {
+		t.Errorf("got %+v, want error", msg)
+	}
+
+	// Legacy one-shot payload
+	peer.send(Request{Piped: "action:next"})
+	if msg := peer.recv(); msg.Type != MsgActivated {
+		t.Errorf("got %+v, want activated", msg)
+	}
+
+	peer.in.Close()
+	<-errc
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if len(fake.commands) != 2 || fake.commands[0] != "toggle" || fake.commands[1] != "next" {
+		t.Errorf("commands = %v", fake.commands)
+	}
+}
+
+func TestServeStateRefresh(t *testing.T) {
+	fake := &fakeVolumio{state: volumio.PlayerState{Status: "play", Title: "One"}}
+	peer, errc := startProvider(t, fake, 10*time.Millisecond)
+
+	// Wait until the provider has loaded its initial state
+	peer.send(Request{Type: MsgPing, QID: 1})
+	if msg := peer.recv(); msg.Type != MsgPong {
+		t.Fatalf("got %+v, want pong", msg)
+	}
+
+	fake.mu.Lock()
+	fake.state.Title = "Two"
+	fake.mu.Unlock()
+
+	if msg := peer.recv(); msg.Type != MsgRefresh {
+		t.Fatalf("got %+v, want refresh after track change", msg)
+	}
+
+	peer.in.Close()
+	if err := <-errc; err != nil {
+		t.Errorf("Serve() error = %v", err)
+	}
+}
+
+func TestServeInvalidRequest(t *testing.T) {
+	peer, errc := startProvider(t, &fakeVolumio{}, 0)
+
+	peer.in.Write([]byte("not json\n"))
+	if msg := peer.recv(); msg.Type != MsgError {
+		t.Errorf("got %+v, want error", msg)
+	}
+
+	peer.in.Close()
+	<-errc
+}
+
+func TestServeSearch(t *testing.T) {
+	fake := &fakeVolumio{state: volumio.PlayerState{Status: "stop"}}
+	peer, errc := startProvider(t, fake, 0)
+
+	peer.send(Request{Type: MsgQuery, QID: 1, Query: "da"})
+	if entries := peer.collect(1); len(entries) != 0 {
+		t.Errorf("short query returned %+v", entries)
+	}
+
+	peer.send(Request{Type: MsgQuery, QID: 2, Query: "daft punk"})
+	entries := peer.collect(2)
+	if len(entries) != 2 {
+		t.Fatalf("search returned %d entries: %+v", len(entries), entries)
+	}
+	if entries[0].Piped != "browse:artists://Daft Punk" || entries[0].Categories[0] != "Artists" {
+		t.Errorf("artist entry = %+v", entries[0])
+	}
+	track := entries[1]
+	if !strings.HasPrefix(track.Piped, "item:") || track.Actions["queue"] != "queue:mnt/NAS/one.flac|mpd" {
+		t.Errorf("track entry = %+v", track)
+	}
+
+	// Repeating the query is served from cache
+	peer.send(Request{Type: MsgQuery, QID: 3, Query: "daft punk"})
+	peer.collect(3)
+
+	peer.send(Request{Type: MsgActivate, QID: 4, Identifier: track.Actions["queue"]})
+	if msg := peer.recv(); msg.Type != MsgActivated {
+		t.Fatalf("got %+v, want activated", msg)
+	}
+
+	// Selecting the track opens its action menu
+	peer.send(Request{Type: MsgActivate, QID: 5, Identifier: track.Piped})
+	peer.recv()
+	peer.recv()
+	peer.send(Request{Type: MsgQuery, QID: 6, Query: "favourites"})
+	if entries := peer.collect(6); len(entries) != 3 || entries[2].Piped != "favourite:mnt/NAS/one.flac|mpd" {
+		t.Errorf("item menu = %+v", entries)
+	}
+
+	peer.in.Close()
+	<-errc
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if fake.searches != 1 {
+		t.Errorf("searches = %d, want 1", fake.searches)
+	}
+	if len(fake.commands) != 1 || fake.commands[0] != "addToQueue mnt/NAS/one.flac mpd" {
+		t.Errorf("commands = %v", fake.commands)
+	}
+}
+
+func TestServeBrowse(t *testing.T) {
+	fake := &fakeVolumio{
+		state: volumio.PlayerState{Status: "stop"},
+		library: map[string][]volumio.BrowseItem{
+			"albums://": {
+				{URI: "albums://Daft Punk/Discovery", Title: "Discovery", Type: "folder", Service: "mpd", Artist: "Daft Punk"},
+				{URI: "albums://Justice/Cross", Title: "Cross", Type: "folder", Service: "mpd", Artist: "Justice"},
+			},
+		},
+	}
+	peer, errc := startProvider(t, fake, 0)
+
+	peer.send(Request{Type: MsgActivate, QID: 1, Identifier: "browse:albums://"})
+	if msg := peer.recv(); msg.Type != MsgActivated {
+		t.Fatalf("got %+v, want activated", msg)
+	}
+	if msg := peer.recv(); msg.Type != MsgRefresh {
+		t.Fatalf("got %+v, want refresh after navigation", msg)
+	}
+
+	peer.send(Request{Type: MsgQuery, QID: 2, Query: "justice"})
+	entries := peer.collect(2)
+	if len(entries) != 3 || entries[0].Piped != "nav:back" || entries[2].Label != "📁 Cross" {
+		t.Fatalf("browse query returned %+v", entries)
+	}
+	if entries[2].Actions["play"] != "play:albums://Justice/Cross|mpd" {
+		t.Errorf("album actions = %v", entries[2].Actions)
+	}
+
+	peer.send(Request{Type: MsgActivate, QID: 3, Identifier: entries[2].Actions["play"]})
+	if msg := peer.recv(); msg.Type != MsgActivated {
+		t.Fatalf("got %+v, want activated", msg)
+	}
+
+	peer.send(Request{Type: MsgActivate, QID: 4, Identifier: "nav:back"})
+	peer.recv()
+	peer.recv()
+	peer.send(Request{Type: MsgQuery, QID: 5})
+	if entries := peer.collect(5); entries[0].Piped != "action:toggle" {
+		t.Errorf("nav:back did not return to main menu: %+v", entries[0])
+	}
+
+	peer.send(Request{Type: MsgActivate, QID: 6, Identifier: "browse:missing"})
+	if msg := peer.recv(); msg.Type != MsgError {
+		t.Errorf("got %+v, want error for unknown folder", msg)
+	}
+
+	peer.in.Close()
+	<-errc
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if len(fake.commands) != 1 || fake.commands[0] != "replaceAndPlay albums://Justice/Cross mpd" {
+		t.Errorf("commands = %v", fake.commands)
+	}
+}
@@ -0,0 +1,193 @@
+package exporter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/riclib/volu/internal/volumio"
+)
+
+// Source is the part of the Volumio client the exporter reads.
+type Source interface {
+	GetState() (*volumio.PlayerState, error)
+	GetQueue() ([]volumio.QueueItem, error)
+}
+
+// Buckets are the upper bounds of the request latency histogram, in
+// seconds.
+var Buckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// statuses are reported one-hot by volumio_playback_status.
+var statuses = []string{"play", "pause", "stop"}
+
+// histogram is the latency of one endpoint.
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+// Exporter serves Volumio metrics in the Prometheus text format. The
+// player is queried on every scrape; request latencies and errors are
+// collected from the client through Observe.
+type Exporter struct {
+	source Source
+	host   string
+
+	mu        sync.Mutex
+	latencies map[string]*histogram
+	errors    map[string]uint64
+}
+
+// New creates an exporter for the player on host.
+func New(source Source, host string) *Exporter {
+	return &Exporter{
+		source:    source,
+		host:      host,
+		latencies: make(map[string]*histogram),
+		errors:    make(map[string]uint64),
+	}
+}
+
+// Observe records an API request; pass it to volumio.Client.SetObserver.
+func (e *Exporter) Observe(endpoint string, d time.Duration, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	h, ok := e.latencies[endpoint]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(Buckets))}
+		e.latencies[endpoint] = h
+	}
+	seconds := d.Seconds()
+	for i, bound := range Buckets {
+		if seconds <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += seconds
+	h.count++
+
+	if err != nil {
+		e.errors[endpoint]++
+	}
+}
+
+// ServeHTTP writes the metrics.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	e.Write(w)
+}
+
+// Write queries the player and writes all metrics to w.
+func (e *Exporter) Write(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	host := label("host", e.host)
+
+	state, err := e.source.GetState()
+	up := 0.0
+	if err == nil {
+		up = 1
+	}
+	metric(out, "volumio_up", "gauge", "Whether the Volumio API answered.")
+	sample(out, "volumio_up", host, up)
+
+	if state != nil {
+		metric(out, "volumio_volume_percent", "gauge", "Volume level, 0-100.")
+		sample(out, "volumio_volume_percent", host, float64(state.Volume))
+
+		metric(out, "volumio_muted", "gauge", "Whether the player is muted.")
+		sample(out, "volumio_muted", host, boolValue(state.Mute))
+
+		metric(out, "volumio_playback_status", "gauge", "Playback status: 1 for the current one of play, pause and stop.")
+		for _, status := range statuses {
+			sample(out, "volumio_playback_status", host+","+label("status", status), boolValue(state.Status == status))
+		}
+
+		metric(out, "volumio_track_position_seconds", "gauge", "Playback position in the current track.")
+		sample(out, "volumio_track_position_seconds", host, float64(state.Seek)/1000)
+
+		metric(out, "volumio_track_duration_seconds", "gauge", "Length of the current track; 0 for streams.")
+		sample(out, "volumio_track_duration_seconds", host, float64(state.Duration))
+
+		metric(out, "volumio_queue_position", "gauge", "Index of the current track in the queue.")
+		sample(out, "volumio_queue_position", host, float64(state.Position))
+
+		if queue, err := e.source.GetQueue(); err == nil {
+			metric(out, "volumio_queue_length", "gauge", "Number of tracks in the queue.")
+			sample(out, "volumio_queue_length", host, float64(len(queue)))
+		}
+	}
+
+	e.writeRequests(out, host)
+	return out.Flush()
+}
+
+// writeRequests writes the request latency histograms and error counters.
+func (e *Exporter) writeRequests(out *bufio.Writer, host string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	endpoints := make([]string, 0, len(e.latencies))
+	for endpoint := range e.latencies {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+
+	metric(out, "volumio_api_request_duration_seconds", "histogram", "Latency of Volumio API requests by endpoint.")
+	for _, endpoint := range endpoints {
+		h := e.latencies[endpoint]
+		labels := host + "," + label("endpoint", endpoint)
+		var cumulative uint64
+		for i, bound := range Buckets {
+			cumulative += h.counts[i]
+			sample(out, "volumio_api_request_duration_seconds_bucket", labels+","+label("le", formatFloat(bound)), float64(cumulative))
+		}
+		sample(out, "volumio_api_request_duration_seconds_bucket", labels+","+label("le", "+Inf"), float64(h.count))
+		sample(out, "volumio_api_request_duration_seconds_sum", labels, h.sum)
+		sample(out, "volumio_api_request_duration_seconds_count", labels, float64(h.count))
+	}
+
+	metric(out, "volumio_api_request_errors_total", "counter", "Failed Volumio API requests by endpoint.")
+	for _, endpoint := range endpoints {
+		sample(out, "volumio_api_request_errors_total", host+","+label("endpoint", endpoint), float64(e.errors[endpoint]))
+	}
+}
+
+func metric(out *bufio.Writer, name, kind, help string) {
+	fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func sample(out *bufio.Writer, name, labels string, value float64) {
+	fmt.Fprintf(out, "%s{%s} %s\n", name, labels, formatFloat(value))
+}
+
+// labelEscaper escapes label values as the text format requires.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func label(name, value string) string {
+	return name + `="` + labelEscaper.Replace(value) + `"`
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
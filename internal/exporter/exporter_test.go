@@ -0,0 +1,104 @@
+package exporter
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/riclib/volu/internal/volumio"
+)
+
+func TestExporter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/getState":
+			w.Write([]byte(`{"status": "pause", "volume": 35, "mute": true, "seek": 61500, "duration": 240, "position": 2}`))
+		case "/api/v1/getQueue":
+			w.Write([]byte(`{"queue": [{"uri": "a"}, {"uri": "b"}, {"uri": "c"}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := volumio.NewClient(server.URL)
+	exp := New(client, `office "pi"`)
+	client.SetObserver(exp.Observe)
+	client.Next() // fails with 404
+
+	rec := httptest.NewRecorder()
+	exp.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	host := `host="office \"pi\""`
+	for _, want := range []string{
+		`volumio_up{` + host + `} 1`,
+		`volumio_volume_percent{` + host + `} 35`,
+		`volumio_muted{` + host + `} 1`,
+		`volumio_playback_status{` + host + `,status="pause"} 1`,
+		`volumio_playback_status{` + host + `,status="play"} 0`,
+		`volumio_track_position_seconds{` + host + `} 61.5`,
+		`volumio_track_duration_seconds{` + host + `} 240`,
+		`volumio_queue_length{` + host + `} 3`,
+		`# TYPE volumio_api_request_duration_seconds histogram`,
+		`volumio_api_request_duration_seconds_bucket{` + host + `,endpoint="getState",le="+Inf"} 1`,
+		`volumio_api_request_duration_seconds_count{` + host + `,endpoint="commands/next"} 1`,
+		`volumio_api_request_errors_total{` + host + `,endpoint="commands/next"} 1`,
+		`volumio_api_request_errors_total{` + host + `,endpoint="getState"} 0`,
+	} {
+		if !strings.Contains(body, want+"\n") {
+			t.Errorf("metrics missing %s", want)
+		}
+	}
+	if t.Failed() {
+		t.Log(body)
+	}
+}
+
+func TestExporterUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	client := volumio.NewClient(server.URL, volumio.WithRetries(0, 0))
+	exp := New(client, "pi")
+	client.SetObserver(exp.Observe)
+
+	var out strings.Builder
+	exp.Write(&out)
+	if !strings.Contains(out.String(), `volumio_up{host="pi"} 0`) || strings.Contains(out.String(), "volumio_volume_percent") {
+		t.Errorf("unreachable player metrics:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), `volumio_api_request_errors_total{host="pi",endpoint="getState"} 1`) {
+		t.Errorf("getState error not counted:\n%s", out.String())
+	}
+}
+
+func TestHistogramBuckets(t *testing.T) {
+	exp := New(nil, "pi")
+	for _, d := range []time.Duration{3 * time.Millisecond, 40 * time.Millisecond, 40 * time.Millisecond, 20 * time.Second} {
+		exp.Observe("getState", d, nil)
+	}
+
+	var out strings.Builder
+	w := bufio.NewWriter(&out)
+	exp.writeRequests(w, `host="pi"`)
+	w.Flush()
+	for _, want := range []string{
+		`le="0.005"} 1`,
+		`le="0.025"} 1`,
+		`le="0.05"} 3`,
+		`le="10"} 3`,
+		`le="+Inf"} 4`,
+		`volumio_api_request_duration_seconds_sum{host="pi",endpoint="getState"} 20.083`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("histogram missing %s:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/riclib/volu/internal/volumio"
+)
+
+// MinListened is the shortest listen recorded; skipped tracks are left out.
+const MinListened = 10 * time.Second
+
+// Entry is a track played on Volumio.
+type Entry struct {
+	Time     time.Time `json:"time"` // when the track started
+	URI      string    `json:"uri"`
+	Title    string    `json:"title"`
+	Artist   string    `json:"artist,omitempty"`
+	Album    string    `json:"album,omitempty"`
+	Service  string    `json:"service,omitempty"`
+	Listened int       `json:"listened"` // seconds actually played
+}
+
+// Log is an append-only history file with one JSON entry per line.
+type Log struct {
+	Path string
+}
+
+// DefaultLog returns the history log for host, in $XDG_DATA_HOME (or
+// ~/.local/share) as it is kept for good.
+func DefaultLog(host string) (*Log, error) {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get home directory: %w", err)
+		}
+		dir = filepath.Join(home, ".local", "share")
+	}
+	return &Log{Path: filepath.Join(dir, "volu", "history-"+volumio.HostFileName(host)+".jsonl")}, nil
+}
+
+// Append adds e to the log.
+func (l *Log) Append(e Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history entry: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(l.Path), 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	f, err := os.OpenFile(l.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open history: %w", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return f.Close()
+}
+
+// Read returns the entries that started at or after since, oldest first.
+// Lines that can't be parsed, such as one cut short by a crash, are
+// skipped. A missing log has no entries.
+func (l *Log) Read(since time.Time) ([]Entry, error) {
+	f, err := os.Open(l.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Entry
+		if json.Unmarshal(scanner.Bytes(), &e) != nil {
+			continue
+		}
+		if !e.Time.Before(since) {
+			entries = append(entries, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	return entries, nil
+}
+
+// Recorder turns polled player states into history entries, counting
+// only the time a track was actually playing.
+type Recorder struct {
+	key     string
+	entry   Entry
+	playing bool
+	last    time.Time
+	played  time.Duration
+}
+
+// Update records the state polled at now. It returns the entry for the
+// previous track once a different one is loaded, if it played for at
+// least MinListened.
+func (r *Recorder) Update(state *volumio.PlayerState, now time.Time) *Entry {
+	if r.playing {
+		r.played += now.Sub(r.last)
+	}
+	r.last = now
+
+	key := volumio.TrackKey(state)
+
+	var done *Entry
+	if key != r.key {
+		done = r.finish()
+		r.key = key
+		r.entry = Entry{
+			Time:    now.Add(-time.Duration(state.Seek) * time.Millisecond).Truncate(time.Second),
+			URI:     state.URI,
+			Title:   state.Title,
+			Artist:  state.Artist,
+			Album:   state.Album,
+			Service: state.Service,
+		}
+	}
+	r.playing = key != "" && state.Status == "play"
+	return done
+}
+
+// Flush ends the current track at now, e.g. when recording stops, and
+// returns its entry if it played for at least MinListened.
+func (r *Recorder) Flush(now time.Time) *Entry {
+	if r.playing {
+		r.played += now.Sub(r.last)
+	}
+	r.last = now
+	r.playing = false
+	done := r.finish()
+	r.key = ""
+	return done
+}
+
+// Pause stops counting played time at now without ending the current
+// track, e.g. while Volumio is unreachable. The time since the last poll
+// is not counted, and the next Update carries on with the same entry if
+// the track is still loaded.
+func (r *Recorder) Pause(now time.Time) {
+	r.last = now
+	r.playing = false
+}
+
+func (r *Recorder) finish() *Entry {
+	played := r.played
+	r.played = 0
+	if r.key == "" || played < MinListened {
+		return nil
+	}
+	e := r.entry
+	e.Listened = int(played.Round(time.Second).Seconds())
+	return &e
+}
+
+// ParseAge parses a lookback such as "7d", "2w", "12h" or "30m".
+func ParseAge(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			count, err := strconv.Atoi(n)
+			if err != nil || count < 0 {
+				return 0, fmt.Errorf("invalid age %q", s)
+			}
+			return time.Duration(count) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q (use e.g. 7d, 2w or 12h)", s)
+	}
+	return d, nil
+}
+
+// PeriodStart returns when the period ("day", "week", "month", "year" or
+// "all") containing now began. Weeks start on Monday.
+func PeriodStart(period string, now time.Time) (time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch period {
+	case "day", "today":
+		return today, nil
+	case "week":
+		return today.AddDate(0, 0, -(int(today.Weekday())+6)%7), nil
+	case "month":
+		return today.AddDate(0, 0, 1-today.Day()), nil
+	case "year":
+		return time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location()), nil
+	case "all", "":
+		return time.Time{}, nil
+	}
+	return time.Time{}, fmt.Errorf("unknown period %q (use day, week, month, year or all)", period)
+}
+
+// Count is how often an artist, album or track was played.
+type Count struct {
+	Name     string
+	Plays    int
+	Listened time.Duration
+}
+
+// Top counts entries by "artists", "albums" or "tracks" and returns the n
+// most played, most listened first among equal plays. n <= 0 returns all.
+func Top(entries []Entry, by string, n int) ([]Count, error) {
+	var name func(e Entry) string
+	switch by {
+	case "artists", "artist":
+		name = func(e Entry) string { return e.Artist }
+	case "albums", "album":
+		name = func(e Entry) string {
+			if e.Album == "" || e.Artist == "" {
+				return e.Album
+			}
+			return e.Album + " (" + e.Artist + ")"
+		}
+	case "tracks", "track":
+		name = func(e Entry) string {
+			if e.Artist == "" {
+				return e.Title
+			}
+			return e.Artist + " - " + e.Title
+		}
+	default:
+		return nil, fmt.Errorf("unknown category %q (use artists, albums or tracks)", by)
+	}
+
+	index := make(map[string]int)
+	var counts []Count
+	for _, e := range entries {
+		key := name(e)
+		if key == "" {
+			continue
+		}
+		i, ok := index[key]
+		if !ok {
+			i = len(counts)
+			index[key] = i
+			counts = append(counts, Count{Name: key})
+		}
+		counts[i].Plays++
+		counts[i].Listened += time.Duration(e.Listened) * time.Second
+	}
+
+	sort.SliceStable(counts, func(i, j int) bool {
+		if counts[i].Plays != counts[j].Plays {
+			return counts[i].Plays > counts[j].Plays
+		}
+		return counts[i].Listened > counts[j].Listened
+	})
+	if n > 0 && len(counts) > n {
+		counts = counts[:n]
+	}
+	return counts, nil
+}
+
+// AlbumPlays counts the tracks played from each album, by album name.
+func AlbumPlays(entries []Entry) map[string]int {
+	plays := make(map[string]int)
+	for _, e := range entries {
+		if e.Album != "" {
+			plays[e.Album]++
+		}
+	}
+	return plays
+}
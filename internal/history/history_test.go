@@ -0,0 +1,171 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/riclib/volu/internal/volumio"
+)
+
+func TestRecorder(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	state := func(title, status string, seek int) *volumio.PlayerState {
+		return &volumio.PlayerState{Status: status, Title: title, Artist: "Artist", Album: "Album", URI: "mnt/" + title, Service: "mpd", Seek: seek * 1000}
+	}
+
+	var r Recorder
+	var got []*Entry
+	poll := func(s *volumio.PlayerState, at int) {
+		if e := r.Update(s, start.Add(time.Duration(at)*time.Second)); e != nil {
+			got = append(got, e)
+		}
+	}
+	poll(state("A", "play", 0), 0)
+	poll(state("A", "play", 60), 60)
+	poll(state("A", "pause", 60), 65) // paused from 60 to 300
+	poll(state("A", "play", 60), 300) // not counted
+	poll(state("A", "play", 120), 360)
+	poll(state("B", "play", 3), 363) // A done: 60+5+60+3s
+	poll(state("C", "play", 0), 366) // B skipped after 3s, not recorded
+	poll(&volumio.PlayerState{Status: "stop"}, 400)
+
+	if len(got) != 2 {
+		t.Fatalf("recorded %d entries, want 2: %+v", len(got), got)
+	}
+	if a := got[0]; a.Title != "A" || a.Listened != 128 || !a.Time.Equal(start) || a.Service != "mpd" {
+		t.Errorf("A = %+v, want 128s from %v", a, start)
+	}
+	if c := got[1]; c.Title != "C" || c.Listened != 34 {
+		t.Errorf("C = %+v, want 34s", c)
+	}
+
+	poll(state("D", "play", 30), 500)
+	if e := r.Flush(start.Add(530 * time.Second)); e == nil || e.Title != "D" || e.Listened != 30 || !e.Time.Equal(start.Add(470*time.Second)) {
+		t.Errorf("Flush() = %+v", e)
+	}
+	if e := r.Flush(start.Add(600 * time.Second)); e != nil {
+		t.Errorf("second Flush() = %+v, want nil", e)
+	}
+
+	// A failed poll pauses counting but keeps the track as one play
+	poll(state("E", "play", 0), 700)
+	poll(state("E", "play", 20), 720)
+	r.Pause(start.Add(725 * time.Second))
+	poll(state("E", "play", 60), 760) // 720 to 760 not counted
+	poll(state("E", "play", 80), 780)
+	if e := r.Flush(start.Add(780 * time.Second)); e == nil || e.Title != "E" || e.Listened != 40 || !e.Time.Equal(start.Add(700*time.Second)) {
+		t.Errorf("Flush() after Pause() = %+v, want one 40s play", e)
+	}
+}
+
+func TestLog(t *testing.T) {
+	log := &Log{Path: filepath.Join(t.TempDir(), "volu", "history.jsonl")}
+	if entries, err := log.Read(time.Time{}); err != nil || entries != nil {
+		t.Errorf("Read() of missing log = %v, %v", entries, err)
+	}
+
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i, title := range []string{"One", "Two", "Three"} {
+		if err := log.Append(Entry{Time: start.Add(time.Duration(i) * time.Hour), Title: title, Listened: 200}); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+	// A line cut short by a crash
+	f, _ := os.OpenFile(log.Path, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString(`{"time": "2026-03-01T15:00:00Z", "tit`)
+	f.Close()
+
+	entries, err := log.Read(start.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if len(entries) != 2 || entries[0].Title != "Two" || entries[1].Title != "Three" {
+		t.Errorf("Read() = %+v, want Two and Three", entries)
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+		err  bool
+	}{
+		{"7d", 7 * 24 * time.Hour, false},
+		{"2w", 14 * 24 * time.Hour, false},
+		{"12h", 12 * time.Hour, false},
+		{"90m", 90 * time.Minute, false},
+		{"d", 0, true},
+		{"-3d", 0, true},
+		{"soon", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseAge(tt.in)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("ParseAge(%q) = %v, %v; want %v, error %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestPeriodStart(t *testing.T) {
+	now := time.Date(2026, 10, 15, 18, 30, 0, 0, time.UTC) // a Thursday
+	tests := []struct {
+		period string
+		want   time.Time
+	}{
+		{"day", time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)},
+		{"week", time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC)},
+		{"month", time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)},
+		{"year", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"all", time.Time{}},
+	}
+	for _, tt := range tests {
+		got, err := PeriodStart(tt.period, now)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("PeriodStart(%q) = %v, %v; want %v", tt.period, got, err, tt.want)
+		}
+	}
+
+	sunday := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	if got, _ := PeriodStart("week", sunday); !got.Equal(time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("week of a Sunday starts %v, want the Monday before", got)
+	}
+	if _, err := PeriodStart("decade", now); err == nil {
+		t.Error("PeriodStart(decade) should fail")
+	}
+}
+
+func TestTop(t *testing.T) {
+	entries := []Entry{
+		{Artist: "Ferry Corsten", Album: "Gouryella", Title: "Anahera", Listened: 300},
+		{Artist: "Armin van Buuren", Album: "ASOT 1090", Title: "Intro", Listened: 60},
+		{Artist: "Ferry Corsten", Album: "Gouryella", Title: "Anahera", Listened: 300},
+		{Artist: "Armin van Buuren", Album: "ASOT 1091", Title: "Outro", Listened: 600},
+		{Title: "Stream without tags", Listened: 100},
+	}
+
+	artists, _ := Top(entries, "artists", 0)
+	if len(artists) != 2 || artists[0].Name != "Armin van Buuren" || artists[0].Plays != 2 || artists[0].Listened != 11*time.Minute {
+		t.Errorf("top artists = %+v", artists)
+	}
+
+	albums, _ := Top(entries, "albums", 1)
+	if len(albums) != 1 || albums[0].Name != "Gouryella (Ferry Corsten)" || albums[0].Plays != 2 {
+		t.Errorf("top albums = %+v", albums)
+	}
+
+	tracks, _ := Top(entries, "tracks", 0)
+	if len(tracks) != 4 || tracks[0].Name != "Ferry Corsten - Anahera" || tracks[1].Name != "Armin van Buuren - Outro" {
+		t.Errorf("top tracks = %+v", tracks)
+	}
+
+	if _, err := Top(entries, "genres", 10); err == nil {
+		t.Error("Top(genres) should fail")
+	}
+
+	plays := AlbumPlays(entries)
+	if plays["Gouryella"] != 2 || plays["ASOT 1091"] != 1 {
+		t.Errorf("AlbumPlays() = %v", plays)
+	}
+}
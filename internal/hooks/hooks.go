@@ -0,0 +1,256 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/template"
+	"time"
+
+	"github.com/riclib/volu/internal/config"
+	"github.com/riclib/volu/internal/volumio"
+)
+
+// Player events
+const (
+	TrackChange  = "track_change"  // a different track is loaded
+	Play         = "play"          // playback started or resumed
+	Pause        = "pause"         // playback paused
+	Stop         = "stop"          // playback stopped
+	VolumeChange = "volume_change" // volume changed or (un)muted
+	QueueEnd     = "queue_end"     // the last track in the queue finished
+	Disconnect   = "disconnect"    // Volumio stopped responding
+	Reconnect    = "reconnect"     // Volumio is reachable again
+)
+
+// Events lists the events hooks can be set for.
+var Events = []string{TrackChange, Play, Pause, Stop, VolumeChange, QueueEnd, Disconnect, Reconnect}
+
+// Valid reports whether event is a known event.
+func Valid(event string) bool {
+	for _, e := range Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultTimeout is how long a hook may run unless configured.
+const DefaultTimeout = 30 * time.Second
+
+// queueEndSlack is how close to its end the last track must have been at
+// the previous poll for a stop to count as the queue ending.
+const queueEndSlack = 10 * time.Second
+
+// Detector turns polled player states into events.
+type Detector struct {
+	// QueueLength returns the number of tracks in the queue. It is only
+	// called when playback stops; nil never reports queue_end.
+	QueueLength func() (int, error)
+
+	prev *volumio.PlayerState
+	down bool
+}
+
+// Update records the result of a poll and returns the events since the
+// previous one, in the order they should run. The first state only sets
+// the baseline.
+func (d *Detector) Update(state *volumio.PlayerState, err error) []string {
+	if err != nil {
+		if d.down {
+			return nil
+		}
+		d.down = true
+		return []string{Disconnect}
+	}
+
+	var events []string
+	if d.down {
+		d.down = false
+		events = append(events, Reconnect)
+	}
+	prev := d.prev
+	d.prev = state
+	if prev == nil {
+		return events
+	}
+
+	if state.Status != prev.Status {
+		switch state.Status {
+		case "play":
+			events = append(events, Play)
+		case "pause":
+			events = append(events, Pause)
+		case "stop":
+			events = append(events, Stop)
+		}
+	}
+	if key := volumio.TrackKey(state); key != volumio.TrackKey(prev) && key != "" {
+		events = append(events, TrackChange)
+	}
+	if state.Volume != prev.Volume || state.Mute != prev.Mute {
+		events = append(events, VolumeChange)
+	}
+	if prev.Status == "play" && state.Status == "stop" && d.queueEnded(prev) {
+		events = append(events, QueueEnd)
+	}
+	return events
+}
+
+// queueEnded reports whether prev was near the end of the last track in
+// the queue.
+func (d *Detector) queueEnded(prev *volumio.PlayerState) bool {
+	if d.QueueLength == nil || prev.Repeat {
+		return false
+	}
+	length, err := d.QueueLength()
+	if err != nil || length == 0 || prev.Position < length-1 {
+		return false
+	}
+	remaining := time.Duration(prev.Duration)*time.Second - time.Duration(prev.Seek)*time.Millisecond
+	return prev.Duration == 0 || remaining <= queueEndSlack
+}
+
+// Payload is what a hook is told about an event. Commands get it as JSON
+// on stdin and webhooks as the request body.
+type Payload struct {
+	Event    string               `json:"event"`
+	Host     string               `json:"host"`
+	Time     time.Time            `json:"time"`
+	State    *volumio.PlayerState `json:"state,omitempty"`    // nil on disconnect
+	Previous *volumio.PlayerState `json:"previous,omitempty"` // state before the event
+}
+
+// Env returns the payload as VOLU_* environment variables.
+func (p Payload) Env() []string {
+	env := []string{"VOLU_EVENT=" + p.Event, "VOLU_HOST=" + p.Host}
+	s := p.State
+	if s == nil {
+		return env
+	}
+	return append(env,
+		"VOLU_STATUS="+s.Status,
+		"VOLU_TITLE="+s.Title,
+		"VOLU_ARTIST="+s.Artist,
+		"VOLU_ALBUM="+s.Album,
+		"VOLU_ALBUMART="+s.AlbumArt,
+		"VOLU_URI="+s.URI,
+		"VOLU_SERVICE="+s.Service,
+		"VOLU_VOLUME="+strconv.Itoa(s.Volume),
+		"VOLU_MUTE="+strconv.FormatBool(s.Mute),
+		"VOLU_POSITION="+strconv.Itoa(s.Position),
+		"VOLU_SEEK="+strconv.Itoa(s.Seek),
+		"VOLU_DURATION="+strconv.Itoa(s.Duration),
+		"VOLU_RANDOM="+strconv.FormatBool(s.Random),
+		"VOLU_REPEAT="+strconv.FormatBool(s.Repeat),
+	)
+}
+
+// Run runs hook for the event in p.
+func Run(ctx context.Context, hook config.Hook, p Payload) error {
+	timeout := hook.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	switch {
+	case hook.Command != "":
+		return runCommand(ctx, hook.Command, p)
+	case hook.Webhook != "":
+		return post(ctx, hook, p)
+	}
+	return fmt.Errorf("hook for %s has neither command nor webhook", p.Event)
+}
+
+func runCommand(ctx context.Context, command string, p Payload) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = append(os.Environ(), p.Env()...)
+	cmd.Stdin = bytes.NewReader(data)
+	// On timeout, kill whatever the command started too, so its output
+	// is closed and the hook doesn't linger
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = time.Second
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if out := strings.TrimSpace(string(output)); out != "" {
+			return fmt.Errorf("%q failed: %w: %s", command, err, out)
+		}
+		return fmt.Errorf("%q failed: %w", command, err)
+	}
+	return nil
+}
+
+// templateFuncs are available in webhook templates; json quotes a value,
+// e.g. {"text": {{json .State.Title}}}.
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// Body returns the webhook request body for p: the rendered template, or
+// the payload as JSON.
+func Body(hook config.Hook, p Payload) ([]byte, error) {
+	if hook.Template == "" {
+		data, err := json.Marshal(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal event: %w", err)
+		}
+		return data, nil
+	}
+	tmpl, err := template.New("webhook").Funcs(templateFuncs).Parse(hook.Template)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook template: %w", err)
+	}
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, p); err != nil {
+		return nil, fmt.Errorf("failed to render webhook template: %w", err)
+	}
+	return body.Bytes(), nil
+}
+
+func post(ctx context.Context, hook config.Hook, p Payload) error {
+	body, err := Body(hook, p)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.Webhook, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "volu")
+	for name, value := range hook.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s returned %s", hook.Webhook, resp.Status)
+	}
+	return nil
+}
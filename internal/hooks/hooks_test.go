@@ -0,0 +1,134 @@
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/riclib/volu/internal/config"
+	"github.com/riclib/volu/internal/volumio"
+)
+
+func TestDetector(t *testing.T) {
+	queueLength := 3
+	d := &Detector{QueueLength: func() (int, error) { return queueLength, nil }}
+	track := func(title, status string, position, volume int) *volumio.PlayerState {
+		return &volumio.PlayerState{Status: status, Title: title, URI: "mnt/" + title, Position: position, Volume: volume, Duration: 200, Seek: 100000}
+	}
+	offline := errors.New("connection refused")
+
+	steps := []struct {
+		state *volumio.PlayerState
+		err   error
+		want  string
+	}{
+		{track("A", "stop", 0, 30), nil, ""}, // baseline
+		{track("A", "play", 0, 30), nil, "play"},
+		{track("B", "play", 1, 30), nil, "track_change"},
+		{track("B", "play", 1, 40), nil, "volume_change"},
+		{track("B", "pause", 1, 40), nil, "pause"},
+		{nil, offline, "disconnect"},
+		{nil, offline, ""},
+		{track("B", "play", 1, 40), nil, "reconnect,play"},
+		{track("C", "stop", 2, 40), nil, "stop,track_change"}, // stopped mid-track: not the queue end
+		{track("C", "play", 2, 40), nil, "play"},
+		{&volumio.PlayerState{Status: "play", Title: "C", URI: "mnt/C", Position: 2, Volume: 40, Duration: 200, Seek: 195000}, nil, ""},
+		{track("C", "stop", 2, 40), nil, "stop,queue_end"},
+	}
+	for i, step := range steps {
+		got := strings.Join(d.Update(step.state, step.err), ",")
+		if got != step.want {
+			t.Errorf("step %d: events = %q, want %q", i, got, step.want)
+		}
+	}
+}
+
+func TestEnv(t *testing.T) {
+	p := Payload{Event: TrackChange, Host: "volumio.local", State: &volumio.PlayerState{Status: "play", Title: "Song", Artist: "Artist", Volume: 35, Mute: true}}
+	env := strings.Join(p.Env(), "\n")
+	for _, want := range []string{"VOLU_EVENT=track_change", "VOLU_HOST=volumio.local", "VOLU_TITLE=Song", "VOLU_ARTIST=Artist", "VOLU_VOLUME=35", "VOLU_MUTE=true"} {
+		if !strings.Contains(env, want) {
+			t.Errorf("env missing %s:\n%s", want, env)
+		}
+	}
+
+	if env := (Payload{Event: Disconnect, Host: "volumio.local"}).Env(); len(env) != 2 {
+		t.Errorf("disconnect env = %v, want only event and host", env)
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	hook := config.Hook{Command: `printf '%s|' "$VOLU_EVENT" "$VOLU_TITLE" > ` + out + ` && cat >> ` + out}
+	p := Payload{Event: Play, Host: "volumio.local", State: &volumio.PlayerState{Status: "play", Title: "Song"}}
+	if err := Run(context.Background(), hook, p); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	data, _ := os.ReadFile(out)
+	env, stdin, _ := strings.Cut(string(data), "|Song|")
+	if env != "play" {
+		t.Errorf("command saw %q", data)
+	}
+	var got Payload
+	if err := json.Unmarshal([]byte(stdin), &got); err != nil || got.State.Title != "Song" || got.Event != Play {
+		t.Errorf("stdin = %q (%v)", stdin, err)
+	}
+
+	err := Run(context.Background(), config.Hook{Command: "echo nope >&2; exit 3"}, p)
+	if err == nil || !strings.Contains(err.Error(), "nope") {
+		t.Errorf("failing command error = %v, want its output", err)
+	}
+	err = Run(context.Background(), config.Hook{Command: "sleep 5", Timeout: 50 * time.Millisecond}, p)
+	if err == nil {
+		t.Error("Run() of a command past its timeout should fail")
+	}
+	if err := Run(context.Background(), config.Hook{}, p); err == nil {
+		t.Error("Run() of an empty hook should fail")
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var body, auth, contentType string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body, auth, contentType = string(data), r.Header.Get("Authorization"), r.Header.Get("Content-Type")
+		if strings.Contains(body, "fail") {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	p := Payload{Event: TrackChange, Host: "volumio.local", State: &volumio.PlayerState{Title: `Say "Hi"`, Artist: "Artist"}}
+	if err := Run(context.Background(), config.Hook{Webhook: server.URL, Headers: map[string]string{"Authorization": "Bearer x"}}, p); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	var got Payload
+	if err := json.Unmarshal([]byte(body), &got); err != nil || got.Event != TrackChange || got.State.Title != `Say "Hi"` {
+		t.Errorf("body = %s", body)
+	}
+	if auth != "Bearer x" || contentType != "application/json" {
+		t.Errorf("headers = %q, %q", auth, contentType)
+	}
+
+	hook := config.Hook{Webhook: server.URL, Template: `{"text": {{json (printf "Now playing %s - %s" .State.Artist .State.Title)}}}`}
+	if err := Run(context.Background(), hook, p); err != nil {
+		t.Fatalf("Run() with template error = %v", err)
+	}
+	if want := `{"text": "Now playing Artist - Say \"Hi\""}`; body != want {
+		t.Errorf("body = %s, want %s", body, want)
+	}
+
+	hook.Template = `fail`
+	if err := Run(context.Background(), hook, p); err == nil {
+		t.Error("Run() should fail on a 500 response")
+	}
+}
@@ -0,0 +1,245 @@
+package launcher
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Action kinds, used as the prefix of an action string.
+const (
+	KindCommand = "action" // action:<command>  transport, volume and mode controls
+	KindBrowse  = "browse" // browse:<uri>      open a library folder
+	KindPlay    = "play"   // play:<uri>|<svc>  replace the queue and play
+	KindQueue   = "queue"  // queue:<uri>|<svc> append to the queue
+	KindNav     = "nav"    // nav:<where>       launcher navigation (back, home)
+	KindSearch  = "search" // search:<query>    live library search
+
+	KindNext      = "next"      // next:<uri>|<svc>               play after the current track
+	KindPlaylist  = "playlist"  // playlist:<name>|<uri>|<svc>    add to a stored playlist
+	KindFavourite = "favourite" // favourite:<uri>|<svc>          add to favourites
+	KindItem      = "item"      // item:<ref>                     per-item action menu
+	KindAddTo     = "addto"     // addto:<ref>                    playlist picker for an item
+	KindPage      = "page"      // page:<page ref>                one page or A–Z bucket of a large folder
+)
+
+// Action is a parsed launcher action string such as "action:toggle" or
+// "play:mnt/NAS/album|mpd".
+type Action struct {
+	Kind    string
+	Value   string // command, URI or navigation target
+	Service string // service for play and queue actions
+
+	Playlist string // playlist name for playlist actions
+}
+
+// ParseAction parses an action string.
+func ParseAction(s string) (Action, error) {
+	kind, value, ok := strings.Cut(s, ":")
+	if !ok {
+		return Action{}, fmt.Errorf("unknown action: %s", s)
+	}
+
+	switch kind {
+	case KindCommand, KindNav, KindSearch:
+		if value == "" {
+			return Action{}, fmt.Errorf("unknown action: %s", s)
+		}
+		return Action{Kind: kind, Value: value}, nil
+	case KindBrowse:
+		return Action{Kind: kind, Value: value}, nil
+	case KindPlay, KindQueue, KindNext, KindFavourite:
+		uri, service := splitTarget(value)
+		if uri == "" {
+			return Action{}, fmt.Errorf("missing URI in action: %s", s)
+		}
+		return Action{Kind: kind, Value: uri, Service: service}, nil
+	case KindPlaylist:
+		name, target, _ := strings.Cut(value, "|")
+		uri, service := splitTarget(target)
+		if name == "" || uri == "" {
+			return Action{}, fmt.Errorf("missing playlist or URI in action: %s", s)
+		}
+		return Action{Kind: kind, Value: uri, Service: service, Playlist: name}, nil
+	case KindItem, KindAddTo:
+		if _, err := ParseRef(value); err != nil {
+			return Action{}, fmt.Errorf("invalid item in action %s: %w", s, err)
+		}
+		return Action{Kind: kind, Value: value}, nil
+	case KindPage:
+		if _, err := ParsePageRef(value); err != nil {
+			return Action{}, fmt.Errorf("invalid page in action %s: %w", s, err)
+		}
+		return Action{Kind: kind, Value: value}, nil
+	}
+
+	return Action{}, fmt.Errorf("unknown action: %s", s)
+}
+
+// String formats the action back into its string form.
+func (a Action) String() string {
+	switch a.Kind {
+	case KindPlay, KindQueue, KindNext, KindFavourite:
+		return a.Kind + ":" + a.Value + "|" + a.Service
+	case KindPlaylist:
+		return a.Kind + ":" + a.Playlist + "|" + a.Value + "|" + a.Service
+	}
+	return a.Kind + ":" + a.Value
+}
+
+// IsView reports whether the action opens a menu rather than running
+// something, i.e. whether launchers should push it onto their stack.
+func (a Action) IsView() bool {
+	switch a.Kind {
+	case KindBrowse, KindSearch, KindItem, KindAddTo, KindPage:
+		return true
+	}
+	return false
+}
+
+// Command returns the action string for a transport command.
+func Command(cmd string) string {
+	return Action{Kind: KindCommand, Value: cmd}.String()
+}
+
+// Browse returns the action string for opening a folder.
+func Browse(uri string) string {
+	return Action{Kind: KindBrowse, Value: uri}.String()
+}
+
+// Search returns the action string for a library search.
+func Search(query string) string {
+	return Action{Kind: KindSearch, Value: query}.String()
+}
+
+// Play returns the action string for playing an item.
+func Play(uri, service string) string {
+	return Action{Kind: KindPlay, Value: uri, Service: service}.String()
+}
+
+// Queue returns the action string for queueing an item.
+func Queue(uri, service string) string {
+	return Action{Kind: KindQueue, Value: uri, Service: service}.String()
+}
+
+// Next returns the action string for playing an item after the current track.
+func Next(uri, service string) string {
+	return Action{Kind: KindNext, Value: uri, Service: service}.String()
+}
+
+// Favourite returns the action string for adding an item to favourites.
+func Favourite(uri, service string) string {
+	return Action{Kind: KindFavourite, Value: uri, Service: service}.String()
+}
+
+// ToPlaylist returns the action string for adding an item to a playlist.
+// Playlist names containing "|" cannot be expressed.
+func ToPlaylist(name, uri, service string) string {
+	return Action{Kind: KindPlaylist, Value: uri, Service: service, Playlist: name}.String()
+}
+
+// ItemActions returns the action string for opening an item's action menu.
+func ItemActions(ref Ref) string {
+	return KindItem + ":" + ref.Encode()
+}
+
+// AddTo returns the action string for picking a playlist to add an item to.
+func AddTo(ref Ref) string {
+	return KindAddTo + ":" + ref.Encode()
+}
+
+// Ref identifies a library item in item and addto actions, carrying
+// enough metadata to offer browsing its artist and album.
+type Ref struct {
+	URI     string
+	Service string
+	Title   string
+	Artist  string
+	Album   string
+}
+
+// Encode formats the reference as a URL query string.
+func (r Ref) Encode() string {
+	v := url.Values{}
+	v.Set("uri", r.URI)
+	for key, value := range map[string]string{"service": r.Service, "title": r.Title, "artist": r.Artist, "album": r.Album} {
+		if value != "" {
+			v.Set(key, value)
+		}
+	}
+	return v.Encode()
+}
+
+// ParseRef parses a reference produced by Encode.
+func ParseRef(s string) (Ref, error) {
+	v, err := url.ParseQuery(s)
+	if err != nil {
+		return Ref{}, err
+	}
+	ref := Ref{
+		URI:     v.Get("uri"),
+		Service: v.Get("service"),
+		Title:   v.Get("title"),
+		Artist:  v.Get("artist"),
+		Album:   v.Get("album"),
+	}
+	if ref.URI == "" {
+		return Ref{}, fmt.Errorf("missing URI")
+	}
+	return ref, nil
+}
+
+// AllBuckets is the PageRef bucket that pages through a folder without
+// grouping it by letter.
+const AllBuckets = "*"
+
+// PageRef identifies a page of a folder listing in page actions,
+// optionally restricted to the items in one A–Z bucket.
+type PageRef struct {
+	URI    string
+	Bucket string // initial letter, "#" for anything else, or AllBuckets
+	Offset int
+}
+
+// Page returns the action string for showing a page of a folder.
+func Page(ref PageRef) string {
+	return KindPage + ":" + ref.Encode()
+}
+
+// Encode formats the page reference as a URL query string.
+func (p PageRef) Encode() string {
+	v := url.Values{}
+	v.Set("uri", p.URI)
+	if p.Bucket != "" {
+		v.Set("bucket", p.Bucket)
+	}
+	if p.Offset > 0 {
+		v.Set("offset", strconv.Itoa(p.Offset))
+	}
+	return v.Encode()
+}
+
+// ParsePageRef parses a page reference produced by Encode.
+func ParsePageRef(s string) (PageRef, error) {
+	v, err := url.ParseQuery(s)
+	if err != nil {
+		return PageRef{}, err
+	}
+	ref := PageRef{URI: v.Get("uri"), Bucket: v.Get("bucket")}
+	if offset := v.Get("offset"); offset != "" {
+		if ref.Offset, err = strconv.Atoi(offset); err != nil || ref.Offset < 0 {
+			return PageRef{}, fmt.Errorf("invalid offset %q", offset)
+		}
+	}
+	return ref, nil
+}
+
+// splitTarget splits a "uri|service" target. The service never contains
+// a pipe, so the last one separates the two.
+func splitTarget(target string) (uri, service string) {
+	if i := strings.LastIndex(target, "|"); i >= 0 {
+		return target[:i], target[i+1:]
+	}
+	return target, ""
+}
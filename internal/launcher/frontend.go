@@ -0,0 +1,158 @@
+package launcher
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// ErrCancelled is returned by Choose when the user dismisses the launcher.
+var ErrCancelled = errors.New("selection cancelled")
+
+// Frontend describes a dmenu-style launcher that reads one entry per line
+// on stdin and prints the selected line (or the typed text) on stdout.
+type Frontend struct {
+	Name    string
+	Command []string
+	// PrintQuery means the launcher prints the typed query on the first
+	// line of output, followed by the selection (fzf --print-query).
+	PrintQuery bool
+}
+
+// Frontends lists the supported dmenu-style launchers by name.
+// Rofi is driven through its script mode instead, see WriteRofi.
+var Frontends = map[string]Frontend{
+	"dmenu":  {Name: "dmenu", Command: []string{"dmenu", "-i", "-l", "20", "-p", "Volumio"}},
+	"fuzzel": {Name: "fuzzel", Command: []string{"fuzzel", "--dmenu", "--prompt", "Volumio> "}},
+	"wofi":   {Name: "wofi", Command: []string{"wofi", "--dmenu", "--insensitive", "--prompt", "Volumio"}},
+	"fzf":    {Name: "fzf", Command: []string{"fzf", "--print-query", "--prompt", "Volumio> "}, PrintQuery: true},
+}
+
+// FrontendNames returns the names accepted by --launcher, sorted.
+func FrontendNames() []string {
+	names := []string{"rofi"}
+	for name := range Frontends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lines renders items as one line each. Lines are made unique so a
+// selection can be mapped back to its item.
+func Lines(items []Item) []string {
+	lines := make([]string, len(items))
+	seen := make(map[string]int)
+	for i, item := range items {
+		line := sanitizeLine(item.Text())
+		if item.Sub != "" && !item.Separator {
+			line += "  ·  " + sanitizeLine(item.Sub)
+		}
+		if n := seen[line]; n > 0 {
+			seen[line] = n + 1
+			line = fmt.Sprintf("%s (%d)", line, n+1)
+		} else {
+			seen[line] = 1
+		}
+		lines[i] = line
+	}
+	return lines
+}
+
+// Choose runs the launcher with items and returns the selected item. If
+// the user typed text that matches no item, the returned item has no
+// action and query holds the text.
+func (f Frontend) Choose(items []Item) (selected Item, query string, err error) {
+	lines := Lines(items)
+
+	cmd := exec.Command(f.Command[0], f.Command[1:]...)
+	cmd.Stdin = strings.NewReader(strings.Join(lines, "\n") + "\n")
+	var out bytes.Buffer
+	cmd.Stdout = &out
+
+	runErr := cmd.Run()
+	selection, query := parseSelection(out.String(), f.PrintQuery)
+
+	if runErr != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(runErr, &exitErr) {
+			return Item{}, "", fmt.Errorf("failed to run %s: %w", f.Name, runErr)
+		}
+		// Non-zero exit with typed text means "no match", otherwise
+		// cancelled; fzf exits 130 on Esc even with text typed
+		if query == "" || exitErr.ExitCode() == 130 {
+			return Item{}, "", ErrCancelled
+		}
+	}
+
+	for i, line := range lines {
+		if selection != "" && line == selection {
+			return items[i], "", nil
+		}
+	}
+
+	if selection != "" {
+		query = selection
+	}
+	if query == "" {
+		return Item{}, "", ErrCancelled
+	}
+	return Item{}, query, nil
+}
+
+// parseSelection splits launcher output into the selected line and typed query.
+func parseSelection(out string, printQuery bool) (selection, query string) {
+	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+	if printQuery {
+		query = lines[0]
+		if len(lines) > 1 {
+			selection = lines[1]
+		}
+		return selection, query
+	}
+	return lines[0], ""
+}
+
+// WriteRofi writes items in rofi script-mode format. Each row carries its
+// action in the info field, which rofi hands back in $ROFI_INFO; data is
+// returned in $ROFI_DATA on the next invocation.
+func WriteRofi(w io.Writer, items []Item, message, data string) error {
+	var b strings.Builder
+	b.WriteString("\x00prompt\x1fVolumio\n")
+	if message != "" {
+		b.WriteString("\x00message\x1f" + sanitizeLine(message) + "\n")
+	}
+	b.WriteString("\x00data\x1f" + sanitizeLine(data) + "\n")
+
+	for _, item := range items {
+		b.WriteString(sanitizeLine(item.Text()))
+		b.WriteString("\x00info\x1f" + sanitizeLine(item.Action))
+		if item.Sub != "" {
+			b.WriteString("\x1fmeta\x1f" + sanitizeLine(item.Sub))
+		}
+		if item.Action == "" {
+			b.WriteString("\x1fnonselectable\x1ftrue")
+		}
+		b.WriteString("\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// sanitizeLine strips characters that would break line-based protocols.
+func sanitizeLine(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '\n', '\r':
+			return ' '
+		case 0, 0x1f:
+			return -1
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,167 @@
+package launcher
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/riclib/volu/internal/volumio"
+)
+
+func TestLinesUnique(t *testing.T) {
+	lines := Lines([]Item{
+		{Label: "Intro", Icon: "🎵", Sub: "Artist"},
+		{Label: "Intro", Icon: "🎵", Sub: "Artist"},
+		{Label: "Multi\nline"},
+		separator(),
+	})
+
+	want := []string{"🎵 Intro  ·  Artist", "🎵 Intro  ·  Artist (2)", "Multi line", separator().Label}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d = %q, want %q", i, lines[i], want[i])
+		}
+	}
+}
+
+func TestFrontendChoose(t *testing.T) {
+	items := []Item{
+		{Label: "Play / Pause", Action: "action:toggle"},
+		{Label: "Next Track", Action: "action:next"},
+	}
+
+	tests := []struct {
+		name      string
+		frontend  Frontend
+		wantItem  string
+		wantQuery string
+		wantErr   error
+	}{
+		{
+			name:     "select second line",
+			frontend: Frontend{Name: "fake", Command: []string{"sh", "-c", "sed -n 2p"}},
+			wantItem: "action:next",
+		},
+		{
+			name:      "typed text",
+			frontend:  Frontend{Name: "fake", Command: []string{"sh", "-c", "cat >/dev/null; echo daft punk"}},
+			wantQuery: "daft punk",
+		},
+		{
+			name:     "cancelled",
+			frontend: Frontend{Name: "fake", Command: []string{"sh", "-c", "cat >/dev/null; exit 1"}},
+			wantErr:  ErrCancelled,
+		},
+		{
+			name:      "print-query without match",
+			frontend:  Frontend{Name: "fake", Command: []string{"sh", "-c", "cat >/dev/null; echo justice; exit 1"}, PrintQuery: true},
+			wantQuery: "justice",
+		},
+		{
+			name:     "print-query with match",
+			frontend: Frontend{Name: "fake", Command: []string{"sh", "-c", "cat >/dev/null; echo pla; echo 'Play / Pause'"}, PrintQuery: true},
+			wantItem: "action:toggle",
+		},
+		{
+			name:     "print-query escape",
+			frontend: Frontend{Name: "fake", Command: []string{"sh", "-c", "cat >/dev/null; echo pla; exit 130"}, PrintQuery: true},
+			wantErr:  ErrCancelled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item, query, err := tt.frontend.Choose(items)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Choose() error = %v, want %v", err, tt.wantErr)
+			}
+			if item.Action != tt.wantItem || query != tt.wantQuery {
+				t.Errorf("Choose() = (%q, %q), want (%q, %q)", item.Action, query, tt.wantItem, tt.wantQuery)
+			}
+		})
+	}
+}
+
+func TestWriteRofi(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteRofi(&buf, []Item{
+		{Label: "Next Track", Sub: "Skip", Icon: "⏭️", Action: "action:next"},
+		{Label: "Volume: 30%"},
+	}, "oops", "browse%3Aalbums%3A%2F%2F")
+	if err != nil {
+		t.Fatalf("WriteRofi() error = %v", err)
+	}
+
+	want := "\x00prompt\x1fVolumio\n" +
+		"\x00message\x1foops\n" +
+		"\x00data\x1fbrowse%3Aalbums%3A%2F%2F\n" +
+		"⏭️ Next Track\x00info\x1faction:next\x1fmeta\x1fSkip\n" +
+		"Volume: 30%\x00info\x1f\x1fnonselectable\x1ftrue\n"
+	if buf.String() != want {
+		t.Errorf("WriteRofi() =\n%q\nwant\n%q", buf.String(), want)
+	}
+}
+
+func TestNavigator(t *testing.T) {
+	var commands []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/getState":
+			w.Write([]byte(`{"status":"play","title":"Song"}`))
+		case "/api/v1/browse":
+			w.Write([]byte(`{"navigation":{"lists":[{"items":[{"uri":"albums://x","title":"X","type":"folder"}]}]}}`))
+		case "/api/v1/search":
+			w.Write([]byte(`{"navigation":{"lists":[{"title":"Tracks","items":[{"uri":"mnt/a.flac","title":"A","type":"song"}]}]}}`))
+		case "/api/v1/commands/":
+			commands = append(commands, r.URL.Query().Get("cmd"))
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+	router := NewRouter(volumio.NewClient(server.URL))
+
+	nav := &Navigator{}
+	items, err := nav.Menu(router)
+	if err != nil || !strings.Contains(items[0].Label, "Song") {
+		t.Fatalf("main menu = %+v, %v", items, err)
+	}
+
+	for _, action := range []string{"browse:albums://", "search:a b"} {
+		if done, err := nav.Select(router, action); done || err != nil {
+			t.Fatalf("Select(%q) = %v, %v", action, done, err)
+		}
+	}
+	items, err = nav.Menu(router)
+	if err != nil || items[3].Category != "Tracks" {
+		t.Fatalf("search menu = %+v, %v", items, err)
+	}
+	if items[0].Label != "Volumio › Browse Albums › Search: a b" {
+		t.Errorf("breadcrumbs = %q", items[0].Label)
+	}
+
+	restored := DecodeNavigator(nav.Encode())
+	if len(restored.Stack) != 2 || restored.Current() != "search:a b" {
+		t.Errorf("restored stack = %v", restored.Stack)
+	}
+
+	restored.Select(router, "nav:back")
+	items, _ = restored.Menu(router)
+	if items[3].Action != "browse:albums://x" {
+		t.Errorf("after back = %+v", items)
+	}
+	if restored.Returned != "search:a b" {
+		t.Errorf("returned = %q", restored.Returned)
+	}
+	restored.Select(router, "nav:home")
+	if restored.Current() != "" {
+		t.Errorf("after home, current = %q", restored.Current())
+	}
+
+	done, err := restored.Select(router, "action:next")
+	if !done || err != nil || len(commands) != 1 || commands[0] != "next" {
+		t.Errorf("Select(action:next) = %v, %v; commands %v", done, err, commands)
+	}
+}
@@ -0,0 +1,418 @@
+package launcher
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/riclib/volu/internal/volumio"
+)
+
+// Item is a launcher-agnostic menu entry. Renderers for Walker, Elephant
+// and other launchers translate items into their own formats.
+type Item struct {
+	Label      string // Text without icon
+	Sub        string // Secondary line
+	Icon       string // Emoji icon
+	Image      string // Album art URL, if any
+	Category   string // Group heading (e.g. search result list title)
+	Action     string // Action string to run when selected; empty for info items
+	Searchable bool   // Whether the launcher should match the item when filtering
+	Separator  bool   // Visual separator with no action
+
+	// Actions maps alternative action names (e.g. "play", "queue") to
+	// action strings, for launchers that support secondary actions.
+	Actions map[string]string
+}
+
+// Text returns the icon and label as a single line for text-only launchers.
+func (i Item) Text() string {
+	if i.Icon == "" {
+		return i.Label
+	}
+	return i.Icon + " " + i.Label
+}
+
+// separator returns a separator item.
+func separator() Item {
+	return Item{Label: "────────────────────────────────────────", Separator: true}
+}
+
+// IconForItem returns an appropriate icon for a browse item
+func IconForItem(item *volumio.BrowseItem) string {
+	switch item.Type {
+	case "song", "track":
+		return "🎵"
+	case "album":
+		return "💿"
+	case "artist":
+		return "👤"
+	case "playlist":
+		return "📋"
+	case "webradio":
+		return "📻"
+	case "folder", "category":
+		return "📁"
+	default:
+		return "🎶"
+	}
+}
+
+// Preset is a saved web radio station shown numbered in the main menu.
+type Preset struct {
+	Name     string
+	URI      string
+	AlbumArt string
+}
+
+// MenuOptions are the settings the main menu depends on.
+type MenuOptions struct {
+	Presets    []Preset // web radio presets, listed numbered
+	VolumeStep int      // step of the volume up/down entries; zero means DefaultVolumeStep
+}
+
+// MainMenu creates the main menu with quick controls for the given state, which may be nil,
+// followed by any web radio presets
+func MainMenu(state *volumio.PlayerState, opts MenuOptions) []Item {
+	items := []Item{}
+	presets := opts.Presets
+	step := opts.VolumeStep
+	if step <= 0 {
+		step = DefaultVolumeStep
+	}
+
+	// Now playing section
+	if state != nil && state.Title != "" {
+		statusIcon := "▶"
+		if state.Status == "pause" {
+			statusIcon = "⏸"
+		} else if state.Status == "stop" {
+			statusIcon = "⏹"
+		}
+
+		artist := state.Artist
+		if artist == "" {
+			artist = "Unknown"
+		}
+		album := state.Album
+		if album == "" {
+			album = "Unknown"
+		}
+
+		items = append(items, Item{
+			Label:  fmt.Sprintf("Now Playing: %s - %s", artist, state.Title),
+			Sub:    fmt.Sprintf("Album: %s | %s", album, state.Status),
+			Icon:   statusIcon,
+			Action: Command("toggle"),
+		}, separator())
+	}
+
+	// Playback controls
+	items = append(items,
+		Item{Label: "Play / Pause", Sub: "Toggle playback", Icon: "▶️", Action: Command("toggle"), Searchable: true},
+		Item{Label: "Next Track", Sub: "Skip to next track", Icon: "⏭️", Action: Command("next"), Searchable: true},
+		Item{Label: "Previous Track", Sub: "Go to previous track", Icon: "⏮️", Action: Command("prev"), Searchable: true},
+		Item{Label: "Stop", Sub: "Stop playback", Icon: "⏹️", Action: Command("stop"), Searchable: true},
+		separator(),
+	)
+
+	// Web radio presets, numbered so "1" picks the first
+	if len(presets) > 0 {
+		for i, preset := range presets {
+			items = append(items, Item{
+				Label:      fmt.Sprintf("%d. %s", i+1, preset.Name),
+				Sub:        "Web radio preset",
+				Icon:       "📻",
+				Image:      preset.AlbumArt,
+				Action:     Play(preset.URI, "webradio"),
+				Searchable: true,
+			})
+		}
+		items = append(items, separator())
+	}
+
+	// Volume controls
+	if state != nil {
+		volumeIcon := "🔊"
+		muteText := ""
+		if state.Mute {
+			volumeIcon = "🔇"
+			muteText = " (Muted)"
+		}
+
+		items = append(items,
+			Item{
+				Label: fmt.Sprintf("Volume: %d%%", state.Volume),
+				Sub:   fmt.Sprintf("Current volume level%s", muteText),
+				Icon:  volumeIcon,
+			},
+			Item{Label: fmt.Sprintf("Volume Up (+%d%%)", step), Sub: "Increase volume", Icon: "🔊", Action: Command("volup"), Searchable: true},
+			Item{Label: fmt.Sprintf("Volume Down (-%d%%)", step), Sub: "Decrease volume", Icon: "🔉", Action: Command("voldown"), Searchable: true},
+			Item{Label: "Toggle Mute", Sub: "Mute/unmute audio", Icon: "🔇", Action: Command("mute"), Searchable: true},
+			separator(),
+		)
+	}
+
+	// Playback modes
+	shuffleStatus := "OFF"
+	repeatStatus := "OFF"
+	if state != nil {
+		if state.Random {
+			shuffleStatus = "ON"
+		}
+		if state.Repeat {
+			repeatStatus = "ON"
+		}
+	}
+
+	items = append(items,
+		Item{Label: "Shuffle: " + shuffleStatus, Sub: "Toggle shuffle mode", Icon: "🔀", Action: Command("shuffle"), Searchable: true},
+		Item{Label: "Repeat: " + repeatStatus, Sub: "Toggle repeat mode", Icon: "🔁", Action: Command("repeat"), Searchable: true},
+		separator(),
+	)
+
+	// Browse sections
+	items = append(items,
+		Item{Label: "Browse Music Library", Sub: "Navigate your music collection", Icon: "📁", Action: Browse(""), Searchable: true},
+		Item{Label: "Browse Playlists", Sub: "View and play playlists", Icon: "📋", Action: Browse("playlists"), Searchable: true},
+		Item{Label: "Browse Artists", Sub: "Browse by artist", Icon: "👤", Action: Browse("artists://"), Searchable: true},
+		Item{Label: "Browse Albums", Sub: "Browse by album", Icon: "💿", Action: Browse("albums://"), Searchable: true},
+	)
+
+	return items
+}
+
+// BrowseMenu creates a browse menu for the items of a folder, preceded by
+// navigation entries
+func BrowseMenu(items []volumio.BrowseItem) []Item {
+	menu := navItems()
+
+	if len(items) == 0 {
+		return append(menu, Item{Label: "No items found", Sub: "This folder is empty", Icon: "❌"})
+	}
+
+	for _, item := range items {
+		menu = append(menu, ItemFor(item))
+	}
+	return menu
+}
+
+// ResultItems converts every list of a browse result into menu items.
+// When the result has more than one list, items are categorised by list
+// title (e.g. Top tracks, Albums, Related Artists).
+func ResultItems(result *volumio.BrowseResult) []Item {
+	var items []Item
+	for _, list := range result.Lists {
+		for _, entry := range list.Items {
+			item := ItemFor(entry)
+			if len(result.Lists) > 1 {
+				item.Category = list.Title
+			}
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// InfoItem turns the header of an album, artist or playlist listing into
+// an entry that opens its action menu.
+func InfoItem(info *volumio.BrowseInfo) Item {
+	sub := info.Artist
+	if sub == "" || sub == info.Title {
+		sub = info.Type
+	}
+
+	item := Item{
+		Label: info.Title,
+		Sub:   sub,
+		Icon:  IconForItem(&volumio.BrowseItem{Type: info.Type}),
+		Image: info.AlbumArt,
+	}
+	if info.URI != "" {
+		item.Action = ItemActions(Ref{URI: info.URI, Service: info.Service, Title: info.Title, Artist: info.Artist, Album: info.Album})
+	}
+	return item
+}
+
+// BucketMenu groups a large folder listing by initial letter, with one
+// entry per bucket and one for paging through everything.
+func BucketMenu(uri string, items []volumio.BrowseItem) []Item {
+	counts := make(map[string]int)
+	for _, item := range items {
+		counts[Bucket(item.DisplayName())]++
+	}
+	buckets := make([]string, 0, len(counts))
+	for bucket := range counts {
+		buckets = append(buckets, bucket)
+	}
+	sort.Strings(buckets)
+
+	menu := append(navItems(), Item{
+		Label:      "All",
+		Sub:        fmt.Sprintf("%d items", len(items)),
+		Icon:       "📚",
+		Action:     Page(PageRef{URI: uri, Bucket: AllBuckets}),
+		Searchable: true,
+	})
+	for _, bucket := range buckets {
+		menu = append(menu, Item{
+			Label:      bucket,
+			Sub:        fmt.Sprintf("%d items", counts[bucket]),
+			Icon:       "🔤",
+			Action:     Page(PageRef{URI: uri, Bucket: bucket}),
+			Searchable: true,
+		})
+	}
+	return menu
+}
+
+// Bucket returns the A–Z bucket for a name: its upper-cased initial
+// letter, or "#" if it starts with anything else.
+func Bucket(name string) string {
+	for _, r := range name {
+		if unicode.IsLetter(r) {
+			return string(unicode.ToUpper(r))
+		}
+		return "#"
+	}
+	return "#"
+}
+
+// hasTracks reports whether a listing contains individual tracks, which
+// are kept in their folder order rather than grouped by letter.
+func hasTracks(items []volumio.BrowseItem) bool {
+	for _, item := range items {
+		if item.Type == "song" || item.Type == "track" {
+			return true
+		}
+	}
+	return false
+}
+
+// navItems returns the Back and Main Menu entries shown above sub-menus.
+func navItems() []Item {
+	return []Item{
+		{Label: "Back", Sub: "Go back to previous level", Icon: "⬅️", Action: "nav:back"},
+		{Label: "Main Menu", Sub: "Return to the main menu", Icon: "🏠", Action: "nav:home"},
+	}
+}
+
+// ItemFor converts a library item into a menu item that browses into
+// folders and opens the action menu of anything else playable. Playable
+// items also carry play, next, queue and more alternatives.
+func ItemFor(item volumio.BrowseItem) Item {
+	subtitle := item.Type
+	if subtitle == "" {
+		subtitle = "Item"
+	}
+	if item.Artist != "" {
+		subtitle = item.Artist
+		if item.Album != "" {
+			subtitle += " • " + item.Album
+		}
+	} else if item.Album != "" {
+		subtitle = item.Album
+	}
+
+	menuItem := Item{
+		Label:      item.DisplayName(),
+		Sub:        subtitle,
+		Icon:       IconForItem(&item),
+		Image:      item.AlbumArt,
+		Searchable: true,
+	}
+
+	ref := Ref{URI: item.URI, Service: item.Service, Title: item.DisplayName(), Artist: item.Artist, Album: item.Album}
+	if item.IsPlayable() {
+		menuItem.Actions = map[string]string{
+			"play":  Play(item.URI, item.Service),
+			"next":  Next(item.URI, item.Service),
+			"queue": Queue(item.URI, item.Service),
+			"more":  ItemActions(ref),
+		}
+	}
+
+	if item.IsBrowsable() {
+		menuItem.Action = Browse(item.URI)
+	} else if item.IsPlayable() {
+		menuItem.Action = ItemActions(ref)
+	} else {
+		menuItem.Action = Browse(item.URI)
+	}
+
+	return menuItem
+}
+
+// ItemMenu lists what can be done with a single item: play it now or
+// next, queue it, add it to a playlist or favourites, or browse its
+// artist and album.
+func ItemMenu(ref Ref) []Item {
+	menu := append(navItems(),
+		Item{Label: "Play Now", Sub: "Replace the queue and play", Icon: "▶️", Action: Play(ref.URI, ref.Service), Searchable: true},
+		Item{Label: "Play Next", Sub: "Play after the current track", Icon: "⏭️", Action: Next(ref.URI, ref.Service), Searchable: true},
+		Item{Label: "Add to Queue", Sub: "Append to the end of the queue", Icon: "➕", Action: Queue(ref.URI, ref.Service), Searchable: true},
+		Item{Label: "Add to Playlist…", Sub: "Choose a playlist", Icon: "📋", Action: AddTo(ref), Searchable: true},
+		Item{Label: "Add to Favourites", Sub: "Save to your favourites", Icon: "❤️", Action: Favourite(ref.URI, ref.Service), Searchable: true},
+	)
+
+	if ref.Artist != "" {
+		menu = append(menu, Item{Label: "Browse Artist", Sub: ref.Artist, Icon: "👤", Action: Browse(ArtistURI(ref.Artist)), Searchable: true})
+		if ref.Album != "" {
+			menu = append(menu, Item{Label: "Browse Album", Sub: ref.Album, Icon: "💿", Action: Browse(AlbumURI(ref.Artist, ref.Album)), Searchable: true})
+		}
+	}
+
+	return menu
+}
+
+// ArtistURI returns the Volumio browse URI of an artist.
+func ArtistURI(artist string) string {
+	return "artists://" + encodeURIComponent(artist)
+}
+
+// AlbumURI returns the Volumio browse URI of an album.
+func AlbumURI(artist, album string) string {
+	return "albums://" + encodeURIComponent(artist) + "/" + encodeURIComponent(album)
+}
+
+// encodeURIComponent escapes a path segment the way Volumio's web UI does.
+func encodeURIComponent(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// Filter keeps searchable items whose label or subtitle contain every word
+// of query, case-insensitive. Navigation and "Load more…" items are always
+// kept. An empty query returns items unchanged.
+func Filter(items []Item, query string) []Item {
+	words := strings.Fields(strings.ToLower(query))
+	if len(words) == 0 {
+		return items
+	}
+
+	var matches []Item
+	for _, item := range items {
+		// "Load more…" is the only unsearchable page entry
+		loadMore := !item.Searchable && strings.HasPrefix(item.Action, KindPage+":")
+		if strings.HasPrefix(item.Action, KindNav+":") || loadMore {
+			matches = append(matches, item)
+			continue
+		}
+		if !item.Searchable {
+			continue
+		}
+		text := strings.ToLower(item.Label + " " + item.Sub)
+		matched := true
+		for _, word := range words {
+			if !strings.Contains(text, word) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			matches = append(matches, item)
+		}
+	}
+	return matches
+}
@@ -0,0 +1,342 @@
+package launcher
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/riclib/volu/internal/volumio"
+	"github.com/riclib/volu/internal/volumiofake"
+	"github.com/riclib/volu/internal/volumiotest"
+)
+
+func TestParseAction(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Action
+		wantErr bool
+	}{
+		{"action:toggle", Action{Kind: KindCommand, Value: "toggle"}, false},
+		{"browse:", Action{Kind: KindBrowse, Value: ""}, false},
+		{"browse:albums://Daft Punk/Discovery", Action{Kind: KindBrowse, Value: "albums://Daft Punk/Discovery"}, false},
+		{"play:mnt/NAS/a|b.flac|mpd", Action{Kind: KindPlay, Value: "mnt/NAS/a|b.flac", Service: "mpd"}, false},
+		{"queue:http://radio/stream|webradio", Action{Kind: KindQueue, Value: "http://radio/stream", Service: "webradio"}, false},
+		{"play:spotify:track:123", Action{Kind: KindPlay, Value: "spotify:track:123"}, false},
+		{"nav:back", Action{Kind: KindNav, Value: "back"}, false},
+		{"next:mnt/NAS/a.flac|mpd", Action{Kind: KindNext, Value: "mnt/NAS/a.flac", Service: "mpd"}, false},
+		{"favourite:mnt/NAS/a.flac|mpd", Action{Kind: KindFavourite, Value: "mnt/NAS/a.flac", Service: "mpd"}, false},
+		{"playlist:Morning|mnt/NAS/a|b.flac|mpd", Action{Kind: KindPlaylist, Value: "mnt/NAS/a|b.flac", Service: "mpd", Playlist: "Morning"}, false},
+		{"item:uri=mnt%2Fa.flac&title=A", Action{Kind: KindItem, Value: "uri=mnt%2Fa.flac&title=A"}, false},
+		{"playlist:|mnt/a.flac|mpd", Action{}, true},
+		{"item:title=A", Action{}, true},
+		{"action:", Action{}, true},
+		{"play:", Action{}, true},
+		{"toggle", Action{}, true},
+		{"bogus:thing", Action{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseAction(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseAction(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseAction(%q) = %+v, want %+v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestActionRoundTrip(t *testing.T) {
+	ref := Ref{URI: "mnt/x", Service: "mpd", Title: "X & Y", Artist: "A"}
+	for _, s := range []string{
+		Command("next"), Browse("playlists"), Play("mnt/x", "mpd"), Queue("mnt/y", ""),
+		Next("mnt/x", "mpd"), Favourite("mnt/x", ""), ToPlaylist("Morning", "mnt/x", "mpd"),
+		ItemActions(ref), AddTo(ref),
+	} {
+		a, err := ParseAction(s)
+		if err != nil {
+			t.Fatalf("ParseAction(%q) error = %v", s, err)
+		}
+		if a.String() != s {
+			t.Errorf("round trip %q -> %q", s, a.String())
+		}
+	}
+}
+
+func TestMainMenu(t *testing.T) {
+	// Without state: no now playing or volume entries
+	items := MainMenu(nil, MenuOptions{})
+	for _, item := range items {
+		if item.Action == Command("volup") {
+			t.Error("volume controls shown without state")
+		}
+	}
+
+	state := &volumio.PlayerState{Status: "pause", Title: "Song", Artist: "Artist", Volume: 30, Random: true}
+	items = MainMenu(state, MenuOptions{VolumeStep: 5})
+	if items[0].Text() != "⏸ Now Playing: Artist - Song" || items[0].Action != "action:toggle" {
+		t.Errorf("now playing = %+v", items[0])
+	}
+
+	found := map[string]bool{}
+	for _, item := range items {
+		found[item.Action] = true
+		if item.Label == "Shuffle: OFF" {
+			t.Error("shuffle shown as OFF while random is on")
+		}
+		if item.Action == Command("volup") && item.Label != "Volume Up (+5%)" {
+			t.Errorf("volume up label = %q, want the configured step", item.Label)
+		}
+	}
+	for _, action := range []string{"action:volup", "action:mute", "browse:", "browse:albums://"} {
+		if !found[action] {
+			t.Errorf("main menu missing %s", action)
+		}
+	}
+}
+
+func TestMainMenuPresets(t *testing.T) {
+	items := MainMenu(nil, MenuOptions{Presets: []Preset{{Name: "Office Radio", URI: "http://office/stream"}, {Name: "FIP", URI: "http://fip"}}})
+
+	var presets []Item
+	for _, item := range items {
+		if item.Icon == "📻" {
+			presets = append(presets, item)
+		}
+	}
+	if len(presets) != 2 || presets[0].Label != "1. Office Radio" || presets[1].Action != "play:http://fip|webradio" {
+		t.Errorf("presets = %+v", presets)
+	}
+	if got := Filter(items, "1."); len(got) != 1 || got[0].Label != "1. Office Radio" {
+		t.Errorf("Filter(1.) = %+v", got)
+	}
+}
+
+func TestBrowseMenu(t *testing.T) {
+	items := BrowseMenu([]volumio.BrowseItem{
+		{URI: "music-library/NAS", Title: "NAS", Type: "folder", Service: "mpd"},
+		{URI: "mnt/NAS/one.flac", Title: "One", Type: "song", Service: "mpd", Artist: "A", Album: "B"},
+		{URI: "radio/x", Name: "Station", Type: "webradio", Service: "webradio"},
+	})
+
+	if len(items) != 5 || items[0].Action != "nav:back" || items[1].Action != "nav:home" {
+		t.Fatalf("BrowseMenu() = %+v", items)
+	}
+	if items[2].Action != "browse:music-library/NAS" || items[2].Actions["queue"] != "queue:music-library/NAS|mpd" {
+		t.Errorf("folder item = %+v", items[2])
+	}
+	if !strings.HasPrefix(items[3].Action, "item:") || items[3].Sub != "A • B" || items[3].Icon != "🎵" {
+		t.Errorf("song item = %+v", items[3])
+	}
+	if items[3].Actions["play"] != "play:mnt/NAS/one.flac|mpd" || items[3].Actions["next"] != "next:mnt/NAS/one.flac|mpd" {
+		t.Errorf("song actions = %v", items[3].Actions)
+	}
+	if items[4].Label != "Station" || items[4].Actions["play"] != "play:radio/x|webradio" {
+		t.Errorf("webradio item = %+v", items[4])
+	}
+
+	empty := BrowseMenu(nil)
+	if len(empty) != 3 || empty[2].Label != "No items found" {
+		t.Errorf("BrowseMenu(nil) = %+v", empty)
+	}
+}
+
+func TestItemMenu(t *testing.T) {
+	a, err := ParseAction(ItemFor(volumio.BrowseItem{
+		URI: "mnt/NAS/one.flac", Title: "One", Type: "song", Service: "mpd", Artist: "Daft Punk", Album: "Discovery",
+	}).Action)
+	if err != nil || a.Kind != KindItem {
+		t.Fatalf("ParseAction() = %+v, %v", a, err)
+	}
+	ref, err := ParseRef(a.Value)
+	if err != nil {
+		t.Fatalf("ParseRef() error = %v", err)
+	}
+
+	want := map[string]bool{
+		"play:mnt/NAS/one.flac|mpd":             true,
+		"next:mnt/NAS/one.flac|mpd":             true,
+		"queue:mnt/NAS/one.flac|mpd":            true,
+		"favourite:mnt/NAS/one.flac|mpd":        true,
+		AddTo(ref):                              true,
+		"browse:artists://Daft%20Punk":          true,
+		"browse:albums://Daft%20Punk/Discovery": true,
+	}
+	for _, item := range ItemMenu(ref) {
+		delete(want, item.Action)
+	}
+	if len(want) != 0 {
+		t.Errorf("ItemMenu() missing %v", want)
+	}
+
+	// Without artist metadata there is nothing to browse to
+	for _, item := range ItemMenu(Ref{URI: "radio/x"}) {
+		if strings.HasPrefix(item.Action, "browse:") {
+			t.Errorf("unexpected %s", item.Action)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	items := BrowseMenu([]volumio.BrowseItem{
+		{URI: "a", Title: "Discovery", Type: "folder", Artist: "Daft Punk"},
+		{URI: "b", Title: "Cross", Type: "folder", Artist: "Justice"},
+	})
+
+	matches := Filter(items, "daft disc")
+	if len(matches) != 3 || matches[2].Label != "Discovery" {
+		t.Errorf("Filter() = %+v", matches)
+	}
+	if got := Filter(items, ""); len(got) != len(items) {
+		t.Errorf("Filter(empty) dropped items")
+	}
+}
+
+func TestRouter(t *testing.T) {
+	lib := volumiofake.DefaultLibrary()
+	lib.Playlists["Focus"] = nil
+	server := volumiotest.NewServer(lib)
+	defer server.Close()
+
+	migration := lib.Albums[len(lib.Albums)-1]
+	blueprint := lib.Albums[len(lib.Albums)-3]
+	router := NewRouter(volumio.NewClient(server.URL))
+	for _, action := range []string{
+		"play:" + migration.URI + "|mpd", "action:next", "queue:" + blueprint.Tracks[0].URI + "|mpd",
+		"favourite:" + blueprint.Tracks[1].URI + "|mpd", "playlist:Focus|" + blueprint.Tracks[2].URI + "|mpd",
+	} {
+		if err := router.RunString(action); err != nil {
+			t.Errorf("RunString(%q) error = %v", action, err)
+		}
+	}
+	if err := router.RunString("action:explode"); err == nil {
+		t.Error("expected error for unknown command")
+	}
+	if err := router.RunString("nav:back"); err == nil {
+		t.Error("expected error for navigation action")
+	}
+
+	want := []string{"replaceAndPlay", "next", "addToQueue", "addToFavourites", "addToPlaylist"}
+	if got := server.Commands(); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("requests = %v, want %v", got, want)
+	}
+	state := server.State()
+	if state.Status != "play" || state.Title != migration.Tracks[1].Title {
+		t.Errorf("state = %s %q, want playing %q", state.Status, state.Title, migration.Tracks[1].Title)
+	}
+	queue := server.Queue()
+	if len(queue) != len(migration.Tracks)+1 || queue[len(queue)-1].URI != blueprint.Tracks[0].URI {
+		t.Errorf("queue = %+v, want the album then the queued track", queue)
+	}
+
+	items, err := router.Browse(migration.URI)
+	if err != nil {
+		t.Fatalf("Browse() error = %v", err)
+	}
+	resolved := false
+	for _, item := range items {
+		resolved = resolved || item.Image == server.URL+migration.AlbumArt
+	}
+	if !resolved {
+		t.Errorf("album art not resolved to %s: %+v", server.URL+migration.AlbumArt, items)
+	}
+
+	picker, err := router.View(Action{Kind: KindAddTo, Value: Ref{URI: "mnt/a.flac", Title: "A"}.Encode()})
+	if err != nil {
+		t.Fatalf("View(addto) error = %v", err)
+	}
+	if len(picker) != 4 || picker[2].Action != "playlist:Focus|mnt/a.flac|" || picker[3].Action != "playlist:Morning|mnt/a.flac|" {
+		t.Errorf("playlist picker = %+v", picker)
+	}
+}
+
+func TestBrowsePaging(t *testing.T) {
+	var folder []volumio.BrowseItem
+	for _, name := range []string{"Air", "ABBA", "Beck", "Björk", "Cher", "2Pac", "Daft Punk"} {
+		folder = append(folder, volumio.BrowseItem{URI: "artists://" + name, Title: name, Type: "folder"})
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"navigation": map[string]interface{}{
+				"lists": []interface{}{map[string]interface{}{"items": folder}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	router := NewRouter(volumio.NewClient(server.URL))
+	router.PageSize = 3
+	router.BucketThreshold = 5
+
+	// Too many artists: show buckets
+	items, err := router.Browse("artists://")
+	if err != nil {
+		t.Fatalf("Browse() error = %v", err)
+	}
+	var labels []string
+	for _, item := range items[2:] {
+		labels = append(labels, item.Label+" "+item.Sub)
+	}
+	want := "All 7 items|# 1 items|A 2 items|B 2 items|C 1 items|D 1 items"
+	if got := strings.Join(labels, "|"); got != want {
+		t.Errorf("buckets = %s, want %s", got, want)
+	}
+
+	// A bucket fits on one page
+	items, _ = router.View(Action{Kind: KindPage, Value: PageRef{URI: "artists://", Bucket: "B"}.Encode()})
+	if len(items) != 4 || items[2].Label != "Beck" || items[3].Label != "Björk" {
+		t.Errorf("bucket B = %+v", items)
+	}
+
+	// Paging through everything
+	items, _ = router.BrowsePage(PageRef{URI: "artists://", Bucket: AllBuckets})
+	last := items[len(items)-1]
+	if len(items) != 6 || last.Label != "Load more…" || last.Sub != "Showing 1–3 of 7" {
+		t.Fatalf("first page = %+v", items)
+	}
+	a, _ := ParseAction(last.Action)
+	items, _ = router.View(a)
+	if items[2].Label != "Björk" || items[len(items)-1].Sub != "Showing 4–6 of 7" {
+		t.Errorf("second page = %+v", items)
+	}
+	if got := Filter(items, "zzz"); len(got) != 3 || got[2].Label != "Load more…" {
+		t.Errorf("Filter() dropped Load more: %+v", got)
+	}
+
+	// Below the threshold the folder is listed directly
+	router.BucketThreshold = 0
+	router.PageSize = 0
+	if items, _ := router.Browse("artists://"); len(items) != 9 {
+		t.Errorf("unbucketed browse returned %d items", len(items))
+	}
+}
+
+func TestBrowseResultMenu(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"navigation":{"prev":{"uri":"spotify"},
+			"info":{"uri":"spotify:artist:1","service":"spop","type":"artist","title":"Daft Punk"},
+			"lists":[
+				{"title":"Top tracks","items":[{"uri":"spotify:track:1","title":"Get Lucky","type":"song","service":"spop"}]},
+				{"title":"Albums","items":[{"uri":"spotify:album:1","title":"Discovery","type":"folder","service":"spop"}]}
+			]}}`))
+	}))
+	defer server.Close()
+
+	items, err := NewRouter(volumio.NewClient(server.URL)).Browse("spotify:artist:1")
+	if err != nil {
+		t.Fatalf("Browse() error = %v", err)
+	}
+	if len(items) != 5 {
+		t.Fatalf("Browse() = %+v", items)
+	}
+	if items[2].Label != "Daft Punk" || items[2].Icon != "👤" || !strings.HasPrefix(items[2].Action, "item:") {
+		t.Errorf("info header = %+v", items[2])
+	}
+	if items[3].Category != "Top tracks" || items[4].Category != "Albums" {
+		t.Errorf("categories = %q, %q", items[3].Category, items[4].Category)
+	}
+}
@@ -0,0 +1,208 @@
+package launcher
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode"
+)
+
+// Frame is one level of a navigation stack.
+type Frame struct {
+	View  string `json:"view"`            // browse: or search: action string
+	Title string `json:"title,omitempty"` // label of the entry that opened the view
+}
+
+// Navigator tracks the sub-menus a launcher session has opened. An empty
+// stack is the main menu.
+type Navigator struct {
+	Stack []Frame `json:"stack"`
+
+	// Returned is the view most recently left with Back. Its entry is
+	// marked in the parent menu so the user can pick up where they were.
+	Returned string `json:"returned,omitempty"`
+
+	// Labels maps the browse and search actions of the last menu shown to
+	// their labels, used to title the frame when one is selected.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// Current returns the innermost view, or "" for the main menu.
+func (n *Navigator) Current() string {
+	if len(n.Stack) == 0 {
+		return ""
+	}
+	return n.Stack[len(n.Stack)-1].View
+}
+
+// Select applies a selected action, titling any new view from the labels
+// of the last menu shown. See SelectTitled.
+func (n *Navigator) Select(r *Router, action string) (done bool, err error) {
+	return n.SelectTitled(r, action, n.Labels[action])
+}
+
+// SelectTitled applies a selected action. View and navigation actions
+// change the current view; anything else is run through the router
+// and reported as done, meaning the launcher can close.
+func (n *Navigator) SelectTitled(r *Router, action, title string) (done bool, err error) {
+	a, err := ParseAction(action)
+	if err != nil {
+		return false, err
+	}
+
+	if a.IsView() {
+		n.Returned = ""
+		n.Stack = append(n.Stack, Frame{View: a.String(), Title: title})
+		return false, nil
+	}
+
+	switch a.Kind {
+	case KindNav:
+		n.Returned = ""
+		switch a.Value {
+		case "back":
+			if len(n.Stack) > 0 {
+				n.Returned = n.Current()
+				n.Stack = n.Stack[:len(n.Stack)-1]
+			}
+		case "home":
+			n.Stack = nil
+		default:
+			return false, fmt.Errorf("unknown action: %s", action)
+		}
+		return false, nil
+	}
+
+	return true, r.Run(a)
+}
+
+// Breadcrumbs returns the path to the current view, e.g. "Library › NAS › Daft Punk".
+func (n *Navigator) Breadcrumbs() string {
+	parts := []string{"Volumio"}
+	for _, frame := range n.Stack {
+		parts = append(parts, frame.label())
+	}
+	return strings.Join(parts, " › ")
+}
+
+// label returns the frame's title, falling back to its URI or query.
+// Pages are always named after their bucket and position.
+func (f Frame) label() string {
+	a, err := ParseAction(f.View)
+	if err != nil {
+		return f.View
+	}
+	if a.Kind == KindPage {
+		ref, _ := ParsePageRef(a.Value)
+		name := ref.Bucket
+		if name == "" || name == AllBuckets {
+			name = "All"
+		}
+		if ref.Offset > 0 {
+			name += fmt.Sprintf(" (from %d)", ref.Offset+1)
+		}
+		return name
+	}
+	if f.Title != "" {
+		return f.Title
+	}
+	switch {
+	case a.Kind == KindSearch:
+		return "Search: " + a.Value
+	case a.Kind == KindItem:
+		if ref, err := ParseRef(a.Value); err == nil {
+			return ref.Title
+		}
+	case a.Kind == KindAddTo:
+		return "Add to Playlist"
+	case a.Value == "":
+		return "Library"
+	}
+	return a.Value
+}
+
+// Menu builds the items for the current view. Sub-menus start with a
+// breadcrumb header showing where the user is; inside a folder opened from
+// another menu, the header opens the folder's own action menu.
+func (n *Navigator) Menu(r *Router) ([]Item, error) {
+	var items []Item
+	current := n.Current()
+
+	if current == "" {
+		items = r.MainMenu()
+	} else {
+		a, err := ParseAction(current)
+		if err != nil {
+			return nil, err
+		}
+		if items, err = r.View(a); err != nil {
+			return nil, err
+		}
+
+		header := Item{Label: n.Breadcrumbs(), Sub: "Current location", Icon: "📍"}
+		if a.Kind == KindBrowse && len(n.Stack) > 1 {
+			header.Sub = "Current location · select for actions"
+			header.Action = ItemActions(Ref{URI: a.Value, Title: n.Stack[len(n.Stack)-1].label()})
+		}
+		items = append([]Item{header}, items...)
+	}
+
+	n.Labels = make(map[string]string)
+	for i, item := range items {
+		if a, err := ParseAction(item.Action); err == nil && a.IsView() {
+			n.Labels[item.Action] = item.Label
+		}
+		if n.Returned != "" && item.Action == n.Returned {
+			items[i].Icon = "▸"
+		}
+	}
+
+	return items, nil
+}
+
+// Encode serialises the stack into a single line of text, for launchers
+// that carry session state in an environment variable.
+func (n *Navigator) Encode() string {
+	parts := make([]string, 0, len(n.Stack)+1)
+	for _, frame := range n.Stack {
+		parts = append(parts, url.QueryEscape(frame.View)+","+url.QueryEscape(frame.Title))
+	}
+	if n.Returned != "" {
+		parts = append(parts, "<"+url.QueryEscape(n.Returned))
+	}
+	return strings.Join(parts, " ")
+}
+
+// DecodeNavigator restores a navigator from Encode's output.
+func DecodeNavigator(s string) *Navigator {
+	n := &Navigator{}
+	for _, part := range strings.Fields(s) {
+		if returned, ok := strings.CutPrefix(part, "<"); ok {
+			n.Returned, _ = url.QueryUnescape(returned)
+			continue
+		}
+		view, title, _ := strings.Cut(part, ",")
+		view, err := url.QueryUnescape(view)
+		if err != nil {
+			continue
+		}
+		title, _ = url.QueryUnescape(title)
+		n.Stack = append(n.Stack, Frame{View: view, Title: title})
+	}
+	return n
+}
+
+// TitleFromText strips a leading icon from a rendered line, recovering the
+// label for launchers that only report the selected text.
+func TitleFromText(text string) string {
+	first, rest, ok := strings.Cut(text, " ")
+	if !ok {
+		return text
+	}
+	for _, r := range first {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return text
+		}
+	}
+	return rest
+}
@@ -0,0 +1,286 @@
+package launcher
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/riclib/volu/internal/volumio"
+)
+
+// Defaults for splitting up large folders.
+const (
+	DefaultPageSize        = 100 // items shown before a "Load more…" entry
+	DefaultBucketThreshold = 500 // folders larger than this are grouped A–Z
+	DefaultVolumeStep      = 10  // percent changed by volume up/down
+)
+
+// Router executes launcher actions against a Volumio client.
+// Navigation (nav:) is launcher-specific and is left to the caller.
+type Router struct {
+	client volumio.Player
+
+	// PageSize limits how many items a browse menu shows at once; zero
+	// shows everything.
+	PageSize int
+
+	// BucketThreshold is the size above which folders of artists, albums
+	// and other sub-folders are first shown as A–Z buckets; zero disables
+	// grouping.
+	BucketThreshold int
+
+	// Presets are the web radio presets listed in the main menu.
+	Presets []Preset
+
+	// VolumeStep is the percentage volume up and down change the volume by.
+	VolumeStep int
+
+	// Fade sets how play, pause, stop and toggle fade; the zero value
+	// switches instantly.
+	Fade volumio.TransportFade
+}
+
+// NewRouter creates a router for the given client.
+func NewRouter(client volumio.Player) *Router {
+	return &Router{
+		client:          client,
+		PageSize:        DefaultPageSize,
+		BucketThreshold: DefaultBucketThreshold,
+		VolumeStep:      DefaultVolumeStep,
+	}
+}
+
+// Run executes a command, play, queue, next, playlist or favourite
+// action. Views and navigation produce menus rather than side effects and
+// are rejected.
+func (r *Router) Run(a Action) error {
+	return r.RunContext(context.Background(), a)
+}
+
+// RunContext is Run with a context that stops fades early, restoring the
+// volume, e.g. when volu is interrupted.
+func (r *Router) RunContext(ctx context.Context, a Action) error {
+	switch a.Kind {
+	case KindCommand:
+		return r.command(ctx, a.Value)
+	case KindPlay:
+		return r.client.ReplaceAndPlay(a.Value, a.Service)
+	case KindQueue:
+		return r.client.AddToQueue(a.Value, a.Service)
+	case KindNext:
+		return r.client.PlayNext(a.Value, a.Service)
+	case KindPlaylist:
+		return r.client.AddToPlaylist(a.Playlist, a.Value, a.Service)
+	case KindFavourite:
+		return r.client.AddToFavourites(a.Value, a.Service)
+	}
+	return fmt.Errorf("cannot run %s action: %s", a.Kind, a)
+}
+
+// RunString parses and executes an action string.
+func (r *Router) RunString(s string) error {
+	a, err := ParseAction(s)
+	if err != nil {
+		return err
+	}
+	return r.Run(a)
+}
+
+// command runs a transport, volume or mode command.
+func (r *Router) command(ctx context.Context, cmd string) error {
+	switch cmd {
+	case "toggle":
+		return r.client.Toggle(ctx, r.Fade)
+	case "play":
+		return r.client.FadeIn(ctx, r.Fade.In, r.Fade.Curve, r.client.Play)
+	case "pause":
+		return r.client.FadeOut(ctx, r.Fade.Out, r.Fade.Curve, r.client.Pause)
+	case "stop":
+		return r.client.FadeOut(ctx, r.Fade.Out, r.Fade.Curve, r.client.Stop)
+	case "next":
+		return r.client.Next()
+	case "prev":
+		return r.client.Previous()
+	case "volup":
+		return r.client.VolumeUp(r.VolumeStep)
+	case "voldown":
+		return r.client.VolumeDown(r.VolumeStep)
+	case "mute":
+		return r.client.ToggleMute()
+	case "shuffle":
+		return r.client.ToggleRandom()
+	case "repeat":
+		return r.client.ToggleRepeat()
+	default:
+		return fmt.Errorf("unknown command: %s", cmd)
+	}
+}
+
+// MainMenu builds the main menu for the current player state.
+func (r *Router) MainMenu() []Item {
+	return MainMenu(r.State(), r.MenuOptions())
+}
+
+// MenuOptions returns the router's main menu settings.
+func (r *Router) MenuOptions() MenuOptions {
+	return MenuOptions{Presets: r.Presets, VolumeStep: r.VolumeStep}
+}
+
+// State returns the current player state, or nil if it cannot be fetched.
+func (r *Router) State() *volumio.PlayerState {
+	state, err := r.client.GetState()
+	if err != nil {
+		return nil
+	}
+	return state
+}
+
+// View builds the menu opened by a view action (browse, search, item or
+// addto). Sub-menus start with the Back and Main Menu entries.
+func (r *Router) View(a Action) ([]Item, error) {
+	switch a.Kind {
+	case KindBrowse:
+		return r.Browse(a.Value)
+	case KindSearch:
+		results, err := r.Search(a.Value)
+		if err != nil {
+			return nil, err
+		}
+		if len(results) == 0 {
+			results = []Item{{Label: "No results for " + a.Value, Icon: "❌"}}
+		}
+		return append(navItems(), results...), nil
+	case KindItem:
+		ref, err := ParseRef(a.Value)
+		if err != nil {
+			return nil, err
+		}
+		return ItemMenu(ref), nil
+	case KindAddTo:
+		ref, err := ParseRef(a.Value)
+		if err != nil {
+			return nil, err
+		}
+		return r.PlaylistMenu(ref)
+	case KindPage:
+		ref, err := ParsePageRef(a.Value)
+		if err != nil {
+			return nil, err
+		}
+		return r.BrowsePage(ref)
+	}
+	return nil, fmt.Errorf("cannot show %s action: %s", a.Kind, a)
+}
+
+// PlaylistMenu lists the stored playlists, each adding ref to it.
+func (r *Router) PlaylistMenu(ref Ref) ([]Item, error) {
+	names, err := r.client.ListPlaylists()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list playlists: %w", err)
+	}
+
+	menu := navItems()
+	if len(names) == 0 {
+		return append(menu, Item{Label: "No playlists", Sub: "Create one in the Volumio web UI", Icon: "❌"}), nil
+	}
+	for _, name := range names {
+		menu = append(menu, Item{
+			Label:      name,
+			Sub:        "Add " + ref.Title,
+			Icon:       "📋",
+			Action:     ToPlaylist(name, ref.URI, ref.Service),
+			Searchable: true,
+		})
+	}
+	return menu, nil
+}
+
+// Browse lists the folder at uri as a browse menu, with album art
+// resolved to absolute URLs. Large folders are split into pages or A–Z
+// buckets, see BrowsePage.
+func (r *Router) Browse(uri string) ([]Item, error) {
+	return r.BrowsePage(PageRef{URI: uri})
+}
+
+// BrowsePage lists one page of the folder at ref.URI. Without a bucket,
+// a folder of more than BucketThreshold sub-folders is shown as A–Z
+// buckets instead; otherwise, a page that does not reach the end of the
+// listing ends with a "Load more…" entry for the next one. The first page
+// starts with the folder's album, artist or playlist header, if any.
+func (r *Router) BrowsePage(ref PageRef) ([]Item, error) {
+	result, err := r.client.BrowseResult(ref.URI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to browse: %w", err)
+	}
+
+	all := result.Items()
+	entries := ResultItems(result)
+	switch ref.Bucket {
+	case "":
+		if r.BucketThreshold > 0 && len(all) > r.BucketThreshold && !hasTracks(all) {
+			return BucketMenu(ref.URI, all), nil
+		}
+	case AllBuckets:
+	default:
+		var matches []Item
+		for _, entry := range entries {
+			if Bucket(entry.Label) == ref.Bucket {
+				matches = append(matches, entry)
+			}
+		}
+		entries = matches
+	}
+
+	menu := navItems()
+	if result.Info != nil && ref.Offset == 0 && ref.Bucket == "" {
+		menu = append(menu, InfoItem(result.Info))
+	}
+	if len(entries) == 0 {
+		menu = append(menu, Item{Label: "No items found", Sub: "This folder is empty", Icon: "❌"})
+	}
+
+	start := min(ref.Offset, len(entries))
+	end := len(entries)
+	if r.PageSize > 0 {
+		end = min(start+r.PageSize, end)
+	}
+	menu = append(menu, entries[start:end]...)
+
+	if end < len(entries) {
+		next := ref
+		next.Offset = end
+		menu = append(menu, Item{
+			Label:  "Load more…",
+			Sub:    fmt.Sprintf("Showing %d–%d of %d", start+1, end, len(entries)),
+			Icon:   "⏬",
+			Action: Page(next),
+		})
+	}
+	return r.resolveArt(menu), nil
+}
+
+// Search runs a live library search and returns one item per result,
+// categorised by result list (Artists, Albums, Tracks, ...).
+func (r *Router) Search(query string) ([]Item, error) {
+	response, err := r.client.Search(query)
+	if err != nil {
+		return nil, fmt.Errorf("search failed: %w", err)
+	}
+
+	var items []Item
+	for _, list := range response.Navigation.Lists {
+		for _, result := range list.Items {
+			item := ItemFor(result)
+			item.Category = list.Title
+			items = append(items, item)
+		}
+	}
+	return r.resolveArt(items), nil
+}
+
+// resolveArt replaces relative album art paths with absolute URLs.
+func (r *Router) resolveArt(items []Item) []Item {
+	for i := range items {
+		items[i].Image = r.client.GetAlbumArtURL(items[i].Image)
+	}
+	return items
+}
@@ -0,0 +1,274 @@
+package library
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/riclib/volu/internal/atomicfile"
+	"github.com/riclib/volu/internal/volumio"
+)
+
+// DefaultRoot is the browse URI of Volumio's local music library.
+const DefaultRoot = "music-library"
+
+// Kind identifies the type of an index entry.
+type Kind string
+
+const (
+	KindAlbum  Kind = "album"
+	KindArtist Kind = "artist"
+	KindTrack  Kind = "track"
+)
+
+// Entry is a single album, artist or track in the library index.
+type Entry struct {
+	Kind     Kind   `json:"kind"`
+	URI      string `json:"uri"`
+	Title    string `json:"title"`
+	Artist   string `json:"artist,omitempty"`
+	Album    string `json:"album,omitempty"`
+	Service  string `json:"service,omitempty"`
+	AlbumArt string `json:"albumart,omitempty"`
+	Parent   string `json:"parent,omitempty"`
+}
+
+// Item converts the entry into a playable browse item.
+func (e Entry) Item() volumio.BrowseItem {
+	itemType := "song"
+	if e.Kind != KindTrack {
+		itemType = "folder"
+	}
+	return volumio.BrowseItem{
+		URI:      e.URI,
+		Title:    e.Title,
+		Service:  e.Service,
+		Type:     itemType,
+		Artist:   e.Artist,
+		Album:    e.Album,
+		AlbumArt: e.AlbumArt,
+	}
+}
+
+// Folder is a browsed folder as recorded by the last sync.
+type Folder struct {
+	URI      string    `json:"uri"`
+	Title    string    `json:"title"`
+	Parent   string    `json:"parent,omitempty"`
+	Children []string  `json:"children,omitempty"`
+	Tracks   []Entry   `json:"tracks,omitempty"`
+	ModTime  time.Time `json:"mtime"`
+}
+
+// Leaf reports whether the folder contains no subfolders.
+func (f *Folder) Leaf() bool {
+	return len(f.Children) == 0
+}
+
+// Index is a local on-disk copy of the Volumio music library.
+type Index struct {
+	Host     string             `json:"host"`
+	Roots    []string           `json:"roots"`
+	SyncedAt time.Time          `json:"synced_at"`
+	Folders  map[string]*Folder `json:"folders"`
+
+	albums  []Entry
+	artists []Entry
+	tracks  []Entry
+}
+
+// New creates an empty index for the given host.
+func New(host string) *Index {
+	return &Index{
+		Host:    host,
+		Folders: make(map[string]*Folder),
+	}
+}
+
+// DefaultPath returns the index file location for a host.
+// On Linux, this is typically ~/.cache/volu/library-<host>.json.
+func DefaultPath(host string) (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user cache directory: %w", err)
+	}
+	return filepath.Join(cacheDir, "volu", "library-"+volumio.HostFileName(host)+".json"), nil
+}
+
+// Load reads an index from disk.
+// Returns an error wrapping os.ErrNotExist if the library was never synced.
+func Load(path string) (*Index, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read library index: %w", err)
+	}
+
+	idx := New("")
+	if err := json.Unmarshal(data, idx); err != nil {
+		return nil, fmt.Errorf("failed to parse library index: %w", err)
+	}
+	if idx.Folders == nil {
+		idx.Folders = make(map[string]*Folder)
+	}
+	idx.rebuild()
+
+	return idx, nil
+}
+
+// Save writes the index to disk atomically.
+func (idx *Index) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return fmt.Errorf("failed to marshal library index: %w", err)
+	}
+
+	if err := atomicfile.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write library index: %w", err)
+	}
+
+	return nil
+}
+
+// Albums returns every album in the index, sorted by title.
+func (idx *Index) Albums() []Entry {
+	return idx.albums
+}
+
+// Artists returns every artist in the index, sorted by name.
+func (idx *Index) Artists() []Entry {
+	return idx.artists
+}
+
+// Tracks returns every track in the index, in library order.
+func (idx *Index) Tracks() []Entry {
+	return idx.tracks
+}
+
+// AlbumsMatching returns albums whose title, artist or URI contains query, case-insensitive.
+func (idx *Index) AlbumsMatching(query string) []Entry {
+	query = strings.ToLower(query)
+	var matches []Entry
+	for _, album := range idx.albums {
+		if strings.Contains(strings.ToLower(album.Title), query) ||
+			strings.Contains(strings.ToLower(album.Artist), query) ||
+			strings.Contains(strings.ToLower(album.URI), query) {
+			matches = append(matches, album)
+		}
+	}
+	return matches
+}
+
+// rebuild derives the album, artist and track lists from the folder tree.
+func (idx *Index) rebuild() {
+	idx.albums = nil
+	idx.artists = nil
+	idx.tracks = nil
+
+	uris := make([]string, 0, len(idx.Folders))
+	for uri := range idx.Folders {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+
+	artists := make(map[string]bool)
+	for _, uri := range uris {
+		folder := idx.Folders[uri]
+		if len(folder.Tracks) == 0 {
+			continue
+		}
+
+		idx.tracks = append(idx.tracks, folder.Tracks...)
+		idx.albums = append(idx.albums, albumFromFolder(folder))
+
+		for _, track := range folder.Tracks {
+			if track.Artist != "" && !artists[track.Artist] {
+				artists[track.Artist] = true
+				idx.artists = append(idx.artists, artistFromTrack(track, folder))
+			}
+		}
+	}
+
+	sort.SliceStable(idx.albums, func(i, j int) bool {
+		return strings.ToLower(idx.albums[i].Title) < strings.ToLower(idx.albums[j].Title)
+	})
+	sort.SliceStable(idx.artists, func(i, j int) bool {
+		return strings.ToLower(idx.artists[i].Title) < strings.ToLower(idx.artists[j].Title)
+	})
+}
+
+// artistFromTrack builds an artist entry for the artist of a track in
+// folder. MPD artists open Volumio's artist view; other services have no
+// such view, so their artists open the folder holding the album.
+func artistFromTrack(track Entry, folder *Folder) Entry {
+	artist := Entry{
+		Kind:    KindArtist,
+		URI:     "artists://" + track.Artist,
+		Title:   track.Artist,
+		Service: serviceOf(track),
+	}
+	if artist.Service != "mpd" {
+		artist.URI = folder.URI
+		if folder.Parent != "" {
+			artist.URI = folder.Parent
+		}
+	}
+	return artist
+}
+
+// serviceOf returns the service playing a track; tracks indexed without
+// one are local files.
+func serviceOf(track Entry) string {
+	if track.Service == "" {
+		return "mpd"
+	}
+	return track.Service
+}
+
+// albumFromFolder builds an album entry for a folder that directly contains tracks.
+// The album title comes from the tracks' tags when they agree, otherwise the folder name.
+func albumFromFolder(folder *Folder) Entry {
+	album := Entry{
+		Kind:    KindAlbum,
+		URI:     folder.URI,
+		Title:   folder.Title,
+		Service: serviceOf(folder.Tracks[0]),
+		Parent:  folder.Parent,
+	}
+
+	first := folder.Tracks[0]
+	sameAlbum, sameArtist := true, true
+	for _, track := range folder.Tracks[1:] {
+		if track.Album != first.Album {
+			sameAlbum = false
+		}
+		if track.Artist != first.Artist {
+			sameArtist = false
+		}
+	}
+	if sameAlbum && first.Album != "" {
+		album.Album = first.Album
+	}
+	if sameArtist {
+		album.Artist = first.Artist
+	}
+	if album.Title == "" {
+		album.Title = album.Album
+	}
+
+	for _, track := range folder.Tracks {
+		if track.AlbumArt != "" {
+			album.AlbumArt = track.AlbumArt
+			break
+		}
+	}
+
+	return album
+}
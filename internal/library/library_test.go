@@ -0,0 +1,217 @@
+package library
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/riclib/volu/internal/volumio"
+)
+
+// fakeBrowser serves a fixed folder tree and counts Browse calls per URI.
+type fakeBrowser struct {
+	tree  map[string][]volumio.BrowseItem
+	calls map[string]int
+}
+
+func (f *fakeBrowser) Browse(uri string) ([]volumio.BrowseItem, error) {
+	if f.calls == nil {
+		f.calls = make(map[string]int)
+	}
+	f.calls[uri]++
+	items, ok := f.tree[uri]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return items, nil
+}
+
+func newTestTree() *fakeBrowser {
+	return &fakeBrowser{tree: map[string][]volumio.BrowseItem{
+		"music-library": {
+			{URI: "music-library/NAS", Title: "NAS", Type: "folder", Service: "mpd"},
+		},
+		"music-library/NAS": {
+			{URI: "music-library/NAS/ASOT 1090", Title: "ASOT 1090", Type: "folder", Service: "mpd"},
+			{URI: "music-library/NAS/Daft Punk", Title: "Daft Punk", Type: "folder", Service: "mpd"},
+		},
+		"music-library/NAS/ASOT 1090": {
+			{URI: "music-library/NAS/ASOT 1090/01.flac", Title: "Intro", Type: "song", Service: "mpd", Artist: "Armin van Buuren", Album: "ASOT 1090"},
+			{URI: "music-library/NAS/ASOT 1090/02.flac", Title: "Blah", Type: "song", Service: "mpd", Artist: "Armin van Buuren", Album: "ASOT 1090"},
+		},
+		"music-library/NAS/Daft Punk": {
+			{URI: "music-library/NAS/Daft Punk/Discovery", Title: "Discovery", Type: "folder", Service: "mpd"},
+		},
+		"music-library/NAS/Daft Punk/Discovery": {
+			{URI: "music-library/NAS/Daft Punk/Discovery/01.flac", Title: "One More Time", Type: "song", Service: "mpd", Artist: "Daft Punk", Album: "Discovery"},
+		},
+	}}
+}
+
+func TestSync(t *testing.T) {
+	browser := newTestTree()
+	idx := New("volumio.local")
+
+	stats, err := idx.Sync(browser, SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	if stats.Browsed != 5 {
+		t.Errorf("Browsed = %d, want 5", stats.Browsed)
+	}
+	if len(idx.Tracks()) != 3 {
+		t.Errorf("Tracks = %d, want 3", len(idx.Tracks()))
+	}
+
+	albums := idx.Albums()
+	if len(albums) != 2 {
+		t.Fatalf("Albums = %d, want 2", len(albums))
+	}
+	if albums[0].Title != "ASOT 1090" || albums[0].Artist != "Armin van Buuren" {
+		t.Errorf("albums[0] = %+v", albums[0])
+	}
+	if albums[1].URI != "music-library/NAS/Daft Punk/Discovery" {
+		t.Errorf("albums[1].URI = %q", albums[1].URI)
+	}
+
+	artists := idx.Artists()
+	if len(artists) != 2 || artists[0].Title != "Armin van Buuren" || artists[1].URI != "artists://Daft Punk" {
+		t.Errorf("Artists = %+v", artists)
+	}
+}
+
+func TestSyncIncremental(t *testing.T) {
+	browser := newTestTree()
+	idx := New("volumio.local")
+	if _, err := idx.Sync(browser, SyncOptions{}); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	// Add a new album and remove an existing one
+	browser.tree["music-library/NAS"] = []volumio.BrowseItem{
+		{URI: "music-library/NAS/ASOT 1090", Title: "ASOT 1090", Type: "folder"},
+		{URI: "music-library/NAS/ASOT 1091", Title: "ASOT 1091", Type: "folder"},
+	}
+	browser.tree["music-library/NAS/ASOT 1091"] = []volumio.BrowseItem{
+		{URI: "music-library/NAS/ASOT 1091/01.flac", Title: "Intro", Type: "song", Album: "ASOT 1091"},
+	}
+
+	stats, err := idx.Sync(browser, SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	if stats.Reused != 1 {
+		t.Errorf("Reused = %d, want 1", stats.Reused)
+	}
+	if stats.Removed != 2 {
+		t.Errorf("Removed = %d, want 2", stats.Removed)
+	}
+	if browser.calls["music-library/NAS/ASOT 1090"] != 1 {
+		t.Errorf("known album browsed %d times, want 1", browser.calls["music-library/NAS/ASOT 1090"])
+	}
+	if got := len(idx.Albums()); got != 2 {
+		t.Errorf("Albums = %d, want 2", got)
+	}
+
+	// A full sync browses everything again
+	if _, err := idx.Sync(browser, SyncOptions{Full: true}); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if browser.calls["music-library/NAS/ASOT 1090"] != 2 {
+		t.Errorf("full sync did not re-browse known album")
+	}
+}
+
+func TestSyncMaxAge(t *testing.T) {
+	browser := newTestTree()
+	idx := New("volumio.local")
+	if _, err := idx.Sync(browser, SyncOptions{}); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	for _, folder := range idx.Folders {
+		folder.ModTime = folder.ModTime.Add(-48 * time.Hour)
+	}
+
+	// Retag a track in a known album
+	browser.tree["music-library/NAS/ASOT 1090"][1].Title = "Blah (Extended Mix)"
+
+	stats, err := idx.Sync(browser, SyncOptions{MaxAge: 24 * time.Hour})
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if stats.Reused != 0 {
+		t.Errorf("Reused = %d, want 0 for stale folders", stats.Reused)
+	}
+	if got := idx.Folders["music-library/NAS/ASOT 1090"].Tracks[1].Title; got != "Blah (Extended Mix)" {
+		t.Errorf("retagged track title = %q, want the new tag", got)
+	}
+}
+
+func TestRebuildKeepsService(t *testing.T) {
+	idx := New("volumio.local")
+	idx.Folders = map[string]*Folder{
+		"spotify/artist/daftpunk/discovery": {
+			URI:    "spotify/artist/daftpunk/discovery",
+			Title:  "Discovery",
+			Parent: "spotify/artist/daftpunk",
+			Tracks: []Entry{{Kind: KindTrack, URI: "spotify:track:1", Title: "One More Time", Artist: "Daft Punk", Service: "spop"}},
+		},
+	}
+	idx.rebuild()
+
+	albums, artists := idx.Albums(), idx.Artists()
+	if len(albums) != 1 || albums[0].Service != "spop" {
+		t.Errorf("Albums = %+v, want a spop album", albums)
+	}
+	if len(artists) != 1 || artists[0].Service != "spop" || artists[0].URI != "spotify/artist/daftpunk" {
+		t.Errorf("Artists = %+v, want a spop artist opening its folder", artists)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	idx := New("volumio.local")
+	if _, err := idx.Sync(newTestTree(), SyncOptions{}); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "library.json")
+	if err := idx.Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if loaded.Host != "volumio.local" {
+		t.Errorf("Host = %q", loaded.Host)
+	}
+	if len(loaded.Albums()) != 2 || len(loaded.Tracks()) != 3 {
+		t.Errorf("loaded %d albums, %d tracks", len(loaded.Albums()), len(loaded.Tracks()))
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Load(missing) error = %v, want os.ErrNotExist", err)
+	}
+}
+
+func TestAlbumsMatching(t *testing.T) {
+	idx := New("volumio.local")
+	if _, err := idx.Sync(newTestTree(), SyncOptions{}); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	matches := idx.AlbumsMatching("asot")
+	if len(matches) != 1 || matches[0].Title != "ASOT 1090" {
+		t.Errorf("AlbumsMatching(asot) = %+v", matches)
+	}
+
+	item := matches[0].Item()
+	if item.Type != "folder" || item.Service != "mpd" {
+		t.Errorf("Item() = %+v", item)
+	}
+}
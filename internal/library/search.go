@@ -0,0 +1,184 @@
+package library
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/riclib/volu/internal/volumio"
+)
+
+// Field weights used when ranking matches.
+const (
+	weightTitle  = 3.0
+	weightArtist = 2.0
+	weightAlbum  = 1.5
+)
+
+// Match quality for a single query token against a single word.
+const (
+	qualityExact     = 1.0
+	qualityPrefix    = 0.8
+	qualitySubstring = 0.5
+	qualityTypo      = 0.4
+)
+
+// Result is a ranked search hit.
+type Result struct {
+	Entry Entry
+	Score float64
+}
+
+// Item returns the playable browse item for the result.
+func (r Result) Item() volumio.BrowseItem {
+	return r.Entry.Item()
+}
+
+// Find performs a fuzzy search over albums, artists and tracks.
+//
+// Every query token must match a word in the title, artist or album, either
+// exactly, as a prefix, as a substring or within a small edit distance. Hits
+// are ranked by match quality weighted by field (title over artist over
+// album), with albums and artists ahead of tracks on ties. A limit of zero
+// returns all results.
+func (idx *Index) Find(query string, limit int) []Result {
+	tokens := tokenize(query)
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	var results []Result
+	collect := func(entries []Entry, bonus float64) {
+		for _, entry := range entries {
+			if score, ok := scoreEntry(entry, tokens); ok {
+				results = append(results, Result{Entry: entry, Score: score + bonus})
+			}
+		}
+	}
+	collect(idx.artists, 0.2)
+	collect(idx.albums, 0.1)
+	collect(idx.tracks, 0)
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// scoreEntry returns the score for an entry, and false if any token fails to match.
+func scoreEntry(entry Entry, tokens []string) (float64, bool) {
+	fields := []struct {
+		words  []string
+		weight float64
+	}{
+		{tokenize(entry.Title), weightTitle},
+		{tokenize(entry.Artist), weightArtist},
+		{tokenize(entry.Album), weightAlbum},
+	}
+
+	total := 0.0
+	for _, token := range tokens {
+		best := 0.0
+		for _, field := range fields {
+			if q := matchWords(token, field.words) * field.weight; q > best {
+				best = q
+			}
+		}
+		if best == 0 {
+			return 0, false
+		}
+		total += best
+	}
+
+	// Prefer entries whose title is fully covered by the query
+	if words := fields[0].words; len(words) > 0 {
+		total += float64(len(tokens)) / float64(len(words)+len(tokens))
+	}
+
+	return total, true
+}
+
+// matchWords returns the best match quality of token against any of words.
+func matchWords(token string, words []string) float64 {
+	best := 0.0
+	for _, word := range words {
+		q := 0.0
+		switch {
+		case word == token:
+			q = qualityExact
+		case strings.HasPrefix(word, token):
+			q = qualityPrefix
+		case len(token) >= 3 && strings.Contains(word, token):
+			q = qualitySubstring
+		case withinTypos(token, word):
+			q = qualityTypo
+		}
+		if q > best {
+			best = q
+			if best == qualityExact {
+				break
+			}
+		}
+	}
+	return best
+}
+
+// withinTypos reports whether token is close enough to word, or to a prefix
+// of word, to count as a typo: one edit for 4+ runes, two for 8+.
+func withinTypos(token, word string) bool {
+	t := []rune(token)
+	allowed := 0
+	switch {
+	case len(t) >= 8:
+		allowed = 2
+	case len(t) >= 4:
+		allowed = 1
+	default:
+		return false
+	}
+
+	w := []rune(word)
+	if len(w) > len(t)+allowed {
+		w = w[:len(t)+allowed]
+	}
+	return editDistance(t, w) <= allowed
+}
+
+// editDistance computes the optimal string alignment distance between a and b,
+// counting adjacent transpositions as a single edit.
+func editDistance(a, b []rune) int {
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+
+	return prev[len(b)]
+}
+
+// tokenize lowercases s and splits it into words on anything that isn't a letter or digit.
+func tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,91 @@
+package library
+
+import (
+	"testing"
+)
+
+func newSearchIndex(t *testing.T) *Index {
+	t.Helper()
+	idx := New("volumio.local")
+	if _, err := idx.Sync(newTestTree(), SyncOptions{}); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	return idx
+}
+
+func TestFind(t *testing.T) {
+	idx := newSearchIndex(t)
+
+	tests := []struct {
+		name      string
+		query     string
+		wantFirst string
+		wantKind  Kind
+	}{
+		{"exact artist", "daft punk", "Daft Punk", KindArtist},
+		{"prefix", "disco", "Discovery", KindAlbum},
+		{"typo", "dicsovery", "Discovery", KindAlbum},
+		{"track title", "one more time", "One More Time", KindTrack},
+		{"title and artist", "one more daft", "One More Time", KindTrack},
+		{"number", "asot 1090", "ASOT 1090", KindAlbum},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := idx.Find(tt.query, 0)
+			if len(results) == 0 {
+				t.Fatalf("Find(%q) returned no results", tt.query)
+			}
+			first := results[0].Entry
+			if first.Title != tt.wantFirst || first.Kind != tt.wantKind {
+				t.Errorf("Find(%q)[0] = %s %q, want %s %q", tt.query, first.Kind, first.Title, tt.wantKind, tt.wantFirst)
+			}
+		})
+	}
+}
+
+func TestFindNoMatch(t *testing.T) {
+	idx := newSearchIndex(t)
+
+	if results := idx.Find("metallica", 0); len(results) != 0 {
+		t.Errorf("Find(metallica) = %+v, want none", results)
+	}
+	if results := idx.Find("  ", 0); results != nil {
+		t.Errorf("Find(blank) = %+v, want nil", results)
+	}
+	// All tokens must match
+	if results := idx.Find("daft asot", 0); len(results) != 0 {
+		t.Errorf("Find(daft asot) = %+v, want none", results)
+	}
+}
+
+func TestFindLimit(t *testing.T) {
+	idx := newSearchIndex(t)
+
+	results := idx.Find("armin", 2)
+	if len(results) != 2 {
+		t.Fatalf("Find(armin, 2) returned %d results", len(results))
+	}
+	if results[0].Entry.Kind != KindArtist {
+		t.Errorf("expected artist first, got %s", results[0].Entry.Kind)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"punk", "punk", 0},
+		{"pnuk", "punk", 1},
+		{"pun", "punk", 1},
+		{"kitten", "sitting", 3},
+		{"", "abc", 3},
+	}
+
+	for _, tt := range tests {
+		if got := editDistance([]rune(tt.a), []rune(tt.b)); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
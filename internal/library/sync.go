@@ -0,0 +1,158 @@
+package library
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/riclib/volu/internal/volumio"
+)
+
+// DefaultMaxAge is how long 'volu library sync' reuses an album folder
+// before browsing it again, so added or retagged tracks show up.
+const DefaultMaxAge = 7 * 24 * time.Hour
+
+// Browser lists the contents of a library URI.
+// *volumio.Client satisfies this interface.
+type Browser interface {
+	Browse(uri string) ([]volumio.BrowseItem, error)
+}
+
+// SyncOptions controls how Sync walks the library.
+type SyncOptions struct {
+	// Roots are the browse URIs to index. Defaults to DefaultRoot.
+	Roots []string
+	// Full re-browses every folder instead of reusing known album folders.
+	Full bool
+	// MaxAge forces album folders older than this to be re-browsed during
+	// an incremental sync, picking up added or retagged tracks. Zero means
+	// album folders are never refreshed.
+	MaxAge time.Duration
+	// Progress, if set, is called with each folder URI as it is browsed.
+	Progress func(uri string)
+}
+
+// SyncStats summarises a sync run.
+type SyncStats struct {
+	Browsed int // Folders fetched from Volumio
+	Reused  int // Album folders kept from the previous index
+	Removed int // Folders that disappeared from the library
+}
+
+// Sync walks the library with b and updates the index in place.
+//
+// An incremental sync (the default) still browses every folder that
+// contains subfolders, so new artists and albums are discovered, but
+// reuses album folders (folders with only tracks) indexed less than
+// MaxAge ago. Volumio's listings carry no modification time, so changes
+// inside a reused album folder are only seen once it is re-browsed.
+// Folders that are no longer reachable are dropped.
+func (idx *Index) Sync(b Browser, opts SyncOptions) (SyncStats, error) {
+	roots := opts.Roots
+	if len(roots) == 0 {
+		roots = []string{DefaultRoot}
+	}
+
+	s := &syncer{
+		browser: b,
+		opts:    opts,
+		old:     idx.Folders,
+		folders: make(map[string]*Folder),
+		now:     time.Now(),
+	}
+	if opts.Full {
+		s.old = map[string]*Folder{}
+	}
+
+	for _, root := range roots {
+		if err := s.walk(root, root, ""); err != nil {
+			return s.stats, err
+		}
+	}
+
+	for uri := range idx.Folders {
+		if _, ok := s.folders[uri]; !ok {
+			s.stats.Removed++
+		}
+	}
+
+	idx.Roots = roots
+	idx.Folders = s.folders
+	idx.SyncedAt = s.now
+	idx.rebuild()
+
+	return s.stats, nil
+}
+
+// syncer holds the state of a single Sync run.
+type syncer struct {
+	browser Browser
+	opts    SyncOptions
+	old     map[string]*Folder
+	folders map[string]*Folder
+	now     time.Time
+	stats   SyncStats
+}
+
+func (s *syncer) walk(uri, title, parent string) error {
+	if _, seen := s.folders[uri]; seen {
+		return nil
+	}
+
+	if prev, ok := s.old[uri]; ok && prev.Leaf() && !s.stale(prev) {
+		prev.Parent = parent
+		s.folders[uri] = prev
+		s.stats.Reused++
+		return nil
+	}
+
+	if s.opts.Progress != nil {
+		s.opts.Progress(uri)
+	}
+
+	items, err := s.browser.Browse(uri)
+	if err != nil {
+		return fmt.Errorf("failed to browse %s: %w", uri, err)
+	}
+	s.stats.Browsed++
+
+	folder := &Folder{
+		URI:     uri,
+		Title:   title,
+		Parent:  parent,
+		ModTime: s.now,
+	}
+	s.folders[uri] = folder
+
+	var subfolders []volumio.BrowseItem
+	for _, item := range items {
+		switch {
+		case item.Type == "song" || item.Type == "track":
+			folder.Tracks = append(folder.Tracks, Entry{
+				Kind:     KindTrack,
+				URI:      item.URI,
+				Title:    item.DisplayName(),
+				Artist:   item.Artist,
+				Album:    item.Album,
+				Service:  item.Service,
+				AlbumArt: item.AlbumArt,
+				Parent:   uri,
+			})
+		case item.IsBrowsable() && item.URI != "" && item.URI != uri:
+			folder.Children = append(folder.Children, item.URI)
+			subfolders = append(subfolders, item)
+		}
+	}
+
+	for _, sub := range subfolders {
+		if err := s.walk(sub.URI, sub.DisplayName(), uri); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// stale reports whether a reusable folder is older than MaxAge.
+func (s *syncer) stale(f *Folder) bool {
+	return s.opts.MaxAge > 0 && s.now.Sub(f.ModTime) > s.opts.MaxAge
+}
@@ -0,0 +1,155 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// The freedesktop.org notification service.
+const (
+	busName = "org.freedesktop.Notifications"
+	busPath = "/org/freedesktop/Notifications"
+)
+
+// caller is the part of a D-Bus object used to send notifications.
+type caller interface {
+	Call(method string, flags dbus.Flags, args ...interface{}) *dbus.Call
+}
+
+// DBus shows notifications through org.freedesktop.Notifications on the
+// session bus, replacing the previous notification of the same category.
+type DBus struct {
+	conn    *dbus.Conn
+	obj     caller
+	Timeout time.Duration
+	IDs     *IDStore // replace IDs per category; nil to always stack
+}
+
+// NewDBus connects to the session bus. A zero timeout means
+// DefaultTimeout.
+func NewDBus(timeout time.Duration) (*DBus, error) {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to session bus: %w", err)
+	}
+	return &DBus{
+		conn:    conn,
+		obj:     conn.Object(busName, busPath),
+		Timeout: timeout,
+		IDs:     DefaultIDStore(),
+	}, nil
+}
+
+// Notify shows n.
+func (d *DBus) Notify(n Notification) error {
+	_, err := d.send(n)
+	return err
+}
+
+// send shows n and returns its ID.
+func (d *DBus) send(n Notification) (uint32, error) {
+	actions := []string{}
+	for _, action := range n.Actions {
+		actions = append(actions, action.Key, action.Label)
+	}
+
+	urgency := byte(1)
+	if n.Urgent() {
+		urgency = 2
+	}
+	hints := map[string]dbus.Variant{"urgency": dbus.MakeVariant(urgency)}
+	if n.Image != "" {
+		hints["image-path"] = dbus.MakeVariant("file://" + n.Image)
+	}
+	if n.Category != "" {
+		hints["x-dunst-stack-tag"] = dbus.MakeVariant("volu-" + n.Category)
+	}
+
+	var replaces uint32
+	if d.IDs != nil && n.Category != "" {
+		replaces = d.IDs.Get(n.Category)
+	}
+
+	call := d.obj.Call(busName+".Notify", 0,
+		"volu", replaces, n.Icon, n.Title, n.Body, actions, hints, int32(d.Timeout.Milliseconds()))
+	var id uint32
+	if err := call.Store(&id); err != nil {
+		return 0, fmt.Errorf("failed to send notification: %w", err)
+	}
+
+	if d.IDs != nil && n.Category != "" {
+		d.IDs.Set(n.Category, id)
+	}
+	return id, nil
+}
+
+// NotifyAndWait shows n and waits for one of its actions to be clicked,
+// returning the action's key. It returns "" once the notification is
+// closed or ctx is done. Notification servers send the click only to
+// the connection that showed the notification, so this process has to
+// stay alive until then.
+func (d *DBus) NotifyAndWait(ctx context.Context, n Notification) (string, error) {
+	match := []dbus.MatchOption{dbus.WithMatchObjectPath(busPath), dbus.WithMatchInterface(busName)}
+	if err := d.conn.AddMatchSignal(match...); err != nil {
+		return "", fmt.Errorf("failed to watch notifications: %w", err)
+	}
+	defer d.conn.RemoveMatchSignal(match...)
+	signals := make(chan *dbus.Signal, 8)
+	d.conn.Signal(signals)
+	defer d.conn.RemoveSignal(signals)
+
+	id, err := d.send(n)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return "", nil
+		case signal := <-signals:
+			if key, done := actionFor(signal, id); done {
+				return key, nil
+			}
+		}
+	}
+}
+
+// actionFor interprets a notification signal for notification id. It
+// returns the clicked action's key, and whether the notification is done
+// with.
+func actionFor(signal *dbus.Signal, id uint32) (string, bool) {
+	if signal == nil || len(signal.Body) < 2 {
+		return "", false
+	}
+	if signalID, ok := signal.Body[0].(uint32); !ok || signalID != id {
+		return "", false
+	}
+
+	switch signal.Name {
+	case busName + ".ActionInvoked":
+		key, _ := signal.Body[1].(string)
+		return key, true
+	case busName + ".NotificationClosed":
+		return "", true
+	}
+	return "", false
+}
+
+// SupportsActions reports whether n can show action buttons and report
+// clicks.
+func SupportsActions(n Notifier) bool {
+	switch n := n.(type) {
+	case *DBus:
+		return true
+	case Filter:
+		return SupportsActions(n.Notifier)
+	}
+	return false
+}
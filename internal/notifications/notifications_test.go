@@ -0,0 +1,139 @@
+package notifications
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/riclib/volu/internal/config"
+)
+
+// fakeBus records Notify calls and hands out increasing IDs.
+type fakeBus struct {
+	calls [][]interface{}
+}
+
+func (f *fakeBus) Call(method string, flags dbus.Flags, args ...interface{}) *dbus.Call {
+	f.calls = append(f.calls, args)
+	return &dbus.Call{Body: []interface{}{uint32(len(f.calls) + 40)}}
+}
+
+func TestDBusReplacesPerCategory(t *testing.T) {
+	bus := &fakeBus{}
+	d := &DBus{obj: bus, Timeout: 3 * time.Second, IDs: &IDStore{Path: filepath.Join(t.TempDir(), "ids.json")}}
+
+	notes := []Notification{
+		{Category: CategoryVolume, Title: "Volumio Volume", Body: "20%"},
+		{Category: CategoryTrack, Title: "Now Playing", Body: "Song", Image: "/tmp/art", Actions: []Action{{Key: "next", Label: "Skip"}}},
+		{Category: CategoryVolume, Title: "Volumio Volume", Body: "30%"},
+		{Category: CategoryError, Title: "Volumio Error", Body: "Oops"},
+	}
+	for _, n := range notes {
+		if err := d.Notify(n); err != nil {
+			t.Fatalf("Notify() error = %v", err)
+		}
+	}
+
+	// app, replaces, icon, summary, body, actions, hints, timeout
+	replaces := []uint32{0, 0, 41, 0}
+	for i, call := range bus.calls {
+		if call[1].(uint32) != replaces[i] {
+			t.Errorf("call %d replaces %v, want %d", i, call[1], replaces[i])
+		}
+		if call[7].(int32) != 3000 {
+			t.Errorf("call %d timeout %v, want 3000", i, call[7])
+		}
+	}
+
+	track := bus.calls[1]
+	if actions := track[5].([]string); len(actions) != 2 || actions[0] != "next" || actions[1] != "Skip" {
+		t.Errorf("actions = %v", actions)
+	}
+	hints := track[6].(map[string]dbus.Variant)
+	if hints["image-path"].Value() != "file:///tmp/art" || hints["x-dunst-stack-tag"].Value() != "volu-track" {
+		t.Errorf("hints = %v", hints)
+	}
+	if urgency := bus.calls[3][6].(map[string]dbus.Variant)["urgency"].Value(); urgency != byte(2) {
+		t.Errorf("error urgency = %v, want critical", urgency)
+	}
+}
+
+func TestActionFor(t *testing.T) {
+	tests := []struct {
+		name   string
+		signal *dbus.Signal
+		key    string
+		done   bool
+	}{
+		{"clicked", &dbus.Signal{Name: busName + ".ActionInvoked", Body: []interface{}{uint32(7), "next"}}, "next", true},
+		{"closed", &dbus.Signal{Name: busName + ".NotificationClosed", Body: []interface{}{uint32(7), uint32(2)}}, "", true},
+		{"other notification", &dbus.Signal{Name: busName + ".ActionInvoked", Body: []interface{}{uint32(8), "next"}}, "", false},
+		{"nil", nil, "", false},
+	}
+	for _, tt := range tests {
+		key, done := actionFor(tt.signal, 7)
+		if key != tt.key || done != tt.done {
+			t.Errorf("%s: actionFor() = %q, %v; want %q, %v", tt.name, key, done, tt.key, tt.done)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	bus := &fakeBus{}
+	n := New(config.NotificationConfig{Backend: "notify-send", Disable: []string{CategoryVolume}})
+	filter, ok := n.(Filter)
+	if !ok {
+		t.Fatalf("New() = %T, want Filter", n)
+	}
+	filter.Notifier = &DBus{obj: bus}
+
+	filter.Notify(Notification{Category: CategoryVolume, Title: "Volumio Volume"})
+	filter.Notify(Notification{Category: CategoryTrack, Title: "Now Playing"})
+	if len(bus.calls) != 1 || bus.calls[0][3] != "Now Playing" {
+		t.Errorf("calls = %v, want only the track notification", bus.calls)
+	}
+	if !SupportsActions(filter) || SupportsActions(&Command{}) {
+		t.Error("SupportsActions() wrong for D-Bus or notify-send")
+	}
+
+	if _, ok := New(config.NotificationConfig{Backend: "off"}).(Discard); !ok {
+		t.Error("backend off should discard")
+	}
+}
+
+func TestCommandArgs(t *testing.T) {
+	c := &Command{Timeout: 1500 * time.Millisecond}
+	got := strings.Join(c.args(Notification{Category: CategoryError, Title: "Volumio Error", Body: "Oops", Icon: "error"}), " ")
+	want := "-t 1500 -a volu -i error -u critical -h string:x-dunst-stack-tag:volu-error Volumio Error Oops"
+	if got != want {
+		t.Errorf("args = %q\nwant   %q", got, want)
+	}
+}
+
+func TestCacheImage(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte("jpeg"))
+	}))
+	defer server.Close()
+
+	for i := 0; i < 2; i++ {
+		path, err := CacheImage(server.URL + "/albumart?path=x")
+		if err != nil {
+			t.Fatalf("CacheImage() error = %v", err)
+		}
+		if data, _ := os.ReadFile(path); string(data) != "jpeg" {
+			t.Errorf("cached image = %q", data)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("requests = %d, want 1 (second call cached)", requests)
+	}
+}
@@ -0,0 +1,242 @@
+package notifications
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/riclib/volu/internal/config"
+)
+
+// Notification categories. Each category replaces its own previous
+// notification instead of stacking a new one, and can be disabled in the
+// config.
+const (
+	CategoryInfo   = "info"   // playback commands and confirmations
+	CategoryError  = "error"  // failures, shown as critical
+	CategoryVolume = "volume" // volume changes
+	CategoryTrack  = "track"  // now playing
+)
+
+// DefaultTimeout is how long notifications stay up unless configured.
+const DefaultTimeout = 2 * time.Second
+
+// Action is a button on a notification. Key is passed back when it is
+// clicked; volu uses launcher command names such as "next" and "toggle".
+type Action struct {
+	Key   string `json:"key"`
+	Label string `json:"label"`
+}
+
+// Notification is a desktop notification.
+type Notification struct {
+	Category string   `json:"category"`
+	Title    string   `json:"title"`
+	Body     string   `json:"body"`
+	Icon     string   `json:"icon,omitempty"`  // icon name, e.g. "media-playback-start"
+	Image    string   `json:"image,omitempty"` // local image file, e.g. cached album art
+	Actions  []Action `json:"actions,omitempty"`
+}
+
+// Urgent reports whether the notification should be shown as critical.
+func (n Notification) Urgent() bool {
+	return n.Category == CategoryError
+}
+
+// Notifier shows notifications.
+type Notifier interface {
+	Notify(n Notification) error
+}
+
+// New returns the notifier selected in the config: D-Bus (the default,
+// falling back to notify-send when no session bus is reachable),
+// notify-send, or off. Disabled categories are dropped.
+func New(cfg config.NotificationConfig) Notifier {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	var n Notifier
+	switch cfg.Backend {
+	case "off", "none":
+		return Discard{}
+	case "notify-send":
+		n = &Command{Timeout: timeout}
+	default:
+		bus, err := NewDBus(timeout)
+		if err != nil {
+			n = &Command{Timeout: timeout}
+		} else {
+			n = bus
+		}
+	}
+
+	if len(cfg.Disable) > 0 {
+		n = Filter{Notifier: n, Disabled: cfg.Disable}
+	}
+	return n
+}
+
+// Discard drops every notification.
+type Discard struct{}
+
+// Notify does nothing.
+func (Discard) Notify(Notification) error { return nil }
+
+// Filter drops notifications in disabled categories.
+type Filter struct {
+	Notifier
+	Disabled []string
+}
+
+// Notify passes n on unless its category is disabled.
+func (f Filter) Notify(n Notification) error {
+	if f.Disables(n.Category) {
+		return nil
+	}
+	return f.Notifier.Notify(n)
+}
+
+// Disables reports whether notifications in category are dropped.
+func (f Filter) Disables(category string) bool {
+	for _, disabled := range f.Disabled {
+		if disabled == category {
+			return true
+		}
+	}
+	return false
+}
+
+// Command shows notifications by running notify-send. It cannot replace
+// earlier notifications by ID, but passes the category as a stack tag,
+// which dunst and mako use to replace them.
+type Command struct {
+	Timeout time.Duration
+}
+
+// Notify runs notify-send for n. Actions are not supported.
+func (c *Command) Notify(n Notification) error {
+	if err := exec.Command("notify-send", c.args(n)...).Run(); err != nil {
+		return fmt.Errorf("failed to run notify-send: %w", err)
+	}
+	return nil
+}
+
+func (c *Command) args(n Notification) []string {
+	icon := n.Icon
+	if n.Image != "" {
+		icon = n.Image
+	}
+	args := []string{"-t", strconv.FormatInt(c.Timeout.Milliseconds(), 10), "-a", "volu"}
+	if icon != "" {
+		args = append(args, "-i", icon)
+	}
+	if n.Urgent() {
+		args = append(args, "-u", "critical")
+	}
+	if n.Category != "" {
+		args = append(args, "-h", "string:x-dunst-stack-tag:volu-"+n.Category)
+	}
+	return append(args, n.Title, n.Body)
+}
+
+// IDStore remembers the last notification ID per category across volu
+// invocations, so e.g. each volume change replaces the previous bubble.
+type IDStore struct {
+	Path string
+}
+
+// DefaultIDStore keeps IDs in $XDG_RUNTIME_DIR, as they are only valid
+// for the current session.
+func DefaultIDStore() *IDStore {
+	return &IDStore{Path: filepath.Join(runtimeDir(), "volu", "notifications.json")}
+}
+
+// runtimeDir returns $XDG_RUNTIME_DIR, or the temp directory if unset.
+func runtimeDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return dir
+	}
+	return os.TempDir()
+}
+
+func (s *IDStore) load() map[string]uint32 {
+	ids := map[string]uint32{}
+	if data, err := os.ReadFile(s.Path); err == nil {
+		json.Unmarshal(data, &ids)
+	}
+	return ids
+}
+
+// Get returns the last ID shown for category, or 0.
+func (s *IDStore) Get(category string) uint32 {
+	return s.load()[category]
+}
+
+// Set records the ID shown for category.
+func (s *IDStore) Set(category string, id uint32) error {
+	ids := s.load()
+	ids[category] = id
+	data, err := json.Marshal(ids)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0700); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	return os.WriteFile(s.Path, data, 0600)
+}
+
+// CacheImage downloads the image at url into the user cache directory,
+// reusing an earlier download, and returns its path. Notification
+// servers only show local images.
+func CacheImage(url string) (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user cache directory: %w", err)
+	}
+	sum := sha1.Sum([]byte(url))
+	path := filepath.Join(cacheDir, "volu", "art", hex.EncodeToString(sum[:]))
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch image: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch image: %s", resp.Status)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create art cache: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "download-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create art cache: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, resp.Body); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to fetch image: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", fmt.Errorf("failed to cache image: %w", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,86 @@
+package notifications
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/riclib/volu/internal/volumio"
+)
+
+// SelfWindow is how close to a track change volu must have marked its own
+// command for the change to count as triggered by volu.
+const SelfWindow = 5 * time.Second
+
+// TrackWatcher turns polled player states into track changes worth
+// announcing. A new track is only announced once it has been playing for
+// Debounce, so skipping through several tracks shows just the last one.
+type TrackWatcher struct {
+	Debounce time.Duration
+
+	started bool
+	current string    // track last announced, or playing at start
+	pending string    // new track waiting out the debounce
+	since   time.Time // when pending was first seen
+}
+
+// Update records the state polled at now. It returns true when the track
+// has settled on a new one that is playing.
+func (w *TrackWatcher) Update(state *volumio.PlayerState, now time.Time) bool {
+	key := volumio.TrackKey(state)
+	if !w.started {
+		w.started = true
+		w.current = key
+		return false
+	}
+	if key == w.current {
+		w.pending = ""
+		return false
+	}
+	if key != w.pending {
+		w.pending = key
+		w.since = now
+	}
+	if now.Sub(w.since) < w.Debounce {
+		return false
+	}
+
+	w.current = key
+	w.pending = ""
+	return key != "" && state.Status == "play"
+}
+
+// Since returns when the track last announced by Update was first seen.
+func (w *TrackWatcher) Since() time.Time {
+	return w.since
+}
+
+// selfMarkPath is touched whenever volu itself changes the track.
+func selfMarkPath() string {
+	return filepath.Join(runtimeDir(), "volu", "track-change")
+}
+
+// MarkSelf records that volu just changed the track and showed its own
+// notification, so 'volu notifyd' doesn't announce it a second time.
+func MarkSelf() error {
+	path := selfMarkPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err == nil {
+		return nil
+	}
+	return os.WriteFile(path, nil, 0600)
+}
+
+// TriggeredBySelf reports whether volu marked a change of its own within
+// SelfWindow of changed.
+func TriggeredBySelf(changed time.Time) bool {
+	info, err := os.Stat(selfMarkPath())
+	if err != nil {
+		return false
+	}
+	d := info.ModTime().Sub(changed)
+	return d > -SelfWindow && d < SelfWindow
+}
@@ -0,0 +1,75 @@
+package notifications
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/riclib/volu/internal/volumio"
+)
+
+func TestTrackWatcher(t *testing.T) {
+	track := func(title, status string) *volumio.PlayerState {
+		return &volumio.PlayerState{Status: status, Title: title, Artist: "Artist", URI: "mnt/" + title}
+	}
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(seconds int) time.Time { return start.Add(time.Duration(seconds) * time.Second) }
+
+	w := &TrackWatcher{Debounce: 2 * time.Second}
+	steps := []struct {
+		state *volumio.PlayerState
+		at    int
+		want  bool
+	}{
+		{track("A", "play"), 0, false}, // playing at start
+		{track("A", "play"), 1, false},
+		{track("B", "play"), 2, false}, // rapid skips
+		{track("C", "play"), 3, false},
+		{track("D", "play"), 4, false},
+		{track("D", "play"), 5, false},
+		{track("D", "play"), 6, true}, // settled
+		{track("D", "play"), 9, false},
+		{track("E", "pause"), 10, false},
+		{track("E", "pause"), 13, false}, // not playing
+		{track("F", "play"), 14, false},
+		{track("E", "play"), 15, false}, // back to the last track
+		{track("E", "play"), 20, false},
+	}
+	for i, step := range steps {
+		if got := w.Update(step.state, at(step.at)); got != step.want {
+			t.Errorf("step %d (%s at %ds): Update() = %v, want %v", i, step.state.Title, step.at, got, step.want)
+		}
+	}
+
+	// Web radio keeps its URI while the stream title changes
+	radio := &TrackWatcher{}
+	radio.Update(&volumio.PlayerState{Status: "play", URI: "http://stream", Title: "Song 1"}, start)
+	if !radio.Update(&volumio.PlayerState{Status: "play", URI: "http://stream", Title: "Song 2"}, at(1)) {
+		t.Error("stream title change not announced")
+	}
+}
+
+func TestTriggeredBySelf(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	if TriggeredBySelf(time.Now()) {
+		t.Error("TriggeredBySelf() = true before any mark")
+	}
+
+	if err := MarkSelf(); err != nil {
+		t.Fatalf("MarkSelf() error = %v", err)
+	}
+	if !TriggeredBySelf(time.Now()) {
+		t.Error("TriggeredBySelf() = false right after a mark")
+	}
+	if TriggeredBySelf(time.Now().Add(time.Minute)) {
+		t.Error("TriggeredBySelf() = true for a change a minute later")
+	}
+
+	// A second mark moves the time forward
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(selfMarkPath(), old, old)
+	MarkSelf()
+	if !TriggeredBySelf(time.Now()) {
+		t.Error("second MarkSelf() did not update the mark")
+	}
+}
@@ -4,23 +4,39 @@ import (
 	"fmt"
 	"math/rand"
 	"regexp"
+	"sort"
 	"time"
 
+	"github.com/riclib/volu/internal/library"
 	"github.com/riclib/volu/internal/volumio"
 )
 
 // Player handles radio series playback functionality.
 type Player struct {
-	client *volumio.Client
+	client  volumio.Player
+	library *library.Index
+	plays   map[string]int
 }
 
 // NewPlayer creates a new radio player.
-func NewPlayer(client *volumio.Client) *Player {
+func NewPlayer(client volumio.Player) *Player {
 	return &Player{
 		client: client,
 	}
 }
 
+// UseLibrary makes the player select albums from a local library index
+// instead of issuing a live search against Volumio.
+func (p *Player) UseLibrary(idx *library.Index) {
+	p.library = idx
+}
+
+// UseHistory makes the player prefer episodes played less often. plays
+// counts the tracks played from each album, by album name.
+func (p *Player) UseHistory(plays map[string]int) {
+	p.plays = plays
+}
+
 // PlayRandomEpisodes searches for albums matching the pattern, randomly selects count albums,
 // and queues them for playback. Shuffle is automatically disabled.
 func (p *Player) PlayRandomEpisodes(searchQuery, pattern string, count int) error {
@@ -54,7 +70,8 @@ func (p *Player) PlayRandomEpisodes(searchQuery, pattern string, count int) erro
 	return nil
 }
 
-// findMatchingAlbums searches for albums using the search API and filters by regex pattern.
+// findMatchingAlbums searches for albums using the library index or the search API
+// and filters by regex pattern.
 func (p *Player) findMatchingAlbums(searchQuery, pattern string) ([]volumio.BrowseItem, error) {
 	// Compile the regex pattern
 	regex, err := regexp.Compile(pattern)
@@ -62,8 +79,7 @@ func (p *Player) findMatchingAlbums(searchQuery, pattern string) ([]volumio.Brow
 		return nil, fmt.Errorf("invalid regex pattern: %w", err)
 	}
 
-	// Search for albums using the Volumio API
-	albums, err := p.client.SearchAlbums(searchQuery)
+	albums, err := p.searchAlbums(searchQuery)
 	if err != nil {
 		return nil, fmt.Errorf("search failed: %w", err)
 	}
@@ -80,12 +96,31 @@ func (p *Player) findMatchingAlbums(searchQuery, pattern string) ([]volumio.Brow
 	return matching, nil
 }
 
+// searchAlbums returns candidate albums for a query, preferring the local
+// library index when one is attached.
+func (p *Player) searchAlbums(searchQuery string) ([]volumio.BrowseItem, error) {
+	if p.library == nil {
+		return p.client.SearchAlbums(searchQuery)
+	}
+
+	entries := p.library.AlbumsMatching(searchQuery)
+	albums := make([]volumio.BrowseItem, len(entries))
+	for i, entry := range entries {
+		albums[i] = entry.Item()
+	}
+	return albums, nil
+}
+
 // randomSelect randomly selects up to count items from the albums slice.
 // If count >= len(albums), returns all albums in random order.
 func (p *Player) randomSelect(albums []volumio.BrowseItem, count int) []volumio.BrowseItem {
 	// Seed the random number generator
 	rand.Seed(time.Now().UnixNano())
 
+	if p.plays != nil {
+		return p.leastPlayed(albums, count)
+	}
+
 	if count >= len(albums) {
 		// Return all albums in random order
 		return shuffle(albums)
@@ -112,6 +147,19 @@ func (p *Player) randomSelect(albums []volumio.BrowseItem, count int) []volumio.
 	return selected
 }
 
+// leastPlayed selects up to count of the least played albums, picking at
+// random among albums played equally often.
+func (p *Player) leastPlayed(albums []volumio.BrowseItem, count int) []volumio.BrowseItem {
+	selected := shuffle(albums)
+	sort.SliceStable(selected, func(i, j int) bool {
+		return p.plays[selected[i].DisplayName()] < p.plays[selected[j].DisplayName()]
+	})
+	if count < len(selected) {
+		selected = selected[:count]
+	}
+	return selected
+}
+
 // shuffle returns a shuffled copy of the albums slice.
 func shuffle(albums []volumio.BrowseItem) []volumio.BrowseItem {
 	shuffled := make([]volumio.BrowseItem, len(albums))
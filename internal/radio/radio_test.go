@@ -0,0 +1,61 @@
+package radio
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/riclib/volu/internal/volumio"
+	"github.com/riclib/volu/internal/volumiofake"
+	"github.com/riclib/volu/internal/volumiotest"
+)
+
+func TestPlayRandomEpisodes(t *testing.T) {
+	server := volumiotest.NewServer(volumiofake.DefaultLibrary())
+	defer server.Close()
+	client := volumio.NewClient(server.URL)
+	client.ToggleRandom()
+
+	player := NewPlayer(client)
+	player.UseHistory(map[string]int{"ASOT 1090": 3, "ASOT 1091": 1, "ASOT 1092": 2, "ASOT 1093": 4, "ASOT 1094": 5})
+	if err := player.PlayRandomEpisodes("ASOT", `^ASOT \d+$`, 2); err != nil {
+		t.Fatalf("PlayRandomEpisodes() error = %v", err)
+	}
+
+	state := server.State()
+	if state.Random {
+		t.Error("shuffle should be off")
+	}
+	if state.Status != "play" || state.Album != "ASOT 1095" {
+		t.Errorf("playing %s from %q, want the never played ASOT 1095", state.Status, state.Album)
+	}
+	albums := map[string]bool{}
+	for _, track := range server.Queue() {
+		albums[track.Album] = true
+	}
+	if len(albums) != 2 || !albums["ASOT 1091"] {
+		t.Errorf("queued albums = %v, want ASOT 1095 and ASOT 1091", albums)
+	}
+
+	err := player.PlayRandomEpisodes("ASOT", `^Tritonia`, 1)
+	if err == nil || !strings.Contains(err.Error(), "no albums found") {
+		t.Errorf("PlayRandomEpisodes() with no matches error = %v", err)
+	}
+}
+
+// offlinePlayer is a volumio.Player whose searches fail; other methods
+// are not expected to be called.
+type offlinePlayer struct {
+	volumio.Player
+}
+
+func (offlinePlayer) SearchAlbums(query string) ([]volumio.BrowseItem, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestPlayRandomEpisodesOffline(t *testing.T) {
+	err := NewPlayer(offlinePlayer{}).PlayRandomEpisodes("ASOT", `^ASOT`, 2)
+	if err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Errorf("PlayRandomEpisodes() error = %v, want the search error", err)
+	}
+}
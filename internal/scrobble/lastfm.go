@@ -0,0 +1,172 @@
+package scrobble
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Last.fm endpoints.
+const (
+	DefaultLastFMURL = "https://ws.audioscrobbler.com/2.0/"
+	LastFMAuthURL    = "https://www.last.fm/api/auth/"
+)
+
+// lastFMInvalidParameters is the Last.fm error for listens it won't
+// accept. Other errors, such as an expired session or the service being
+// offline, are worth retrying once fixed.
+const lastFMInvalidParameters = 6
+
+// LastFM submits listens with a session key.
+type LastFM struct {
+	URL        string
+	APIKey     string
+	APISecret  string
+	SessionKey string
+	HTTPClient *http.Client
+}
+
+// NewLastFM creates a Last.fm client; an empty url means DefaultLastFMURL.
+func NewLastFM(url, apiKey, apiSecret, sessionKey string) *LastFM {
+	if url == "" {
+		url = DefaultLastFMURL
+	}
+	return &LastFM{
+		URL:        url,
+		APIKey:     apiKey,
+		APISecret:  apiSecret,
+		SessionKey: sessionKey,
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Name returns "lastfm".
+func (fm *LastFM) Name() string {
+	return "lastfm"
+}
+
+// NowPlaying calls track.updateNowPlaying.
+func (fm *LastFM) NowPlaying(l Listen) error {
+	params := url.Values{"artist": {l.Artist}, "track": {l.Title}}
+	if l.Album != "" {
+		params.Set("album", l.Album)
+	}
+	if l.Duration > 0 {
+		params.Set("duration", strconv.Itoa(int(l.Duration.Seconds())))
+	}
+	return fm.call("track.updateNowPlaying", params, nil)
+}
+
+// Scrobble calls track.scrobble with up to 50 listens.
+func (fm *LastFM) Scrobble(listens []Listen) error {
+	params := url.Values{}
+	for i, l := range listens {
+		n := "[" + strconv.Itoa(i) + "]"
+		params.Set("artist"+n, l.Artist)
+		params.Set("track"+n, l.Title)
+		params.Set("timestamp"+n, strconv.FormatInt(l.ListenedAt.Unix(), 10))
+		if l.Album != "" {
+			params.Set("album"+n, l.Album)
+		}
+		if l.Duration > 0 {
+			params.Set("duration"+n, strconv.Itoa(int(l.Duration.Seconds())))
+		}
+	}
+	return fm.call("track.scrobble", params, nil)
+}
+
+// Token starts a login with auth.getToken. The user approves it at
+// AuthURL before calling Session.
+func (fm *LastFM) Token() (string, error) {
+	var result struct {
+		Token string `json:"token"`
+	}
+	if err := fm.call("auth.getToken", url.Values{}, &result); err != nil {
+		return "", err
+	}
+	return result.Token, nil
+}
+
+// AuthURL returns the page where the user approves token.
+func (fm *LastFM) AuthURL(token string) string {
+	return LastFMAuthURL + "?" + url.Values{"api_key": {fm.APIKey}, "token": {token}}.Encode()
+}
+
+// Session exchanges an approved token for a session key with
+// auth.getSession, returning the user name and key.
+func (fm *LastFM) Session(token string) (string, string, error) {
+	var result struct {
+		Session struct {
+			Name string `json:"name"`
+			Key  string `json:"key"`
+		} `json:"session"`
+	}
+	if err := fm.call("auth.getSession", url.Values{"token": {token}}, &result); err != nil {
+		return "", "", err
+	}
+	return result.Session.Name, result.Session.Key, nil
+}
+
+// sign adds the API key, session key and signature to params.
+func (fm *LastFM) sign(method string, params url.Values) {
+	params.Set("method", method)
+	params.Set("api_key", fm.APIKey)
+	if fm.SessionKey != "" && method != "auth.getToken" && method != "auth.getSession" {
+		params.Set("sk", fm.SessionKey)
+	}
+
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var sig string
+	for _, key := range keys {
+		sig += key + params.Get(key)
+	}
+	sum := md5.Sum([]byte(sig + fm.APISecret))
+	params.Set("api_sig", hex.EncodeToString(sum[:]))
+	params.Set("format", "json")
+}
+
+// call posts a signed request and decodes the response into result.
+func (fm *LastFM) call(method string, params url.Values, result interface{}) error {
+	fm.sign(method, params)
+	resp, err := fm.HTTPClient.PostForm(fm.URL, params)
+	if err != nil {
+		return fmt.Errorf("failed to reach Last.fm: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read Last.fm response: %w", err)
+	}
+	var failure struct {
+		Error   int    `json:"error"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(data, &failure) == nil && failure.Error != 0 {
+		if failure.Error == lastFMInvalidParameters {
+			return &RejectedError{Service: fm.Name(), Message: failure.Message}
+		}
+		return fmt.Errorf("Last.fm error %d: %s", failure.Error, failure.Message)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Last.fm error: %s", resp.Status)
+	}
+
+	if result != nil {
+		if err := json.Unmarshal(data, result); err != nil {
+			return fmt.Errorf("failed to parse Last.fm response: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package scrobble
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultListenBrainzURL is the ListenBrainz API root.
+const DefaultListenBrainzURL = "https://api.listenbrainz.org"
+
+// ListenBrainz submits listens with a user token.
+type ListenBrainz struct {
+	URL        string
+	Token      string
+	HTTPClient *http.Client
+}
+
+// NewListenBrainz creates a ListenBrainz client; an empty url means
+// DefaultListenBrainzURL.
+func NewListenBrainz(url, token string) *ListenBrainz {
+	if url == "" {
+		url = DefaultListenBrainzURL
+	}
+	return &ListenBrainz{
+		URL:        strings.TrimSuffix(url, "/"),
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Name returns "listenbrainz".
+func (lb *ListenBrainz) Name() string {
+	return "listenbrainz"
+}
+
+type lbSubmission struct {
+	ListenType string     `json:"listen_type"`
+	Payload    []lbListen `json:"payload"`
+}
+
+type lbListen struct {
+	ListenedAt int64      `json:"listened_at,omitempty"`
+	Track      lbMetadata `json:"track_metadata"`
+}
+
+type lbMetadata struct {
+	ArtistName     string                 `json:"artist_name"`
+	TrackName      string                 `json:"track_name"`
+	ReleaseName    string                 `json:"release_name,omitempty"`
+	AdditionalInfo map[string]interface{} `json:"additional_info"`
+}
+
+func lbTrack(l Listen) lbMetadata {
+	info := map[string]interface{}{"submission_client": "volu", "media_player": "Volumio"}
+	if l.Duration > 0 {
+		info["duration_ms"] = l.Duration.Milliseconds()
+	}
+	return lbMetadata{ArtistName: l.Artist, TrackName: l.Title, ReleaseName: l.Album, AdditionalInfo: info}
+}
+
+// NowPlaying sets the user's playing now track.
+func (lb *ListenBrainz) NowPlaying(l Listen) error {
+	return lb.submit(lbSubmission{ListenType: "playing_now", Payload: []lbListen{{Track: lbTrack(l)}}})
+}
+
+// Scrobble submits listens.
+func (lb *ListenBrainz) Scrobble(listens []Listen) error {
+	sub := lbSubmission{ListenType: "import"}
+	if len(listens) == 1 {
+		sub.ListenType = "single"
+	}
+	for _, l := range listens {
+		sub.Payload = append(sub.Payload, lbListen{ListenedAt: l.ListenedAt.Unix(), Track: lbTrack(l)})
+	}
+	return lb.submit(sub)
+}
+
+func (lb *ListenBrainz) submit(sub lbSubmission) error {
+	body, err := json.Marshal(sub)
+	if err != nil {
+		return fmt.Errorf("failed to marshal listens: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, lb.URL+"/1/submit-listens", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Token "+lb.Token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := lb.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach ListenBrainz: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	var result struct {
+		Error string `json:"error"`
+	}
+	data, _ := io.ReadAll(resp.Body)
+	if json.Unmarshal(data, &result) != nil || result.Error == "" {
+		result.Error = resp.Status
+	}
+	// Bad listens are rejected with 400; auth problems, rate limits and
+	// outages are worth retrying once fixed
+	if resp.StatusCode == http.StatusBadRequest {
+		return &RejectedError{Service: lb.Name(), Message: result.Error}
+	}
+	return fmt.Errorf("ListenBrainz error: %s", result.Error)
+}
@@ -0,0 +1,218 @@
+package scrobble
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/riclib/volu/internal/atomicfile"
+	"github.com/riclib/volu/internal/config"
+	"github.com/riclib/volu/internal/volumio"
+)
+
+// Scrobbling rules shared by Last.fm and ListenBrainz: a track counts as
+// listened to once half of it, or MaxThreshold, has played, and tracks
+// shorter than MinDuration are never scrobbled.
+const (
+	MinDuration  = 30 * time.Second
+	MaxThreshold = 4 * time.Minute
+)
+
+// restartSlack is how far the position may move backwards before the
+// track counts as started again, e.g. with repeat single.
+const restartSlack = 5 * time.Second
+
+// Listen is a track played on Volumio.
+type Listen struct {
+	Artist     string        `json:"artist"`
+	Title      string        `json:"title"`
+	Album      string        `json:"album,omitempty"`
+	Duration   time.Duration `json:"duration"`
+	ListenedAt time.Time     `json:"listened_at"` // when the track started
+}
+
+// Threshold returns how long a track of duration d has to play before it
+// is scrobbled.
+func Threshold(d time.Duration) time.Duration {
+	if d/2 < MaxThreshold {
+		return d / 2
+	}
+	return MaxThreshold
+}
+
+// Tracker follows the player state and decides when to send now playing
+// and when to scrobble. The threshold is measured in time actually played
+// between polls, not by the position, so seeking ahead doesn't scrobble.
+type Tracker struct {
+	key       string
+	listen    Listen
+	position  time.Duration
+	announced bool
+	scrobbled bool
+	playing   bool
+	last      time.Time
+	played    time.Duration
+}
+
+// Update records the state polled at now. It returns the listen to send
+// as now playing when a track starts playing, and the listen to scrobble
+// once the track has played past its threshold; either may be nil.
+func (t *Tracker) Update(state *volumio.PlayerState, now time.Time) (nowPlaying, scrobble *Listen) {
+	if state == nil {
+		return nil, nil
+	}
+	if t.playing {
+		t.played += now.Sub(t.last)
+	}
+	t.last = now
+
+	key := volumio.TrackKey(state)
+	position := time.Duration(state.Seek) * time.Millisecond
+	if key != t.key || position+restartSlack < t.position {
+		t.key = key
+		t.listen = Listen{
+			Artist:     state.Artist,
+			Title:      state.Title,
+			Album:      state.Album,
+			Duration:   time.Duration(state.Duration) * time.Second,
+			ListenedAt: now.Add(-position).Truncate(time.Second),
+		}
+		t.announced = false
+		t.scrobbled = false
+		t.played = 0
+	}
+	t.position = position
+	t.playing = key != "" && state.Status == "play"
+
+	if state.Status != "play" || state.Artist == "" || state.Title == "" {
+		return nil, nil
+	}
+	if !t.announced {
+		t.announced = true
+		listen := t.listen
+		nowPlaying = &listen
+	}
+	if !t.scrobbled && t.listen.Duration >= MinDuration && t.played >= Threshold(t.listen.Duration) {
+		t.scrobbled = true
+		listen := t.listen
+		scrobble = &listen
+	}
+	return nowPlaying, scrobble
+}
+
+// Pause stops counting played time at now without ending the current
+// track, e.g. while Volumio is unreachable.
+func (t *Tracker) Pause(now time.Time) {
+	t.last = now
+	t.playing = false
+}
+
+// Service is a scrobbling service.
+type Service interface {
+	Name() string
+	NowPlaying(l Listen) error
+	Scrobble(listens []Listen) error
+}
+
+// RejectedError is returned by a service that refused the listens
+// themselves. Retrying won't help, so they are dropped rather than queued.
+type RejectedError struct {
+	Service string
+	Message string
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("%s rejected the listens: %s", e.Service, e.Message)
+}
+
+// Queue keeps listens that could not be submitted yet, such as while
+// offline.
+type Queue struct {
+	Path string
+}
+
+// DefaultQueue returns the queue file for service, in $XDG_STATE_HOME (or
+// ~/.local/state): queued listens are not a cache that can be rebuilt.
+func DefaultQueue(service string) (*Queue, error) {
+	dir, err := config.StateDir()
+	if err != nil {
+		return nil, err
+	}
+	return &Queue{Path: filepath.Join(dir, "scrobble-"+service+".json")}, nil
+}
+
+// Load returns the queued listens, oldest first.
+func (q *Queue) Load() ([]Listen, error) {
+	data, err := os.ReadFile(q.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scrobble queue: %w", err)
+	}
+	var listens []Listen
+	if err := json.Unmarshal(data, &listens); err != nil {
+		return nil, fmt.Errorf("failed to parse scrobble queue: %w", err)
+	}
+	return listens, nil
+}
+
+// Save replaces the queued listens; an empty list removes the file.
+func (q *Queue) Save(listens []Listen) error {
+	if len(listens) == 0 {
+		if err := os.Remove(q.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to clear scrobble queue: %w", err)
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(q.Path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	data, err := json.MarshalIndent(listens, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal scrobble queue: %w", err)
+	}
+	if err := atomicfile.WriteFile(q.Path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write scrobble queue: %w", err)
+	}
+	return nil
+}
+
+// maxBatch is the most listens submitted in one request (Last.fm's limit).
+const maxBatch = 50
+
+// Submit sends the queued listens followed by listens to s, oldest first.
+// Whatever could not be sent stays queued for the next Submit; listens the
+// service rejected are dropped. It returns the number of listens sent.
+func Submit(s Service, q *Queue, listens ...Listen) (int, error) {
+	pending, err := q.Load()
+	if err != nil {
+		return 0, err
+	}
+	pending = append(pending, listens...)
+
+	sent := 0
+	for len(pending) > 0 {
+		batch := pending
+		if len(batch) > maxBatch {
+			batch = batch[:maxBatch]
+		}
+		err = s.Scrobble(batch)
+		var rejected *RejectedError
+		if err != nil && !errors.As(err, &rejected) {
+			break
+		}
+		if err == nil {
+			sent += len(batch)
+		}
+		pending = pending[len(batch):]
+	}
+
+	if saveErr := q.Save(pending); saveErr != nil && err == nil {
+		err = saveErr
+	}
+	return sent, err
+}
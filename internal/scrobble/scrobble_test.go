@@ -0,0 +1,279 @@
+package scrobble
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/riclib/volu/internal/volumio"
+)
+
+func TestThreshold(t *testing.T) {
+	tests := []struct {
+		duration time.Duration
+		want     time.Duration
+	}{
+		{3 * time.Minute, 90 * time.Second},
+		{8 * time.Minute, 4 * time.Minute},
+		{20 * time.Minute, 4 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := Threshold(tt.duration); got != tt.want {
+			t.Errorf("Threshold(%v) = %v, want %v", tt.duration, got, tt.want)
+		}
+	}
+}
+
+func TestTracker(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	state := func(title, status string, seek, duration int) *volumio.PlayerState {
+		return &volumio.PlayerState{Status: status, Artist: "Artist", Title: title, Album: "Album", URI: "mnt/" + title, Seek: seek * 1000, Duration: duration}
+	}
+
+	var tr Tracker
+	steps := []struct {
+		state      *volumio.PlayerState
+		at         int // seconds after start
+		nowPlaying bool
+		scrobble   bool
+	}{
+		{state("A", "play", 10, 180), 10, true, false},
+		{state("A", "play", 60, 180), 60, false, false},
+		{state("A", "pause", 60, 180), 70, false, false}, // paused from 60 to 99
+		{state("A", "play", 60, 180), 99, false, false},  // not counted
+		{state("A", "play", 89, 180), 128, false, false},
+		{state("A", "play", 90, 180), 129, false, true}, // played half of 3 minutes
+		{state("A", "play", 170, 180), 209, false, false},
+		{state("A", "play", 1, 180), 220, true, false}, // repeated
+		{state("B", "play", 5, 20), 229, true, false},  // too short to scrobble
+		{state("B", "play", 19, 20), 243, false, false},
+		{state("C", "play", 240, 900), 500, true, false}, // seeked 4 minutes in
+		{state("C", "play", 479, 900), 739, false, false},
+		{state("C", "play", 480, 900), 740, false, true}, // long track, 4 minutes played
+		{state("D", "stop", 0, 200), 800, false, false},
+	}
+	for i, step := range steps {
+		now := start.Add(time.Duration(step.at) * time.Second)
+		nowPlaying, scrobble := tr.Update(step.state, now)
+		if (nowPlaying != nil) != step.nowPlaying || (scrobble != nil) != step.scrobble {
+			t.Errorf("step %d (%s at %ds): now playing %v, scrobble %v; want %v, %v",
+				i, step.state.Title, step.at, nowPlaying != nil, scrobble != nil, step.nowPlaying, step.scrobble)
+		}
+		if scrobble != nil && scrobble.Title == "A" {
+			if want := start; !scrobble.ListenedAt.Equal(want) {
+				t.Errorf("ListenedAt = %v, want %v", scrobble.ListenedAt, want)
+			}
+			if scrobble.Duration != 3*time.Minute || scrobble.Album != "Album" {
+				t.Errorf("scrobble = %+v", scrobble)
+			}
+		}
+	}
+
+	// A failed poll pauses counting
+	tr.Update(state("E", "play", 0, 200), start.Add(1000*time.Second))
+	tr.Update(state("E", "play", 50, 200), start.Add(1050*time.Second))
+	tr.Pause(start.Add(1060 * time.Second))
+	if _, scrobble := tr.Update(state("E", "play", 100, 200), start.Add(1100*time.Second)); scrobble != nil {
+		t.Error("scrobbled E after 50s of play, want 100s")
+	}
+}
+
+func TestListenBrainz(t *testing.T) {
+	var got []lbSubmission
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/1/submit-listens" || r.Header.Get("Authorization") != "Token secret" {
+			t.Errorf("request %s %s auth %q", r.Method, r.URL.Path, r.Header.Get("Authorization"))
+		}
+		var sub lbSubmission
+		json.NewDecoder(r.Body).Decode(&sub)
+		got = append(got, sub)
+		if sub.Payload[0].Track.TrackName == "Bad" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code": 400, "error": "Invalid track"}`))
+			return
+		}
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer server.Close()
+
+	lb := NewListenBrainz(server.URL+"/", "secret")
+	listen := Listen{Artist: "Artist", Title: "Song", Album: "Album", Duration: 200 * time.Second, ListenedAt: time.Unix(1700000000, 0)}
+	if err := lb.NowPlaying(listen); err != nil {
+		t.Fatalf("NowPlaying() error = %v", err)
+	}
+	if err := lb.Scrobble([]Listen{listen, listen}); err != nil {
+		t.Fatalf("Scrobble() error = %v", err)
+	}
+
+	if got[0].ListenType != "playing_now" || got[0].Payload[0].ListenedAt != 0 {
+		t.Errorf("now playing = %+v", got[0])
+	}
+	sub := got[1]
+	if sub.ListenType != "import" || len(sub.Payload) != 2 || sub.Payload[0].ListenedAt != 1700000000 {
+		t.Errorf("scrobble = %+v", sub)
+	}
+	if track := sub.Payload[0].Track; track.ArtistName != "Artist" || track.ReleaseName != "Album" || track.AdditionalInfo["duration_ms"] != float64(200000) {
+		t.Errorf("track = %+v", track)
+	}
+
+	var rejected *RejectedError
+	if err := lb.Scrobble([]Listen{{Artist: "Artist", Title: "Bad"}}); err == nil || !errors.As(err, &rejected) {
+		t.Errorf("Scrobble() error = %v, want RejectedError", err)
+	}
+}
+
+func TestLastFM(t *testing.T) {
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		methods = append(methods, r.PostForm.Get("method"))
+
+		// Check the signature the way Last.fm does
+		var keys []string
+		for key := range r.PostForm {
+			if key != "api_sig" && key != "format" {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		sig := ""
+		for _, key := range keys {
+			sig += key + r.PostForm.Get(key)
+		}
+		sum := md5.Sum([]byte(sig + "shh"))
+		if r.PostForm.Get("api_sig") != hex.EncodeToString(sum[:]) {
+			w.Write([]byte(`{"error": 13, "message": "Invalid method signature supplied"}`))
+			return
+		}
+
+		switch r.PostForm.Get("method") {
+		case "auth.getSession":
+			w.Write([]byte(`{"session": {"name": "office", "key": "sk123"}}`))
+		case "track.scrobble":
+			if r.PostForm.Get("sk") != "sk123" || r.PostForm.Get("artist[1]") != "Other" || r.PostForm.Get("timestamp[0]") != "1700000000" {
+				t.Errorf("scrobble form = %v", r.PostForm)
+			}
+			w.Write([]byte(`{"scrobbles": {"@attr": {"accepted": 2, "ignored": 0}}}`))
+		default:
+			w.Write([]byte(`{"nowplaying": {}}`))
+		}
+	}))
+	defer server.Close()
+
+	fm := NewLastFM(server.URL, "key", "shh", "")
+	name, key, err := fm.Session("token")
+	if err != nil || name != "office" || key != "sk123" {
+		t.Fatalf("Session() = %q, %q, %v", name, key, err)
+	}
+
+	fm.SessionKey = key
+	listen := Listen{Artist: "Artist", Title: "Song", Duration: 200 * time.Second, ListenedAt: time.Unix(1700000000, 0)}
+	if err := fm.NowPlaying(listen); err != nil {
+		t.Errorf("NowPlaying() error = %v", err)
+	}
+	other := listen
+	other.Artist = "Other"
+	if err := fm.Scrobble([]Listen{listen, other}); err != nil {
+		t.Errorf("Scrobble() error = %v", err)
+	}
+	if strings.Join(methods, ",") != "auth.getSession,track.updateNowPlaying,track.scrobble" {
+		t.Errorf("methods = %v", methods)
+	}
+
+	fm.APISecret = "wrong"
+	if err := fm.NowPlaying(listen); err == nil || !strings.Contains(err.Error(), "error 13") {
+		t.Errorf("NowPlaying() with a bad secret error = %v", err)
+	}
+}
+
+func TestSubmitQueuesWhileOffline(t *testing.T) {
+	var received []string
+	online := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !online {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var sub lbSubmission
+		json.NewDecoder(r.Body).Decode(&sub)
+		for _, l := range sub.Payload {
+			received = append(received, l.Track.TrackName)
+		}
+	}))
+	defer server.Close()
+
+	lb := NewListenBrainz(server.URL, "secret")
+	q := &Queue{Path: filepath.Join(t.TempDir(), "scrobble-listenbrainz.json")}
+	listen := func(title string) Listen {
+		return Listen{Artist: "Artist", Title: title, ListenedAt: time.Unix(1700000000, 0)}
+	}
+
+	for _, title := range []string{"One", "Two"} {
+		if sent, err := Submit(lb, q, listen(title)); err == nil || sent != 0 {
+			t.Errorf("Submit(%s) offline = %d, %v; want an error", title, sent, err)
+		}
+	}
+	if queued, _ := q.Load(); len(queued) != 2 {
+		t.Fatalf("queued %d listens, want 2", len(queued))
+	}
+
+	online = true
+	sent, err := Submit(lb, q, listen("Three"))
+	if err != nil || sent != 3 {
+		t.Fatalf("Submit() online = %d, %v; want 3", sent, err)
+	}
+	if strings.Join(received, ",") != "One,Two,Three" {
+		t.Errorf("received %v, want oldest first", received)
+	}
+	if queued, _ := q.Load(); len(queued) != 0 {
+		t.Errorf("queue not emptied: %v", queued)
+	}
+}
+
+func TestSubmitDropsRejected(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "Invalid track"}`))
+	}))
+	defer server.Close()
+
+	q := &Queue{Path: filepath.Join(t.TempDir(), "queue.json")}
+	if _, err := Submit(NewListenBrainz(server.URL, "secret"), q, Listen{Title: "Bad"}); err == nil {
+		t.Error("Submit() error = nil, want the rejection")
+	}
+	if queued, _ := q.Load(); len(queued) != 0 {
+		t.Errorf("rejected listen was queued: %v", queued)
+	}
+}
+
+func TestSessionKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "volu", "lastfm-session")
+
+	if key, err := LoadSessionKey(path); err != nil || key != "" {
+		t.Fatalf("LoadSessionKey() without a session = %q, %v; want empty", key, err)
+	}
+	if err := SaveSessionKey(path, "d580d57f32848f5dcf574d1ce18d78b2"); err != nil {
+		t.Fatalf("SaveSessionKey() failed: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %o", info.Mode().Perm())
+	}
+	if key, err := LoadSessionKey(path); err != nil || key != "d580d57f32848f5dcf574d1ce18d78b2" {
+		t.Errorf("LoadSessionKey() = %q, %v", key, err)
+	}
+}
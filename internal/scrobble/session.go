@@ -0,0 +1,47 @@
+package scrobble
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/riclib/volu/internal/atomicfile"
+	"github.com/riclib/volu/internal/config"
+)
+
+// DefaultSessionPath returns where 'volu scrobble login' keeps the Last.fm
+// session key: a file only the user can read, in $XDG_STATE_HOME (or
+// ~/.local/state), rather than the config file.
+func DefaultSessionPath() (string, error) {
+	dir, err := config.StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "lastfm-session"), nil
+}
+
+// LoadSessionKey returns the session key saved at path, or "" if there is
+// none.
+func LoadSessionKey(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read Last.fm session: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// SaveSessionKey writes key to path, readable only by the user.
+func SaveSessionKey(path, key string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if err := atomicfile.WriteFile(path, []byte(key+"\n"), 0600); err != nil {
+		return fmt.Errorf("failed to write Last.fm session: %w", err)
+	}
+	return nil
+}
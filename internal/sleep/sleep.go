@@ -0,0 +1,141 @@
+package sleep
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/riclib/volu/internal/volumio"
+)
+
+// Player is the part of the Volumio client the timer needs.
+type Player interface {
+	FadeOut(ctx context.Context, d time.Duration, curve volumio.Curve, action func() error) error
+	Stop() error
+}
+
+// Timer is a pending sleep timer. It is persisted while the timer
+// process runs so other volu invocations can show or cancel it.
+type Timer struct {
+	PID      int           `json:"pid"`
+	Host     string        `json:"host"`
+	Deadline time.Time     `json:"deadline"` // when playback stops
+	Fade     time.Duration `json:"fade"`     // fade-out before the deadline
+}
+
+// Remaining returns the time left until playback stops.
+func (t *Timer) Remaining(now time.Time) time.Duration {
+	if left := t.Deadline.Sub(now); left > 0 {
+		return left
+	}
+	return 0
+}
+
+// Alive reports whether the timer process is still running.
+func (t *Timer) Alive() bool {
+	if t.PID <= 0 {
+		return false
+	}
+	return syscall.Kill(t.PID, 0) == nil
+}
+
+// Cancel stops the timer process. It restores the volume if it was
+// fading and removes the state file on its way out.
+func (t *Timer) Cancel() error {
+	if !t.Alive() {
+		return nil
+	}
+	if err := syscall.Kill(t.PID, syscall.SIGTERM); err != nil {
+		return fmt.Errorf("failed to stop sleep timer: %w", err)
+	}
+	return nil
+}
+
+// DefaultPath returns the timer state file for host, in $XDG_RUNTIME_DIR
+// when set so a stale timer never survives a reboot.
+func DefaultPath(host string) (string, error) {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get user cache directory: %w", err)
+		}
+		dir = cacheDir
+	}
+	return filepath.Join(dir, "volu", "sleep-"+volumio.HostFileName(host)+".json"), nil
+}
+
+// Load reads the timer at path. A missing file, or one left behind by a
+// process that is no longer running, yields an error wrapping
+// os.ErrNotExist.
+func Load(path string) (*Timer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sleep timer: %w", err)
+	}
+
+	var t Timer
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("failed to parse sleep timer: %w", err)
+	}
+	if !t.Alive() {
+		os.Remove(path)
+		return nil, fmt.Errorf("sleep timer not running: %w", os.ErrNotExist)
+	}
+	return &t, nil
+}
+
+// Save writes the timer to path.
+func (t *Timer) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	data, err := json.Marshal(t)
+	if err != nil {
+		return fmt.Errorf("failed to marshal sleep timer: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write sleep timer: %w", err)
+	}
+	return nil
+}
+
+// Release removes the state file at path if it still belongs to this
+// timer, leaving a newer timer's file alone.
+func (t *Timer) Release(path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	var current Timer
+	if json.Unmarshal(data, &current) == nil && current.PID == t.PID {
+		os.Remove(path)
+	}
+}
+
+// Run waits for the timer, fades the volume to zero over the fade window,
+// stops playback and restores the original volume. The fade is the one
+// transport commands use, so it goes below the client's minimum volume.
+// If ctx is cancelled during the fade, the original volume is restored
+// and ctx's error returned.
+func (t *Timer) Run(ctx context.Context, p Player) error {
+	wait := time.NewTimer(time.Until(t.Deadline.Add(-t.Fade)))
+	defer wait.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-wait.C:
+	}
+
+	if err := p.FadeOut(ctx, time.Until(t.Deadline), volumio.CurveLinear, p.Stop); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("failed to stop playback: %w", err)
+	}
+	return nil
+}
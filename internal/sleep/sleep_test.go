@@ -0,0 +1,130 @@
+package sleep
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/riclib/volu/internal/volumio"
+)
+
+// fakeVolumio plays at volume and records volume changes and stops.
+type fakeVolumio struct {
+	mu      sync.Mutex
+	volume  int
+	volumes []int
+	stopped bool
+}
+
+func (f *fakeVolumio) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.URL.Query().Get("cmd") {
+	case "volume":
+		f.volume, _ = strconv.Atoi(r.URL.Query().Get("volume"))
+		f.volumes = append(f.volumes, f.volume)
+	case "stop":
+		f.stopped = true
+	}
+	status := "play"
+	if f.stopped {
+		status = "stop"
+	}
+	json.NewEncoder(w).Encode(volumio.PlayerState{Status: status, Volume: f.volume})
+}
+
+// newPlayer returns a client for fake with a minimum volume of 5.
+func newPlayer(t *testing.T, fake *fakeVolumio) *volumio.Client {
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return volumio.New(volumio.WithBaseURL(server.URL), volumio.WithVolumeLimits(5, 100))
+}
+
+func TestRunFadesStopsAndRestores(t *testing.T) {
+	fake := &fakeVolumio{volume: 8}
+	timer := &Timer{Deadline: time.Now().Add(time.Second), Fade: 900 * time.Millisecond}
+
+	if err := timer.Run(context.Background(), newPlayer(t, fake)); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if !fake.stopped {
+		t.Error("playback not stopped")
+	}
+	if fake.volume != 8 {
+		t.Errorf("volume after sleep = %d, want 8 restored", fake.volume)
+	}
+	// Fading steps down to silence, below the minimum, before the restore
+	n := len(fake.volumes)
+	if n < 3 || fake.volumes[n-2] != 0 {
+		t.Fatalf("volumes = %v, want a fade ending in 0 then 8", fake.volumes)
+	}
+	for i := 1; i < n-1; i++ {
+		if fake.volumes[i] > fake.volumes[i-1] {
+			t.Errorf("volume rose during fade: %v", fake.volumes)
+		}
+	}
+}
+
+func TestRunCancelledDuringFade(t *testing.T) {
+	fake := &fakeVolumio{volume: 40}
+	timer := &Timer{Deadline: time.Now().Add(5 * time.Second), Fade: 5 * time.Second}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 600*time.Millisecond)
+	defer cancel()
+	if err := timer.Run(ctx, newPlayer(t, fake)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Run() error = %v, want deadline exceeded", err)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if fake.stopped || fake.volume != 40 {
+		t.Errorf("after cancel: stopped %v, volume %d; want playing at 40", fake.stopped, fake.volume)
+	}
+}
+
+func TestTimerPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sleep.json")
+
+	if _, err := Load(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Load(missing) error = %v", err)
+	}
+
+	timer := &Timer{PID: os.Getpid(), Host: "volumio.local", Deadline: time.Now().Add(30 * time.Minute), Fade: 2 * time.Minute}
+	if err := timer.Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if left := loaded.Remaining(time.Now()); left < 29*time.Minute || loaded.Fade != 2*time.Minute {
+		t.Errorf("loaded timer = %+v, remaining %s", loaded, left)
+	}
+
+	// Another timer's release leaves the file alone
+	(&Timer{PID: os.Getpid() + 1}).Release(path)
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("file removed by another timer: %v", err)
+	}
+	timer.Release(path)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("file not released: %v", err)
+	}
+
+	// A timer whose process is gone is treated as missing
+	(&Timer{PID: 1 << 30}).Save(path)
+	if _, err := Load(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Load(dead) error = %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package volumio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/riclib/volu/internal/atomicfile"
+)
+
+// Circuit breaker defaults
+const (
+	DefaultBreakerThreshold = 3                // failed requests in a row that open the circuit
+	DefaultBreakerCooldown  = 30 * time.Second // how long it stays open before trying again
+)
+
+// ErrUnreachable is returned without contacting the player while the
+// circuit breaker is open
+var ErrUnreachable = errors.New("player unreachable")
+
+// Breaker stops requests to a player that keeps failing to answer, so a
+// dead Pi fails fast instead of making every caller wait for timeouts.
+// Its state lives in a small file, so separate volu invocations (e.g.
+// one per Waybar tick) share it. After Threshold requests in a row fail
+// to reach the player, requests fail with ErrUnreachable for Cooldown.
+// After that, requests from every process go through again until one of
+// them succeeds, closing the circuit, or fails, reopening it
+type Breaker struct {
+	Path      string
+	Threshold int
+	Cooldown  time.Duration
+}
+
+// breakerState is what the breaker file holds
+type breakerState struct {
+	Failures  int       `json:"failures"`
+	OpenUntil time.Time `json:"open_until,omitempty"`
+}
+
+// NewBreaker creates a breaker keeping its state in path, with the
+// default threshold and cooldown
+func NewBreaker(path string) *Breaker {
+	return &Breaker{Path: path, Threshold: DefaultBreakerThreshold, Cooldown: DefaultBreakerCooldown}
+}
+
+// DefaultBreaker returns the breaker for host in the user's cache
+// directory
+func DefaultBreaker(host string) (*Breaker, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user cache directory: %w", err)
+	}
+	return NewBreaker(filepath.Join(cacheDir, "volu", "breaker-"+HostFileName(host)+".json")), nil
+}
+
+// Allow returns ErrUnreachable, wrapped with when the next try is due,
+// while the circuit is open
+func (b *Breaker) Allow(now time.Time) error {
+	s := b.load()
+	if now.Before(s.OpenUntil) {
+		return fmt.Errorf("%w: %d requests failed in a row, next try after %s", ErrUnreachable, s.Failures, s.OpenUntil.Format("15:04:05"))
+	}
+	return nil
+}
+
+// Record counts a request that failed to reach the player, or resets the
+// count after one that did
+func (b *Breaker) Record(now time.Time, failed bool) error {
+	s := b.load()
+	if !failed {
+		if s.Failures == 0 {
+			return nil
+		}
+		return b.Reset()
+	}
+
+	s.Failures++
+	if s.Failures >= b.threshold() {
+		s.OpenUntil = now.Add(b.cooldown())
+	}
+	return b.save(s)
+}
+
+// Reset closes the circuit
+func (b *Breaker) Reset() error {
+	if err := os.Remove(b.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to reset circuit breaker: %w", err)
+	}
+	return nil
+}
+
+func (b *Breaker) threshold() int {
+	if b.Threshold <= 0 {
+		return DefaultBreakerThreshold
+	}
+	return b.Threshold
+}
+
+func (b *Breaker) cooldown() time.Duration {
+	if b.Cooldown <= 0 {
+		return DefaultBreakerCooldown
+	}
+	return b.Cooldown
+}
+
+// load reads the breaker state; a missing or broken file is a closed
+// circuit
+func (b *Breaker) load() breakerState {
+	var s breakerState
+	if data, err := os.ReadFile(b.Path); err == nil {
+		json.Unmarshal(data, &s)
+	}
+	return s
+}
+
+// save writes the breaker state through a temporary file, so other
+// processes never read half of it
+func (b *Breaker) save(s breakerState) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to encode circuit breaker: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(b.Path), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	if err := atomicfile.WriteFile(b.Path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write circuit breaker: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package volumio
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// BrowseInfo is the header Volumio sends above album, artist and
+// playlist listings
+type BrowseInfo struct {
+	URI      string `json:"uri"`
+	Service  string `json:"service"`
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Artist   string `json:"artist"`
+	Album    string `json:"album"`
+	AlbumArt string `json:"albumart"`
+}
+
+// BrowseList is one titled section of a browse result, e.g. the
+// "Albums" or "Top tracks" of an artist page
+type BrowseList struct {
+	Title              string       `json:"title"`
+	Icon               string       `json:"icon"`
+	AvailableListViews []string     `json:"availableListViews"`
+	Items              []BrowseItem `json:"items"`
+}
+
+// UnmarshalJSON accepts both list objects and bare arrays of items,
+// which some plugins send instead
+func (l *BrowseList) UnmarshalJSON(data []byte) error {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		*l = BrowseList{}
+		return json.Unmarshal(trimmed, &l.Items)
+	}
+
+	type plain BrowseList
+	return json.Unmarshal(data, (*plain)(l))
+}
+
+// BrowseResult is a parsed browse response
+type BrowseResult struct {
+	Prev  string       `json:"prev,omitempty"` // URI of the parent folder, if any
+	Info  *BrowseInfo  `json:"info,omitempty"` // header for albums, artists and playlists
+	Lists []BrowseList `json:"lists"`
+}
+
+// Items returns the items of all lists in order
+func (r *BrowseResult) Items() []BrowseItem {
+	var items []BrowseItem
+	for _, list := range r.Lists {
+		items = append(items, list.Items...)
+	}
+	return items
+}
+
+// browseResponse covers the shapes Volumio and its plugins use for
+// browse responses. Current versions nest everything in navigation;
+// older ones put lists or items at the top level.
+type browseResponse struct {
+	Navigation *struct {
+		Prev *struct {
+			URI string `json:"uri"`
+		} `json:"prev"`
+		Info  *BrowseInfo  `json:"info"`
+		Lists []BrowseList `json:"lists"`
+	} `json:"navigation"`
+	Lists []BrowseList `json:"lists"`
+	List  []BrowseItem `json:"list"`
+	Items []BrowseItem `json:"items"`
+}
+
+// ParseBrowse parses the body of a browse response
+func ParseBrowse(body []byte) (*BrowseResult, error) {
+	var response browseResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse browse response: %w", err)
+	}
+
+	result := &BrowseResult{}
+	if nav := response.Navigation; nav != nil {
+		if nav.Prev != nil {
+			result.Prev = nav.Prev.URI
+		}
+		result.Info = nav.Info
+		result.Lists = nav.Lists
+	}
+
+	switch {
+	case len(result.Lists) > 0:
+	case len(response.Lists) > 0:
+		result.Lists = response.Lists
+	case len(response.List) > 0:
+		result.Lists = []BrowseList{{Items: response.List}}
+	case len(response.Items) > 0:
+		result.Lists = []BrowseList{{Items: response.Items}}
+	}
+
+	return result, nil
+}
+
+// BrowseResult browses the music library at the given URI and returns
+// every list along with the header and parent URI
+func (c *Client) BrowseResult(uri string) (*BrowseResult, error) {
+	params := url.Values{}
+	if uri != "" {
+		params.Set("uri", uri)
+	}
+
+	body, err := c.get("/api/v1/browse", params)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseBrowse(body)
+}
+
+// Browse browses the music library at the given URI and returns the
+// items of all lists
+func (c *Client) Browse(uri string) ([]BrowseItem, error) {
+	result, err := c.BrowseResult(uri)
+	if err != nil {
+		return nil, err
+	}
+	return result.Items(), nil
+}
@@ -0,0 +1,138 @@
+package volumio
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files")
+
+// browseCase is a browse response with the golden file it is checked
+// against.
+type browseCase struct {
+	name   string
+	body   []byte
+	golden string
+}
+
+// browseCases returns the hand-written responses in testdata/browse and
+// every browse exchange in the cassettes under testdata/browse/recorded,
+// recorded from real players with 'volu --record'.
+func browseCases(t *testing.T) []browseCase {
+	t.Helper()
+	var cases []browseCase
+
+	written, err := filepath.Glob(filepath.Join("testdata", "browse", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range written {
+		body, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		cases = append(cases, browseCase{name: name, body: body, golden: strings.TrimSuffix(path, ".json") + ".golden"})
+	}
+
+	recorded, err := filepath.Glob(filepath.Join("testdata", "browse", "recorded", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range recorded {
+		c, err := LoadCassette(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		base := strings.TrimSuffix(path, ".json")
+		n := 0
+		for _, in := range c.Interactions {
+			if !strings.HasPrefix(in.URL, "/api/v1/browse?") || in.Status != http.StatusOK {
+				continue
+			}
+			n++
+			name := fmt.Sprintf("%s-%d", filepath.Base(base), n)
+			cases = append(cases, browseCase{name: "recorded/" + name, body: in.Body, golden: fmt.Sprintf("%s-%d.golden", base, n)})
+		}
+	}
+	return cases
+}
+
+// TestParseBrowseGolden parses the browse responses from browseCases and
+// compares the result with the matching .golden file. Run with -update
+// after adding a response or a cassette.
+func TestParseBrowseGolden(t *testing.T) {
+	cases := browseCases(t)
+	if len(cases) == 0 {
+		t.Fatal("no browse responses found")
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := ParseBrowse(tc.body)
+			if err != nil {
+				t.Fatalf("ParseBrowse() error = %v", err)
+			}
+
+			got, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+
+			if *update {
+				if err := os.WriteFile(tc.golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(tc.golden)
+			if err != nil {
+				t.Fatalf("missing golden file, run with -update: %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("ParseBrowse() mismatch for %s:\n%s\nwant:\n%s", tc.name, got, want)
+			}
+		})
+	}
+}
+
+func TestBrowseKeepsAllLists(t *testing.T) {
+	body, err := os.ReadFile(filepath.Join("testdata", "browse", "spotify-artist.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("uri") != "spotify:artist:4tZwfgrHOc3mvqYlEYSvVi" {
+			t.Errorf("uri = %q", r.URL.Query().Get("uri"))
+		}
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	items, err := client.Browse("spotify:artist:4tZwfgrHOc3mvqYlEYSvVi")
+	if err != nil {
+		t.Fatalf("Browse() error = %v", err)
+	}
+	if len(items) != 3 || items[2].Title != "Justice" {
+		t.Errorf("Browse() = %+v", items)
+	}
+}
+
+func TestParseBrowseInvalid(t *testing.T) {
+	if _, err := ParseBrowse([]byte(`{"navigation":`)); err == nil {
+		t.Error("expected error for truncated response")
+	}
+	result, err := ParseBrowse([]byte(`{}`))
+	if err != nil || len(result.Lists) != 0 || result.Info != nil {
+		t.Errorf("ParseBrowse({}) = %+v, %v", result, err)
+	}
+}
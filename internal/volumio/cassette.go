@@ -0,0 +1,287 @@
+package volumio
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RedactedHost replaces the player's hostname in recorded cassettes
+const RedactedHost = "volumio.local"
+
+// Interaction is one recorded request and its response. URLs are stored
+// without scheme and host, so a cassette replays against any base URL
+type Interaction struct {
+	Method      string          `json:"method"`
+	URL         string          `json:"url"` // path and query
+	RequestBody json.RawMessage `json:"request_body,omitempty"`
+	Status      int             `json:"status"`
+	ContentType string          `json:"content_type,omitempty"`
+	Body        json.RawMessage `json:"body,omitempty"` // JSON responses
+	Text        string          `json:"text,omitempty"` // anything else
+}
+
+// Cassette is a recorded series of Volumio API exchanges
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// LoadCassette reads a cassette file
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+	var c Cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse cassette: %w", err)
+	}
+	return &c, nil
+}
+
+// Save writes the cassette to path as indented JSON
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cassette: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	return nil
+}
+
+// Recorder is an http.RoundTripper that passes requests on to a real
+// Volumio and records every exchange to a cassette file. The player's
+// hostname, and any of Hosts, are replaced by RedactedHost in what is
+// recorded, so album art URLs and the like don't leak the local network
+type Recorder struct {
+	Path      string
+	Transport http.RoundTripper // default: http.DefaultTransport
+	Hosts     []string          // more hostnames or addresses to redact
+
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+// NewRecorder creates a recorder writing to path. The file is rewritten
+// after every exchange, so a recording survives the process being killed
+func NewRecorder(path string) *Recorder {
+	return &Recorder{Path: path}
+}
+
+// Cassette returns what has been recorded so far
+func (r *Recorder) Cassette() *Cassette {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &Cassette{Interactions: append([]Interaction(nil), r.cassette.Interactions...)}
+}
+
+// RoundTrip sends req and records the exchange
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var requestBody []byte
+	if req.Body != nil {
+		var err error
+		if requestBody, err = io.ReadAll(req.Body); err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(requestBody))
+	}
+
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	redact := r.redactor(req)
+	interaction := Interaction{
+		Method:      req.Method,
+		URL:         redact.Replace(req.URL.RequestURI()),
+		Status:      resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+	}
+	if json.Valid(requestBody) {
+		interaction.RequestBody = json.RawMessage(redact.Replace(string(requestBody)))
+	}
+	if redacted := redact.Replace(string(body)); json.Valid(body) {
+		interaction.Body = json.RawMessage(redacted)
+	} else {
+		interaction.Text = redacted
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	if err := r.cassette.Save(r.Path); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// redactor replaces the request's hostname and Hosts with RedactedHost
+func (r *Recorder) redactor(req *http.Request) hostRedactor {
+	var hosts hostRedactor
+	for _, host := range append([]string{req.URL.Hostname()}, r.Hosts...) {
+		if host != "" && host != RedactedHost {
+			hosts = append(hosts, host)
+		}
+	}
+	// Longest first, so "pi.local" is replaced whole rather than as "pi"
+	sort.Slice(hosts, func(i, j int) bool { return len(hosts[i]) > len(hosts[j]) })
+	return hosts
+}
+
+// hostRedactor replaces whole host names in text with RedactedHost. A
+// host only matches where it isn't part of a longer name or word, so a
+// short host such as "pi" leaves "/api/v1" alone
+type hostRedactor []string
+
+// Replace returns s with the hosts replaced
+func (h hostRedactor) Replace(s string) string {
+	for _, host := range h {
+		var b strings.Builder
+		rest := s
+		for {
+			i := strings.Index(rest, host)
+			if i < 0 {
+				break
+			}
+			end := i + len(host)
+			b.WriteString(rest[:i])
+			if hostBoundary(rest[:i], rest[end:]) {
+				b.WriteString(RedactedHost)
+			} else {
+				b.WriteString(host)
+			}
+			rest = rest[end:]
+		}
+		b.WriteString(rest)
+		s = b.String()
+	}
+	return s
+}
+
+// hostBoundary reports whether a host found between before and after
+// stands on its own: not preceded by a name character or dot, and not
+// followed by a name character or by a dot starting another label
+func hostBoundary(before, after string) bool {
+	if before != "" {
+		if c := before[len(before)-1]; hostChar(c) || c == '.' {
+			return false
+		}
+	}
+	if after != "" {
+		if hostChar(after[0]) {
+			return false
+		}
+		if after[0] == '.' && len(after) > 1 && hostChar(after[1]) {
+			return false
+		}
+	}
+	return true
+}
+
+// hostChar reports whether c can be part of a host name label
+func hostChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_'
+}
+
+// Replayer is an http.RoundTripper that answers requests from a
+// cassette instead of a network. Requests match an interaction by
+// method, path, query and body; repeated requests get the matching
+// interactions in recorded order, then the last one again, so polling
+// code keeps seeing the final state
+type Replayer struct {
+	cassette *Cassette
+
+	mu   sync.Mutex
+	used map[int]bool
+}
+
+// NewReplayer creates a replayer for c
+func NewReplayer(c *Cassette) *Replayer {
+	return &Replayer{cassette: c, used: map[int]bool{}}
+}
+
+// RoundTrip answers req from the cassette
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	var requestBody []byte
+	if req.Body != nil {
+		var err error
+		if requestBody, err = io.ReadAll(req.Body); err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+		req.Body.Close()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	last := -1
+	for i, in := range r.cassette.Interactions {
+		if in.Method != req.Method || in.URL != req.URL.RequestURI() || !sameJSON(in.RequestBody, requestBody) {
+			continue
+		}
+		last = i
+		if !r.used[i] {
+			break
+		}
+	}
+	if last < 0 {
+		return nil, fmt.Errorf("no recorded response for %s %s", req.Method, req.URL.RequestURI())
+	}
+	r.used[last] = true
+
+	in := r.cassette.Interactions[last]
+	body := []byte(in.Text)
+	if len(in.Body) > 0 {
+		body = in.Body
+	}
+	header := http.Header{}
+	if in.ContentType != "" {
+		header.Set("Content-Type", in.ContentType)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", in.Status, http.StatusText(in.Status)),
+		StatusCode:    in.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// sameJSON reports whether a recorded request body matches a new one,
+// ignoring formatting
+func sameJSON(recorded json.RawMessage, body []byte) bool {
+	if len(recorded) == 0 || len(body) == 0 {
+		return len(recorded) == 0 && len(body) == 0
+	}
+	var a, b interface{}
+	if json.Unmarshal(recorded, &a) != nil || json.Unmarshal(body, &b) != nil {
+		return bytes.Equal(recorded, body)
+	}
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return bytes.Equal(x, y)
+}
@@ -0,0 +1,165 @@
+package volumio
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestReplayLiveStatus replays the getState response behind the live
+// test results in STATUS.md.
+func TestReplayLiveStatus(t *testing.T) {
+	cassette, err := LoadCassette(filepath.Join("testdata", "cassettes", "live-status.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient("http://volumio.local:3000", WithTransport(NewReplayer(cassette)))
+
+	state, err := client.GetState()
+	if err != nil {
+		t.Fatalf("GetState() error = %v", err)
+	}
+	want := PlayerState{
+		Status:   "pause",
+		Position: 2,
+		Seek:     2184,
+		Title:    "The Dance Of The Flames",
+		Artist:   "Arno Elias",
+		Album:    "Buddha Bar Nature",
+		AlbumArt: state.AlbumArt,
+		Duration: 299,
+		Volume:   90,
+		Service:  "mpd",
+		URI:      "mnt/NAS/Music/Buddha Bar Nature/01 - The Dance Of The Flames.flac",
+	}
+	if *state != want {
+		t.Errorf("state = %+v, want %+v", *state, want)
+	}
+	if got := client.GetAlbumArtURL(state.AlbumArt); !strings.HasPrefix(got, "http://volumio.local:3000/albumart?") {
+		t.Errorf("album art URL = %s", got)
+	}
+
+	if err := client.Next(); err == nil || !strings.Contains(err.Error(), "no recorded response for GET /api/v1/commands/?cmd=next") {
+		t.Errorf("Next() error = %v, want no recorded response", err)
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	volume := 30
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/getState":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status":   "play",
+				"volume":   volume,
+				"albumart": "http://" + r.Host + "/albumart?path=x",
+			})
+		case "/api/v1/commands/":
+			volume = 50
+			w.Write([]byte(`{"response":"volume Success"}`))
+		case "/api/v1/addToQueue":
+			w.Write([]byte(`{"response":"success"}`))
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	recording := NewClient(server.URL, WithTransport(NewRecorder(path)))
+	recording.GetState()
+	recording.SetVolume(50)
+	recording.GetState()
+	recording.AddToQueue("mnt/NAS/Album", "mpd")
+	recording.Browse("missing")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("cassette not written: %v", err)
+	}
+	if strings.Contains(string(data), "127.0.0.1") {
+		t.Errorf("cassette leaks the host:\n%s", data)
+	}
+
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cassette.Interactions) != 5 || cassette.Interactions[4].Status != 404 || cassette.Interactions[4].Text != "not found\n" {
+		t.Fatalf("interactions = %+v", cassette.Interactions)
+	}
+
+	client := NewClient("http://volumio.local:3000", WithTransport(NewReplayer(cassette)))
+	var volumes []int
+	for i := 0; i < 3; i++ {
+		state, err := client.GetState()
+		if err != nil {
+			t.Fatalf("GetState() error = %v", err)
+		}
+		volumes = append(volumes, state.Volume)
+		if i == 0 && state.AlbumArt != "http://volumio.local:"+strings.Split(server.URL, ":")[2]+"/albumart?path=x" {
+			t.Errorf("album art = %s, want the host redacted", state.AlbumArt)
+		}
+	}
+	if volumes[0] != 30 || volumes[1] != 50 || volumes[2] != 50 {
+		t.Errorf("replayed volumes = %v, want 30, 50, then the last again", volumes)
+	}
+	if err := client.AddToQueue("mnt/NAS/Album", "mpd"); err != nil {
+		t.Errorf("AddToQueue() error = %v", err)
+	}
+	if err := client.AddToQueue("mnt/NAS/Other", "mpd"); err == nil {
+		t.Error("AddToQueue() with a different body should not match")
+	}
+	if _, err := client.Browse("missing"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Browse() error = %v, want the recorded 404", err)
+	}
+}
+
+func TestRecordShortHost(t *testing.T) {
+	// The player is called "pi", which also appears inside "/api/v1"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":   "play",
+			"title":    "Pipeline",
+			"albumart": "http://pi:3000/albumart?path=x",
+			"uri":      "http://pi.example.com/stream",
+		})
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	recorder := NewRecorder(path)
+	recorder.Hosts = []string{"pi"}
+	recording := NewClient(server.URL, WithTransport(recorder))
+	if _, err := recording.GetState(); err != nil {
+		t.Fatal(err)
+	}
+
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := cassette.Interactions[0]
+	if in.URL != "/api/v1/getState" {
+		t.Errorf("URL = %q, want /api/v1/getState", in.URL)
+	}
+	body := string(in.Body)
+	for _, want := range []string{`"Pipeline"`, `"http://volumio.local:3000/albumart?path=x"`, `"http://pi.example.com/stream"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body lacks %s:\n%s", want, body)
+		}
+	}
+
+	replaying := NewClient("http://"+RedactedHost+":3000", WithTransport(NewReplayer(cassette)), WithRetries(0, 0))
+	state, err := replaying.GetState()
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	if state.Title != "Pipeline" {
+		t.Errorf("replayed title = %q", state.Title)
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -14,6 +16,7 @@ import (
 type PlayerState struct {
 	Status   string `json:"status"`
 	Position int    `json:"position"`
+	Seek     int    `json:"seek"` // Elapsed time in the current track, in milliseconds
 	Title    string `json:"title"`
 	Artist   string `json:"artist"`
 	Album    string `json:"album"`
@@ -22,10 +25,35 @@ type PlayerState struct {
 	Volume   int    `json:"volume"`
 	Mute     bool   `json:"mute"`
 	Service  string `json:"service"`
+	URI      string `json:"uri"`
 	Random   bool   `json:"random"`
 	Repeat   bool   `json:"repeat"`
 }
 
+// HostFileName turns a host, as given to -H, into something safe to use
+// in a file name. Characters other than letters, digits, dots, dashes and
+// underscores become underscores, so "pi:3000" and "[fe80::1]" work too
+func HostFileName(host string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, host)
+}
+
+// TrackKey identifies the track in state, so every watcher agrees on what
+// a track change is. Web radio keeps its URI while the stream title
+// changes, so the title and artist are part of the key. It is empty when
+// nothing is loaded
+func TrackKey(state *PlayerState) string {
+	if state == nil || (state.URI == "" && state.Title == "") {
+		return ""
+	}
+	return state.URI + "\x00" + state.Artist + "\x00" + state.Title
+}
+
 // BrowseItem represents an item in the Volumio music library
 type BrowseItem struct {
 	URI        string `json:"uri"`
@@ -36,6 +64,7 @@ type BrowseItem struct {
 	Artist     string `json:"artist"`
 	Album      string `json:"album"`
 	AlbumArt   string `json:"albumart"`
+	Icon       string `json:"icon"`
 	PluginType string `json:"plugin_type"`
 	PluginName string `json:"plugin_name"`
 }
@@ -65,40 +94,237 @@ func (b *BrowseItem) IsBrowsable() bool {
 type Client struct {
 	baseURL    string
 	httpClient *http.Client
+	userAgent  string
+
+	connectTimeout time.Duration
+	retries        int
+	retryBackoff   time.Duration
+	breaker        *Breaker
+	fadeMemory     *FadeMemory
+
+	minVolume, maxVolume int // limits applied by SetVolume
+
+	observer func(endpoint string, d time.Duration, err error)
+	logf     func(format string, args ...interface{})
 }
 
-// NewClient creates a new Volumio API client
-func NewClient(baseURL string) *Client {
-	if baseURL == "" {
-		baseURL = "http://volumio.local:3000"
+// DefaultBaseURL is the player a client talks to unless told otherwise
+const DefaultBaseURL = "http://volumio.local:3000"
+
+// DefaultTimeout bounds each request attempt, including reading the
+// response
+const DefaultTimeout = 10 * time.Second
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithBaseURL sets the player's API address, e.g. "http://volumio.local:3000"
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		if baseURL != "" {
+			c.baseURL = strings.TrimSuffix(baseURL, "/")
+		}
+	}
+}
+
+// WithTimeout bounds each request attempt, including reading the
+// response
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.httpClient.Timeout = d
+	}
+}
+
+// WithConnectTimeout bounds looking up and connecting to the player,
+// so a player that is off fails fast. It applies unless WithTransport
+// replaces the transport
+func WithConnectTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.connectTimeout = d
+	}
+}
+
+// WithRetries sets how often failed requests are retried, up to
+// MaxRetries, waiting backoff before the first retry and doubling it, with
+// jitter, after each. Requests that may have reached the player are only
+// retried if repeating them is harmless, so e.g. next is never skipped
+// twice
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries = min(max(retries, 0), MaxRetries)
+		c.retryBackoff = backoff
+	}
+}
+
+// WithBreaker makes requests fail fast with ErrUnreachable while b says
+// the player is down
+func WithBreaker(b *Breaker) Option {
+	return func(c *Client) {
+		c.breaker = b
 	}
-	return &Client{
-		baseURL: baseURL,
+}
+
+// WithFadeMemory keeps the volume a fade started from in m while it runs,
+// so fades started by other processes mid-fade restore the right volume
+func WithFadeMemory(m *FadeMemory) Option {
+	return func(c *Client) {
+		c.fadeMemory = m
+	}
+}
+
+// WithTransport makes the client send requests through rt instead of
+// http.DefaultTransport, e.g. to record or replay Volumio exchanges
+func WithTransport(rt http.RoundTripper) Option {
+	return func(c *Client) {
+		c.httpClient.Transport = rt
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+// WithLogger makes the client log every request with its duration and
+// error through logf, e.g. log.Printf
+func WithLogger(logf func(format string, args ...interface{})) Option {
+	return func(c *Client) {
+		c.logf = logf
+	}
+}
+
+// WithVolumeLimits restricts every volume change made through the client
+// to min–max, as SetVolumeLimits does
+func WithVolumeLimits(min, max int) Option {
+	return func(c *Client) {
+		c.SetVolumeLimits(min, max)
+	}
+}
+
+// New creates a Volumio API client for DefaultBaseURL, or the player set
+// with WithBaseURL
+func New(opts ...Option) *Client {
+	c := &Client{
+		baseURL: DefaultBaseURL,
 		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
+			Timeout: DefaultTimeout,
 		},
+		connectTimeout: DefaultConnectTimeout,
+		retries:        DefaultRetries,
+		retryBackoff:   DefaultRetryBackoff,
+		maxVolume:      100,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.httpClient.Transport == nil && c.connectTimeout > 0 {
+		c.httpClient.Transport = dialer(c.connectTimeout)
+	}
+	return c
+}
+
+// NewClient creates a new Volumio API client
+func NewClient(baseURL string, opts ...Option) *Client {
+	return New(append([]Option{WithBaseURL(baseURL)}, opts...)...)
 }
 
 // NewClientWithHost creates a client for a specific host
-func NewClientWithHost(host string) *Client {
-	return NewClient(fmt.Sprintf("http://%s:3000", host))
+func NewClientWithHost(host string, opts ...Option) *Client {
+	return NewClient(fmt.Sprintf("http://%s:3000", host), opts...)
+}
+
+// SetObserver makes the client report every API request to fn, with the
+// endpoint (e.g. "getState" or "commands/play"), how long it took and its
+// error, for metrics
+func (c *Client) SetObserver(fn func(endpoint string, d time.Duration, err error)) {
+	c.observer = fn
+}
+
+// observe reports a request that started at start to the observer and
+// the logger
+func (c *Client) observe(endpoint string, params url.Values, start time.Time, err error) {
+	if c.observer == nil && c.logf == nil {
+		return
+	}
+	name := strings.Trim(strings.TrimPrefix(endpoint, "/api/v1/"), "/")
+	if cmd := params.Get("cmd"); name == "commands" && cmd != "" {
+		name += "/" + cmd
+	}
+	d := time.Since(start)
+	if c.observer != nil {
+		c.observer(name, d, err)
+	}
+	if c.logf != nil {
+		if err != nil {
+			c.logf("volumio %s failed after %s: %v", name, d.Round(time.Millisecond), err)
+		} else {
+			c.logf("volumio %s took %s", name, d.Round(time.Millisecond))
+		}
+	}
 }
 
 func (c *Client) get(endpoint string, params url.Values) ([]byte, error) {
+	start := time.Now()
+	body, err := c.send(idempotentGet(endpoint, params), func() ([]byte, error) {
+		return c.doGet(endpoint, params)
+	})
+	c.observe(endpoint, params, start, err)
+	return body, err
+}
+
+func (c *Client) doGet(endpoint string, params url.Values) ([]byte, error) {
 	u := c.baseURL + endpoint
 	if params != nil {
 		u += "?" + params.Encode()
 	}
 
-	resp, err := c.httpClient.Get(u)
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	return c.do(req)
+}
+
+func (c *Client) post(endpoint string, data interface{}) ([]byte, error) {
+	start := time.Now()
+	body, err := c.send(idempotentPost(endpoint), func() ([]byte, error) {
+		return c.doPost(endpoint, data)
+	})
+	c.observe(endpoint, nil, start, err)
+	return body, err
+}
+
+func (c *Client) doPost(endpoint string, data interface{}) ([]byte, error) {
+	// Volumio POST endpoints accept JSON
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+endpoint, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.do(req)
+}
+
+// do sends req and returns the body of a successful response
+func (c *Client) do(req *http.Request) ([]byte, error) {
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, &StatusError{StatusCode: resp.StatusCode}
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -109,12 +335,6 @@ func (c *Client) get(endpoint string, params url.Values) ([]byte, error) {
 	return body, nil
 }
 
-func (c *Client) post(endpoint string, data interface{}) ([]byte, error) {
-	// Volumio POST endpoints accept JSON
-	// Implementation will be added when needed
-	return nil, fmt.Errorf("not implemented")
-}
-
 // GetState retrieves the current player state
 func (c *Client) GetState() (*PlayerState, error) {
 	body, err := c.get("/api/v1/getState", nil)
@@ -195,16 +415,38 @@ func (c *Client) Previous() error {
 
 // Volume control
 
-// SetVolume sets the volume level (0-100)
-func (c *Client) SetVolume(volume int) error {
-	// Clamp to valid range
-	if volume < 0 {
-		volume = 0
+// SetVolumeLimits restricts every volume change made through the client
+// to min–max, e.g. to protect small speakers. A max of zero means 100.
+func (c *Client) SetVolumeLimits(min, max int) {
+	if max <= 0 || max > 100 {
+		max = 100
 	}
-	if volume > 100 {
-		volume = 100
+	c.minVolume = clampVolume(min, 0, max)
+	c.maxVolume = max
+}
+
+func clampVolume(volume, min, max int) int {
+	if volume < min {
+		return min
+	}
+	if volume > max {
+		return max
 	}
+	return volume
+}
+
+// SetVolume sets the volume level (0-100), kept within the client's limits
+func (c *Client) SetVolume(volume int) error {
+	return c.setVolume(clampVolume(volume, c.minVolume, c.maxVolume))
+}
+
+// silenceVolume sets the volume like SetVolume but may go below the
+// minimum, for fades that pause or start playback from silence
+func (c *Client) silenceVolume(volume int) error {
+	return c.setVolume(clampVolume(volume, 0, c.maxVolume))
+}
 
+func (c *Client) setVolume(volume int) error {
 	params := url.Values{}
 	params.Set("cmd", "volume")
 	params.Set("volume", fmt.Sprintf("%d", volume))
@@ -230,6 +472,26 @@ func (c *Client) VolumeDown(step int) error {
 	return c.SetVolume(state.Volume - step)
 }
 
+// ParseVolumeChange parses a volume level ("50" or "50%") or a relative
+// change ("+3" or "-5")
+func ParseVolumeChange(s string) (level int, relative bool, err error) {
+	relative = strings.HasPrefix(s, "+") || strings.HasPrefix(s, "-")
+	level, err = strconv.Atoi(strings.TrimSuffix(s, "%"))
+	if err != nil || (!relative && (level < 0 || level > 100)) {
+		return 0, false, fmt.Errorf("invalid volume %q (use 0-100, 50%%, +3 or -5)", s)
+	}
+	return level, relative, nil
+}
+
+// ChangeVolume sets the volume to level, or changes it by level if
+// relative
+func (c *Client) ChangeVolume(level int, relative bool) error {
+	if relative {
+		return c.VolumeUp(level)
+	}
+	return c.SetVolume(level)
+}
+
 // Mute mutes audio
 func (c *Client) Mute() error {
 	params := url.Values{}
@@ -289,82 +551,6 @@ func (c *Client) ClearQueue() error {
 
 // Browse and playback
 
-// BrowseResponse represents the complex nested response from the browse API
-type BrowseResponse struct {
-	Navigation *struct {
-		Lists []json.RawMessage `json:"lists"`
-	} `json:"navigation"`
-	Lists []json.RawMessage `json:"lists"`
-	List  []BrowseItem      `json:"list"`
-	Items []BrowseItem      `json:"items"`
-}
-
-// Browse browses the music library at the given URI
-func (c *Client) Browse(uri string) ([]BrowseItem, error) {
-	params := url.Values{}
-	if uri != "" {
-		params.Set("uri", uri)
-	}
-
-	body, err := c.get("/api/v1/browse", params)
-	if err != nil {
-		return nil, err
-	}
-
-	// Parse the complex nested response structure
-	var response BrowseResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, fmt.Errorf("failed to parse browse response: %w", err)
-	}
-
-	// Try different extraction paths based on Volumio's response structure
-	var items []BrowseItem
-
-	// Path 1: navigation.lists[0].items
-	if response.Navigation != nil && len(response.Navigation.Lists) > 0 {
-		var firstList struct {
-			Items []BrowseItem `json:"items"`
-		}
-		if err := json.Unmarshal(response.Navigation.Lists[0], &firstList); err == nil && len(firstList.Items) > 0 {
-			items = firstList.Items
-		} else {
-			// Try parsing as direct list of items
-			var directItems []BrowseItem
-			if err := json.Unmarshal(response.Navigation.Lists[0], &directItems); err == nil {
-				items = directItems
-			}
-		}
-	}
-
-	// Path 2: lists[0].items (fallback)
-	if len(items) == 0 && len(response.Lists) > 0 {
-		var firstList struct {
-			Items []BrowseItem `json:"items"`
-		}
-		if err := json.Unmarshal(response.Lists[0], &firstList); err == nil && len(firstList.Items) > 0 {
-			items = firstList.Items
-		} else {
-			// Try parsing as direct list
-			var directItems []BrowseItem
-			if err := json.Unmarshal(response.Lists[0], &directItems); err == nil {
-				items = directItems
-			}
-		}
-	}
-
-	// Path 3: list (direct)
-	if len(items) == 0 && len(response.List) > 0 {
-		items = response.List
-	}
-
-	// Path 4: items (direct)
-	if len(items) == 0 && len(response.Items) > 0 {
-		items = response.Items
-	}
-
-	return items, nil
-}
-
 // SearchList represents a list in the search response
 type SearchList struct {
 	Title string       `json:"title"`
@@ -425,6 +611,25 @@ func (c *Client) SearchAlbums(query string) ([]BrowseItem, error) {
 	return []BrowseItem{}, nil
 }
 
+// SearchWebRadio searches for web radio stations matching the given query.
+// Returns the stations from every result list, in order.
+func (c *Client) SearchWebRadio(query string) ([]BrowseItem, error) {
+	response, err := c.Search(query)
+	if err != nil {
+		return nil, err
+	}
+
+	var stations []BrowseItem
+	for _, list := range response.Navigation.Lists {
+		for _, item := range list.Items {
+			if item.Type == "webradio" {
+				stations = append(stations, item)
+			}
+		}
+	}
+	return stations, nil
+}
+
 // containsIgnoreCase checks if s contains substr, case-insensitive
 func containsIgnoreCase(s, substr string) bool {
 	sLower := make([]byte, len(s))
@@ -469,66 +674,139 @@ func bytesContains(b, substr []byte) bool {
 	return false
 }
 
-// ReplaceAndPlay clears the queue and plays an item
-func (c *Client) ReplaceAndPlay(uri, service string) error {
+// itemPayload builds the JSON body Volumio expects for an item
+func itemPayload(uri, service string) map[string]string {
 	payload := map[string]string{"uri": uri}
 	if service != "" {
 		payload["service"] = service
 	}
+	return payload
+}
+
+// ReplaceAndPlay clears the queue and plays an item
+func (c *Client) ReplaceAndPlay(uri, service string) error {
+	_, err := c.post("/api/v1/replaceAndPlay", itemPayload(uri, service))
+	return err
+}
 
-	jsonData, err := json.Marshal(payload)
+// AddToQueue adds an item to the queue
+func (c *Client) AddToQueue(uri, service string) error {
+	_, err := c.post("/api/v1/addToQueue", itemPayload(uri, service))
+	return err
+}
+
+// QueueItem represents a track in the play queue
+type QueueItem struct {
+	URI     string `json:"uri"`
+	Title   string `json:"name"`
+	Artist  string `json:"artist"`
+	Album   string `json:"album"`
+	Service string `json:"service"`
+}
+
+// GetQueue retrieves the play queue
+func (c *Client) GetQueue() ([]QueueItem, error) {
+	body, err := c.get("/api/v1/getQueue", nil)
 	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %w", err)
+		return nil, err
+	}
+
+	var response struct {
+		Queue []QueueItem `json:"queue"`
 	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse queue: %w", err)
+	}
+
+	return response.Queue, nil
+}
+
+// MoveQueueItem moves the queue entry at position from to position to
+func (c *Client) MoveQueueItem(from, to int) error {
+	params := url.Values{}
+	params.Set("cmd", "moveQueue")
+	params.Set("from", fmt.Sprintf("%d", from))
+	params.Set("to", fmt.Sprintf("%d", to))
+	_, err := c.get("/api/v1/commands/", params)
+	return err
+}
 
-	req, err := http.NewRequest("POST", c.baseURL+"/api/v1/replaceAndPlay", bytes.NewReader(jsonData))
+// PlayNext queues an item to play after the current track. Volumio can
+// only append to the queue, so the new entries are moved into place.
+func (c *Client) PlayNext(uri, service string) error {
+	state, err := c.GetState()
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return err
+	}
+	before, err := c.GetQueue()
+	if err != nil {
+		return err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	if err := c.AddToQueue(uri, service); err != nil {
+		return err
+	}
 
-	resp, err := c.httpClient.Do(req)
+	after, err := c.GetQueue()
 	if err != nil {
-		return fmt.Errorf("request failed: %w", err)
+		return err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	// Move each added entry, in order, to just after the current track
+	next := state.Position + 1
+	if len(before) == 0 {
+		return nil
+	}
+	for from := len(before); from < len(after); from++ {
+		if from == next {
+			next++
+			continue
+		}
+		if err := c.MoveQueueItem(from, next); err != nil {
+			return fmt.Errorf("failed to move queue item: %w", err)
+		}
+		next++
 	}
 
 	return nil
 }
 
-// AddToQueue adds an item to the queue
-func (c *Client) AddToQueue(uri, service string) error {
-	payload := map[string]string{"uri": uri}
-	if service != "" {
-		payload["service"] = service
-	}
+// Playlists and favourites
 
-	jsonData, err := json.Marshal(payload)
+// ListPlaylists returns the names of the stored playlists
+func (c *Client) ListPlaylists() ([]string, error) {
+	body, err := c.get("/api/v1/listplaylists", nil)
 	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %w", err)
+		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", c.baseURL+"/api/v1/addToQueue", bytes.NewReader(jsonData))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+	var names []string
+	if err := json.Unmarshal(body, &names); err != nil {
+		return nil, fmt.Errorf("failed to parse playlists: %w", err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	return names, nil
+}
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("request failed: %w", err)
-	}
-	defer resp.Body.Close()
+// PlayPlaylist replaces the queue with the named playlist and starts playing
+func (c *Client) PlayPlaylist(name string) error {
+	params := url.Values{}
+	params.Set("cmd", "playplaylist")
+	params.Set("name", name)
+	_, err := c.get("/api/v1/commands/", params)
+	return err
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
-	}
+// AddToPlaylist appends an item to the named playlist
+func (c *Client) AddToPlaylist(name, uri, service string) error {
+	payload := itemPayload(uri, service)
+	payload["name"] = name
+	_, err := c.post("/api/v1/addToPlaylist", payload)
+	return err
+}
 
-	return nil
+// AddToFavourites adds an item to the favourites list
+func (c *Client) AddToFavourites(uri, service string) error {
+	_, err := c.post("/api/v1/addToFavourites", itemPayload(uri, service))
+	return err
 }
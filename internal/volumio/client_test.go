@@ -2,9 +2,13 @@ package volumio
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestGetState(t *testing.T) {
@@ -113,6 +117,58 @@ func TestSetVolume(t *testing.T) {
 	}
 }
 
+func TestPlayNext(t *testing.T) {
+	queue := []QueueItem{{URI: "a"}, {URI: "b"}, {URI: "c"}}
+	var moves []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/getState":
+			json.NewEncoder(w).Encode(PlayerState{Status: "play", Position: 0})
+		case "/api/v1/getQueue":
+			json.NewEncoder(w).Encode(map[string]interface{}{"queue": queue})
+		case "/api/v1/addToQueue":
+			// An album adds two tracks
+			queue = append(queue, QueueItem{URI: "x1"}, QueueItem{URI: "x2"})
+		case "/api/v1/commands/":
+			q := r.URL.Query()
+			moves = append(moves, q.Get("cmd")+" "+q.Get("from")+"->"+q.Get("to"))
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	if err := client.PlayNext("x", "mpd"); err != nil {
+		t.Fatalf("PlayNext() error = %v", err)
+	}
+
+	want := []string{"moveQueue 3->1", "moveQueue 4->2"}
+	if len(moves) != len(want) || moves[0] != want[0] || moves[1] != want[1] {
+		t.Errorf("moves = %v, want %v", moves, want)
+	}
+}
+
+func TestSearchWebRadio(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/search" || r.URL.Query().Get("query") != "fip" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		w.Write([]byte(`{"navigation":{"lists":[
+			{"title":"Albums","items":[{"uri":"albums://FIP/Live","title":"Live","type":"folder","service":"mpd"}]},
+			{"title":"Webradios","items":[{"uri":"https://icecast.radiofrance.fr/fip-hifi.aac","title":"FIP","type":"webradio","service":"webradio"}]}
+		]}}`))
+	}))
+	defer server.Close()
+
+	stations, err := NewClient(server.URL).SearchWebRadio("fip")
+	if err != nil {
+		t.Fatalf("SearchWebRadio() error = %v", err)
+	}
+	if len(stations) != 1 || stations[0].Title != "FIP" {
+		t.Errorf("SearchWebRadio() = %+v", stations)
+	}
+}
+
 func TestRealVolumioConnection(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping integration test in short mode")
@@ -132,3 +188,164 @@ func TestRealVolumioConnection(t *testing.T) {
 		t.Logf("Now playing: %s - %s", state.Artist, state.Title)
 	}
 }
+
+func TestSetVolumeLimits(t *testing.T) {
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.URL.Query().Get("volume"))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	client.SetVolumeLimits(5, 60)
+	for _, volume := range []int{80, 0, 30} {
+		if err := client.SetVolume(volume); err != nil {
+			t.Fatalf("SetVolume(%d) error = %v", volume, err)
+		}
+	}
+
+	want := []string{"60", "5", "30"}
+	for i := range want {
+		if i >= len(got) || got[i] != want[i] {
+			t.Fatalf("volumes = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestParseVolumeChange(t *testing.T) {
+	tests := []struct {
+		in       string
+		level    int
+		relative bool
+		wantErr  bool
+	}{
+		{in: "50", level: 50},
+		{in: "50%", level: 50},
+		{in: "+3", level: 3, relative: true},
+		{in: "-5", level: -5, relative: true},
+		{in: "101", wantErr: true},
+		{in: "loud", wantErr: true},
+	}
+	for _, tt := range tests {
+		level, relative, err := ParseVolumeChange(tt.in)
+		if (err != nil) != tt.wantErr || level != tt.level || relative != tt.relative {
+			t.Errorf("ParseVolumeChange(%q) = %d, %v, %v", tt.in, level, relative, err)
+		}
+	}
+}
+
+func TestSetObserver(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/getQueue" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{"status": "play"}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	var observed []string
+	client.SetObserver(func(endpoint string, d time.Duration, err error) {
+		observed = append(observed, fmt.Sprintf("%s:%v", endpoint, err != nil))
+	})
+
+	client.GetState()
+	client.Next()
+	client.GetQueue()
+	client.AddToQueue("mnt/a", "mpd")
+
+	want := "getState:false,commands/next:false,getQueue:true,addToQueue:false"
+	if got := strings.Join(observed, ","); got != want {
+		t.Errorf("observed %s, want %s", got, want)
+	}
+}
+
+func TestOptions(t *testing.T) {
+	var mu sync.Mutex
+	var userAgents, logged []string
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		userAgents = append(userAgents, r.Header.Get("User-Agent"))
+		mu.Unlock()
+		if r.URL.Path == "/api/v1/getQueue" {
+			<-release
+		}
+		w.Write([]byte(`{"status": "play", "volume": 50}`))
+	}))
+	defer server.Close()
+
+	client := New(
+		WithBaseURL(server.URL+"/"),
+		WithTimeout(50*time.Millisecond),
+		WithUserAgent("volu-test"),
+		WithVolumeLimits(10, 60),
+		WithLogger(func(format string, args ...interface{}) {
+			mu.Lock()
+			logged = append(logged, fmt.Sprintf(format, args...))
+			mu.Unlock()
+		}),
+	)
+
+	if _, err := client.GetState(); err != nil {
+		t.Fatalf("GetState() error = %v", err)
+	}
+	if _, err := client.GetQueue(); err == nil {
+		t.Error("GetQueue() should time out")
+	}
+	close(release)
+	client.AddToQueue("mnt/a", "mpd")
+
+	// Close waits for the timed-out getQueue handler to finish
+	server.Close()
+	mu.Lock()
+	defer mu.Unlock()
+	if got := strings.Join(userAgents, ","); got != "volu-test,volu-test,volu-test" {
+		t.Errorf("User-Agent = %s", got)
+	}
+	if len(logged) != 3 || !strings.HasPrefix(logged[0], "volumio getState took ") || !strings.HasPrefix(logged[1], "volumio getQueue failed after ") {
+		t.Errorf("logged %q", logged)
+	}
+	if client.maxVolume != 60 || client.minVolume != 10 {
+		t.Errorf("volume limits = %d–%d, want 10–60", client.minVolume, client.maxVolume)
+	}
+
+	if got := New().GetAlbumArtURL("/albumart"); got != DefaultBaseURL+"/albumart" {
+		t.Errorf("default album art URL = %s", got)
+	}
+}
+
+func TestTrackKey(t *testing.T) {
+	if key := TrackKey(nil); key != "" {
+		t.Errorf("TrackKey(nil) = %q, want empty", key)
+	}
+	if key := TrackKey(&PlayerState{Status: "stop"}); key != "" {
+		t.Errorf("TrackKey() with nothing loaded = %q, want empty", key)
+	}
+
+	// Web radio keeps its URI while the stream title changes
+	first := &PlayerState{URI: "http://stream.example.com/fip", Title: "Song A", Artist: "Artist"}
+	second := &PlayerState{URI: "http://stream.example.com/fip", Title: "Song B", Artist: "Artist"}
+	if TrackKey(first) == TrackKey(second) {
+		t.Error("Expected a new stream title to change the key")
+	}
+	if TrackKey(first) != TrackKey(&PlayerState{URI: first.URI, Title: first.Title, Artist: first.Artist, Seek: 1000}) {
+		t.Error("Expected the key to ignore playback position")
+	}
+}
+
+func TestHostFileName(t *testing.T) {
+	tests := map[string]string{
+		"volumio.local":       "volumio.local",
+		"192.168.1.20:3000":   "192.168.1.20_3000",
+		"[fe80::1%eth0]:3000": "_fe80__1_eth0__3000",
+		"../etc/passwd":       ".._etc_passwd",
+		`pi\share`:            "pi_share",
+	}
+	for host, want := range tests {
+		if got := HostFileName(host); got != want {
+			t.Errorf("HostFileName(%q) = %q, want %q", host, got, want)
+		}
+	}
+}
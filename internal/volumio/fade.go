@@ -0,0 +1,201 @@
+package volumio
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Curve shapes a volume fade
+type Curve string
+
+// Fade curves
+const (
+	CurveLinear Curve = "linear" // equal steps in volume percentage
+	CurveLog    Curve = "log"    // equal steps in loudness (dB), gentler at low volume
+)
+
+// ParseCurve parses a curve name; an empty name is linear
+func ParseCurve(name string) (Curve, error) {
+	switch name {
+	case "", "linear":
+		return CurveLinear, nil
+	case "log", "logarithmic":
+		return CurveLog, nil
+	}
+	return "", fmt.Errorf("unknown fade curve %q (use linear or log)", name)
+}
+
+// At returns the volume a fade from from to to has reached at progress p,
+// between 0 and 1
+func (c Curve) At(from, to int, p float64) int {
+	if c == CurveLog {
+		// Interpolate in dB; silence is treated as volume 1 so it has a level
+		a, b := math.Max(float64(from), 1), math.Max(float64(to), 1)
+		return int(math.Round(a * math.Pow(b/a, p)))
+	}
+	return from + int(math.Round(float64(to-from)*p))
+}
+
+// fadeStep is the shortest interval between volume changes while fading
+const fadeStep = 250 * time.Millisecond
+
+// Fade moves the volume from from to to over d, calling set for every
+// change. It returns early with ctx's error if ctx is cancelled, leaving
+// the volume where the fade had got to.
+func Fade(ctx context.Context, set func(volume int) error, from, to int, d time.Duration, curve Curve) error {
+	if from == to {
+		return nil
+	}
+	if d <= 0 {
+		return set(to)
+	}
+
+	steps := to - from
+	if steps < 0 {
+		steps = -steps
+	}
+	interval := d / time.Duration(steps)
+	if interval < fadeStep {
+		interval = fadeStep
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	begin := time.Now()
+	last := from
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		p := float64(time.Since(begin)) / float64(d)
+		volume := to
+		if p < 1 {
+			volume = curve.At(from, to, p)
+		}
+		if volume != last {
+			if err := set(volume); err != nil {
+				return fmt.Errorf("failed to set volume: %w", err)
+			}
+			last = volume
+		}
+		if p >= 1 {
+			return nil
+		}
+	}
+}
+
+// FadeTo fades from the current volume to target over d, within the
+// client's volume limits
+func (c *Client) FadeTo(ctx context.Context, target int, d time.Duration, curve Curve) error {
+	state, err := c.GetState()
+	if err != nil {
+		return fmt.Errorf("failed to get volume: %w", err)
+	}
+	return Fade(ctx, c.SetVolume, state.Volume, clampVolume(target, c.minVolume, c.maxVolume), d, curve)
+}
+
+// FadeOut fades to silence over d, runs action (e.g. Pause) and restores
+// the volume so the next play isn't silent. The fade goes below the
+// client's minimum volume. If nothing is playing, or d is zero, action
+// runs straight away. The volume is restored too if ctx is cancelled
+// mid-fade
+func (c *Client) FadeOut(ctx context.Context, d time.Duration, curve Curve, action func() error) error {
+	if d <= 0 {
+		return action()
+	}
+	state, err := c.GetState()
+	if err != nil {
+		return err
+	}
+	if state.Status != "play" {
+		return action()
+	}
+
+	restore := c.beginFade(state.Volume, d)
+	defer c.endFade()
+	if err := Fade(ctx, c.silenceVolume, state.Volume, 0, d, curve); err != nil {
+		c.SetVolume(restore)
+		return err
+	}
+	err = action()
+	if restoreErr := c.SetVolume(restore); err == nil && restoreErr != nil {
+		err = fmt.Errorf("failed to restore volume: %w", restoreErr)
+	}
+	return err
+}
+
+// FadeIn runs action (e.g. Play) silently and fades back up to the
+// current volume over d. If something is already playing, or d is zero,
+// action runs straight away. If ctx is cancelled mid-fade, the volume
+// jumps to where the fade was heading
+func (c *Client) FadeIn(ctx context.Context, d time.Duration, curve Curve, action func() error) error {
+	if d <= 0 {
+		return action()
+	}
+	state, err := c.GetState()
+	if err != nil {
+		return err
+	}
+	if state.Status == "play" {
+		return action()
+	}
+
+	restore := c.beginFade(state.Volume, d)
+	defer c.endFade()
+	if err := c.silenceVolume(0); err != nil {
+		return err
+	}
+	if err := action(); err != nil {
+		c.SetVolume(restore)
+		return err
+	}
+	if err := Fade(ctx, c.silenceVolume, 0, restore, d, curve); err != nil {
+		c.SetVolume(restore)
+		return err
+	}
+	return nil
+}
+
+// beginFade returns the volume to restore after a fade from volume over
+// d: volume itself, or the volume an unfinished fade started from
+func (c *Client) beginFade(volume int, d time.Duration) int {
+	if c.fadeMemory == nil {
+		return volume
+	}
+	return c.fadeMemory.Begin(time.Now(), volume, d)
+}
+
+// endFade forgets the volume kept by beginFade
+func (c *Client) endFade() {
+	if c.fadeMemory != nil {
+		c.fadeMemory.End()
+	}
+}
+
+// TransportFade is how play, pause, stop and toggle fade; zero durations
+// switch instantly
+type TransportFade struct {
+	Out   time.Duration // fade out before pausing or stopping
+	In    time.Duration // fade in when playback starts
+	Curve Curve
+}
+
+// Toggle pauses or resumes playback, fading as set in f
+func (c *Client) Toggle(ctx context.Context, f TransportFade) error {
+	if f.Out <= 0 && f.In <= 0 {
+		return c.TogglePlayPause()
+	}
+	state, err := c.GetState()
+	if err != nil {
+		return err
+	}
+	if state.Status == "play" {
+		return c.FadeOut(ctx, f.Out, f.Curve, c.Pause)
+	}
+	return c.FadeIn(ctx, f.In, f.Curve, c.Play)
+}
@@ -0,0 +1,173 @@
+package volumio
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCurveAt(t *testing.T) {
+	tests := []struct {
+		curve    Curve
+		from, to int
+		p        float64
+		want     int
+	}{
+		{CurveLinear, 0, 20, 0.5, 10},
+		{CurveLinear, 40, 0, 0.25, 30},
+		{CurveLog, 1, 100, 0.5, 10},
+		{CurveLog, 0, 100, 0.5, 10}, // silence counts as 1
+		{CurveLog, 100, 1, 0.5, 10},
+		{CurveLog, 20, 20, 0.5, 20},
+	}
+	for _, tt := range tests {
+		if got := tt.curve.At(tt.from, tt.to, tt.p); got != tt.want {
+			t.Errorf("%s.At(%d, %d, %v) = %d, want %d", tt.curve, tt.from, tt.to, tt.p, got, tt.want)
+		}
+	}
+
+	if _, err := ParseCurve("exponential"); err == nil {
+		t.Error("expected error for unknown curve")
+	}
+}
+
+func TestFade(t *testing.T) {
+	for _, curve := range []Curve{CurveLinear, CurveLog} {
+		var volumes []int
+		set := func(v int) error {
+			volumes = append(volumes, v)
+			return nil
+		}
+		if err := Fade(context.Background(), set, 4, 0, 600*time.Millisecond, curve); err != nil {
+			t.Fatalf("Fade(%s) error = %v", curve, err)
+		}
+		if len(volumes) == 0 || volumes[len(volumes)-1] != 0 {
+			t.Fatalf("Fade(%s) volumes = %v, want to end at 0", curve, volumes)
+		}
+		for i := 1; i < len(volumes); i++ {
+			if volumes[i] >= volumes[i-1] {
+				t.Errorf("Fade(%s) not decreasing: %v", curve, volumes)
+			}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := Fade(ctx, func(int) error { return nil }, 0, 50, time.Second, CurveLinear); err != context.Canceled {
+		t.Errorf("Fade() with cancelled context error = %v", err)
+	}
+}
+
+// fadeServer fakes the state, volume and transport endpoints.
+func fadeServer(t *testing.T, status string, volume int) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	var commands []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/api/v1/getState":
+			json.NewEncoder(w).Encode(PlayerState{Status: status, Volume: volume})
+		case "/api/v1/commands/":
+			q := r.URL.Query()
+			switch q.Get("cmd") {
+			case "volume":
+				volume, _ = strconv.Atoi(q.Get("volume"))
+				commands = append(commands, q.Get("volume"))
+			case "play":
+				status = "play"
+				commands = append(commands, "play")
+			case "pause":
+				status = "pause"
+				commands = append(commands, "pause")
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return commands
+	}
+}
+
+func TestFadeOutPausesAndRestores(t *testing.T) {
+	server, commands := fadeServer(t, "play", 3)
+	client := NewClient(server.URL)
+
+	if err := client.FadeOut(context.Background(), 500*time.Millisecond, CurveLinear, client.Pause); err != nil {
+		t.Fatalf("FadeOut() error = %v", err)
+	}
+	got := commands()
+	if len(got) < 3 || got[len(got)-3] != "0" || got[len(got)-2] != "pause" || got[len(got)-1] != "3" {
+		t.Errorf("commands = %v, want fade to 0, pause, restore 3", got)
+	}
+}
+
+func TestFadeInStartsSilent(t *testing.T) {
+	server, commands := fadeServer(t, "pause", 3)
+	client := NewClient(server.URL)
+
+	if err := client.Toggle(context.Background(), TransportFade{In: 500 * time.Millisecond}); err != nil {
+		t.Fatalf("Toggle() error = %v", err)
+	}
+	got := commands()
+	if len(got) < 3 || got[0] != "0" || got[1] != "play" || got[len(got)-1] != "3" {
+		t.Errorf("commands = %v, want 0, play, fade up to 3", got)
+	}
+}
+
+func TestFadeOutMidFadeRestoresStartVolume(t *testing.T) {
+	// Another volu is fading in towards 30 and has got to 8
+	memory := NewFadeMemory(filepath.Join(t.TempDir(), "fade.json"))
+	memory.Begin(time.Now(), 30, 10*time.Second)
+	server, commands := fadeServer(t, "play", 8)
+	client := NewClient(server.URL, WithFadeMemory(memory))
+
+	if err := client.FadeOut(context.Background(), 300*time.Millisecond, CurveLinear, client.Pause); err != nil {
+		t.Fatalf("FadeOut() error = %v", err)
+	}
+	got := commands()
+	if len(got) == 0 || got[len(got)-1] != "30" {
+		t.Errorf("commands = %v, want the volume restored to 30", got)
+	}
+	if _, err := os.Stat(memory.Path); !os.IsNotExist(err) {
+		t.Errorf("Expected the fade memory to be cleared, got %v", err)
+	}
+}
+
+func TestFadeOutCancelledRestores(t *testing.T) {
+	server, commands := fadeServer(t, "play", 40)
+	client := NewClient(server.URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 600*time.Millisecond)
+	defer cancel()
+	if err := client.FadeOut(ctx, 10*time.Second, CurveLinear, client.Pause); err == nil {
+		t.Fatal("FadeOut() with an interrupted fade returned no error")
+	}
+	got := commands()
+	if len(got) == 0 || got[len(got)-1] != "40" {
+		t.Errorf("commands = %v, want the volume restored to 40", got)
+	}
+}
+
+func TestFadeMemoryExpires(t *testing.T) {
+	memory := NewFadeMemory(filepath.Join(t.TempDir(), "fade.json"))
+	now := time.Now()
+	if got := memory.Begin(now, 30, time.Second); got != 30 {
+		t.Errorf("Begin() = %d, want 30", got)
+	}
+	if got := memory.Begin(now.Add(time.Second), 8, time.Second); got != 30 {
+		t.Errorf("Begin() during a fade = %d, want 30", got)
+	}
+	if got := memory.Begin(now.Add(time.Minute), 12, time.Second); got != 12 {
+		t.Errorf("Begin() after the fade expired = %d, want 12", got)
+	}
+}
@@ -0,0 +1,96 @@
+package volumio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/riclib/volu/internal/atomicfile"
+)
+
+// fadeGrace is how long after its planned end a fade is still taken to be
+// in progress, covering a slow player and the action after a fade out
+const fadeGrace = 10 * time.Second
+
+// FadeMemory remembers the volume a fade started from while it runs, in a
+// small file shared by separate volu invocations. A transport command
+// issued mid-fade, e.g. toggle during a fade in, then restores the user's
+// volume rather than the partly faded one
+type FadeMemory struct {
+	Path string
+}
+
+// fadeMemoryState is what the fade memory file holds
+type fadeMemoryState struct {
+	Volume int       `json:"volume"`
+	Until  time.Time `json:"until"`
+}
+
+// NewFadeMemory creates a fade memory keeping its state in path
+func NewFadeMemory(path string) *FadeMemory {
+	return &FadeMemory{Path: path}
+}
+
+// DefaultFadeMemory returns the fade memory for host in $XDG_RUNTIME_DIR,
+// as it only matters while fades run
+func DefaultFadeMemory(host string) *FadeMemory {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	return NewFadeMemory(filepath.Join(dir, "volu-fade-"+HostFileName(host)+".json"))
+}
+
+// Begin records that a fade from volume lasting d starts at now. If
+// another fade is still in progress, the volume it started from is kept
+// and returned instead, as volume is then only partly faded
+func (m *FadeMemory) Begin(now time.Time, volume int, d time.Duration) int {
+	s, ok := m.load(now)
+	if ok {
+		volume = s.Volume
+	}
+	until := now.Add(d + fadeGrace)
+	if until.Before(s.Until) {
+		until = s.Until
+	}
+	m.save(fadeMemoryState{Volume: volume, Until: until})
+	return volume
+}
+
+// End forgets the fade once its volume has been restored
+func (m *FadeMemory) End() error {
+	if err := os.Remove(m.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to clear fade memory: %w", err)
+	}
+	return nil
+}
+
+// load reads a fade still in progress at now; a missing, broken or
+// expired file is none
+func (m *FadeMemory) load(now time.Time) (fadeMemoryState, bool) {
+	var s fadeMemoryState
+	data, err := os.ReadFile(m.Path)
+	if err != nil || json.Unmarshal(data, &s) != nil || !now.Before(s.Until) {
+		return fadeMemoryState{}, false
+	}
+	return s, true
+}
+
+// save writes the fade memory through a temporary file, so other
+// processes never read half of it
+func (m *FadeMemory) save(s fadeMemoryState) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to encode fade memory: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(m.Path), 0755); err != nil {
+		return fmt.Errorf("failed to create runtime directory: %w", err)
+	}
+	if err := atomicfile.WriteFile(m.Path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write fade memory: %w", err)
+	}
+	return nil
+}
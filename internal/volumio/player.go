@@ -0,0 +1,63 @@
+package volumio
+
+import (
+	"context"
+	"time"
+)
+
+// Player is everything volu does with a Volumio player. *Client
+// implements it against the REST API; consumers accept a Player so tests
+// can substitute their own
+type Player interface {
+	// State
+	GetState() (*PlayerState, error)
+	GetAlbumArtURL(albumart string) string
+
+	// Transport
+	Play() error
+	Pause() error
+	TogglePlayPause() error
+	Stop() error
+	Next() error
+	Previous() error
+	Toggle(ctx context.Context, f TransportFade) error
+
+	// Volume
+	SetVolume(volume int) error
+	VolumeUp(step int) error
+	VolumeDown(step int) error
+	ChangeVolume(level int, relative bool) error
+	Mute() error
+	Unmute() error
+	ToggleMute() error
+	FadeTo(ctx context.Context, target int, d time.Duration, curve Curve) error
+	FadeOut(ctx context.Context, d time.Duration, curve Curve, action func() error) error
+	FadeIn(ctx context.Context, d time.Duration, curve Curve, action func() error) error
+
+	// Playback modes
+	ToggleRandom() error
+	ToggleRepeat() error
+
+	// Browse and search
+	Browse(uri string) ([]BrowseItem, error)
+	BrowseResult(uri string) (*BrowseResult, error)
+	Search(query string) (*SearchResponse, error)
+	SearchAlbums(query string) ([]BrowseItem, error)
+	SearchWebRadio(query string) ([]BrowseItem, error)
+
+	// Queue
+	GetQueue() ([]QueueItem, error)
+	ClearQueue() error
+	ReplaceAndPlay(uri, service string) error
+	AddToQueue(uri, service string) error
+	PlayNext(uri, service string) error
+	MoveQueueItem(from, to int) error
+
+	// Playlists and favourites
+	ListPlaylists() ([]string, error)
+	PlayPlaylist(name string) error
+	AddToPlaylist(name, uri, service string) error
+	AddToFavourites(uri, service string) error
+}
+
+var _ Player = (*Client)(nil)
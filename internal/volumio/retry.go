@@ -0,0 +1,150 @@
+package volumio
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Retry defaults
+const (
+	DefaultRetries        = 2                      // extra attempts after a failed request
+	DefaultRetryBackoff   = 200 * time.Millisecond // wait before the first retry, doubling after
+	DefaultConnectTimeout = 2 * time.Second        // connecting, including the name lookup
+	MaxRetries            = 5                      // most retries WithRetries allows
+	MaxRetryBackoff       = 5 * time.Second        // longest wait between two attempts
+)
+
+// StatusError is returned when the player answers with a status other
+// than 200 OK
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.StatusCode)
+}
+
+// relativeCommands change the player relative to its current state, so
+// repeating one that reached the player would repeat its effect
+var relativeCommands = map[string]bool{
+	"next":      true,
+	"prev":      true,
+	"toggle":    true,
+	"random":    true,
+	"repeat":    true,
+	"moveQueue": true,
+}
+
+// idempotentGet reports whether a GET request can be repeated safely
+func idempotentGet(endpoint string, params url.Values) bool {
+	if strings.Trim(strings.TrimPrefix(endpoint, "/api/v1/"), "/") != "commands" {
+		return true
+	}
+	cmd := params.Get("cmd")
+	if cmd == "volume" {
+		_, err := strconv.Atoi(params.Get("volume"))
+		return err == nil
+	}
+	return !relativeCommands[cmd]
+}
+
+// idempotentPost reports whether a POST request can be repeated safely;
+// everything but replaceAndPlay adds to a list
+func idempotentPost(endpoint string) bool {
+	return endpoint == "/api/v1/replaceAndPlay"
+}
+
+// send makes a request through the circuit breaker, retrying failures
+// that are safe to retry with jittered exponential backoff
+func (c *Client) send(idempotent bool, request func() ([]byte, error)) ([]byte, error) {
+	if c.breaker != nil {
+		if err := c.breaker.Allow(time.Now()); err != nil {
+			return nil, err
+		}
+	}
+
+	var body []byte
+	var err error
+	for attempt := 0; ; attempt++ {
+		body, err = request()
+		if err == nil || attempt >= c.retries || !retryable(err, idempotent) {
+			break
+		}
+		time.Sleep(backoff(c.retryBackoff, attempt))
+	}
+
+	if c.breaker != nil {
+		c.breaker.Record(time.Now(), unreachable(err))
+	}
+	return body, err
+}
+
+// retryable reports whether a failed request should be tried again. A
+// request that never reached the player can always be retried; one that
+// may have is only retried when repeating it is harmless. Timeouts are
+// not retried, so a player that hangs doesn't make callers wait longer
+func retryable(err error, idempotent bool) bool {
+	if notSent(err) {
+		return true
+	}
+	if !idempotent {
+		return false
+	}
+
+	var status *StatusError
+	if errors.As(err, &status) {
+		switch status.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) && urlErr.Timeout() {
+		return false
+	}
+	return true
+}
+
+// notSent reports whether a request failed before it was sent, while
+// looking up or connecting to the player
+func notSent(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// unreachable reports whether a request failed to reach the player, as
+// opposed to the player answering with an error
+func unreachable(err error) bool {
+	var status *StatusError
+	return err != nil && !errors.As(err, &status)
+}
+
+// backoff returns how long to wait before retry attempt+1: base doubled
+// per attempt, with up to half of it added or taken away at random, and
+// never more than MaxRetryBackoff
+func backoff(base time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	d := base
+	for i := 0; i < attempt && d < MaxRetryBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, MaxRetryBackoff)
+	return min(d/2+time.Duration(rand.Int63n(int64(d))), MaxRetryBackoff)
+}
+
+// dialer returns the default transport with a connect timeout
+func dialer(connectTimeout time.Duration) http.RoundTripper {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}).DialContext
+	return transport
+}
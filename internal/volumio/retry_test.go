@@ -0,0 +1,167 @@
+package volumio
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetries(t *testing.T) {
+	attempts := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Path + r.URL.Query().Get("cmd")
+		attempts[name]++
+		if attempts[name] < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"status": "play"}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, WithRetries(2, time.Millisecond))
+	if _, err := client.GetState(); err != nil {
+		t.Errorf("GetState() error = %v, want success on the third attempt", err)
+	}
+	if err := client.Next(); err == nil {
+		t.Error("Next() should not be retried after reaching the player")
+	}
+	if err := client.SetVolume(40); err != nil {
+		t.Errorf("SetVolume() error = %v, want success on the third attempt", err)
+	}
+	if attempts["/api/v1/getState"] != 3 || attempts["/api/v1/commands/next"] != 1 || attempts["/api/v1/commands/volume"] != 3 {
+		t.Errorf("attempts = %v", attempts)
+	}
+
+	var status *StatusError
+	if _, err := NewClient(server.URL, WithRetries(0, 0)).GetQueue(); !errors.As(err, &status) || status.StatusCode != 503 {
+		t.Errorf("GetQueue() without retries error = %v, want status 503", err)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	dial := &url.Error{Op: "Get", URL: "http://volumio.local:3000", Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}}
+	reset := &url.Error{Op: "Get", URL: "http://volumio.local:3000", Err: &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}}
+	timeout := &url.Error{Op: "Get", URL: "http://volumio.local:3000", Err: context.DeadlineExceeded}
+
+	tests := []struct {
+		name       string
+		err        error
+		idempotent bool
+		want       bool
+	}{
+		{"dial error", dial, false, true},
+		{"reset, idempotent", reset, true, true},
+		{"reset, not idempotent", reset, false, false},
+		{"timeout", timeout, true, false},
+		{"503", &StatusError{StatusCode: 503}, true, true},
+		{"500", &StatusError{StatusCode: 500}, true, false},
+	}
+	for _, tt := range tests {
+		if got := retryable(tt.err, tt.idempotent); got != tt.want {
+			t.Errorf("%s: retryable() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	for _, cmd := range []string{"next", "prev", "toggle", "random", "moveQueue"} {
+		if idempotentGet("/api/v1/commands/", url.Values{"cmd": {cmd}}) {
+			t.Errorf("%s should not be idempotent", cmd)
+		}
+	}
+	if !idempotentGet("/api/v1/commands/", url.Values{"cmd": {"volume"}, "volume": {"40"}}) || idempotentGet("/api/v1/commands/", url.Values{"cmd": {"volume"}, "volume": {"plus"}}) {
+		t.Error("only absolute volume changes are idempotent")
+	}
+	if !idempotentGet("/api/v1/browse", url.Values{"uri": {"music-library"}}) || idempotentPost("/api/v1/addToQueue") {
+		t.Error("browse is idempotent, addToQueue is not")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for attempt := 0; attempt < 4; attempt++ {
+		base := 100 * time.Millisecond << attempt
+		for i := 0; i < 20; i++ {
+			if d := backoff(100*time.Millisecond, attempt); d < base/2 || d >= base*3/2 {
+				t.Fatalf("backoff(100ms, %d) = %s, want %s to %s", attempt, d, base/2, base*3/2)
+			}
+		}
+	}
+
+	// Large attempt numbers must neither overflow nor wait forever
+	for _, attempt := range []int{10, 36, 63, 100} {
+		if d := backoff(time.Second, attempt); d <= 0 || d > MaxRetryBackoff {
+			t.Errorf("backoff(1s, %d) = %s, want up to %s", attempt, d, MaxRetryBackoff)
+		}
+	}
+}
+
+func TestWithRetriesClamps(t *testing.T) {
+	if c := NewClient("http://volumio.local:3000", WithRetries(1000, time.Second)); c.retries != MaxRetries {
+		t.Errorf("retries = %d, want %d", c.retries, MaxRetries)
+	}
+	if c := NewClient("http://volumio.local:3000", WithRetries(-1, time.Second)); c.retries != 0 {
+		t.Errorf("retries = %d, want 0", c.retries)
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close() // nothing listens: connections are refused
+
+	breaker := NewBreaker(filepath.Join(t.TempDir(), "volu", "breaker.json"))
+	breaker.Threshold = 2
+	client := NewClient("http://"+addr, WithRetries(0, 0), WithBreaker(breaker))
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetState(); err == nil || errors.Is(err, ErrUnreachable) {
+			t.Fatalf("request %d error = %v, want a connection error", i, err)
+		}
+	}
+	_, err = client.GetState()
+	if !errors.Is(err, ErrUnreachable) || !strings.Contains(err.Error(), "2 requests failed in a row") {
+		t.Fatalf("GetState() with the circuit open error = %v", err)
+	}
+
+	// Another process sharing the file fails fast too
+	other := NewClient("http://"+addr, WithBreaker(NewBreaker(breaker.Path)))
+	if _, err := other.GetState(); !errors.Is(err, ErrUnreachable) {
+		t.Errorf("GetState() from another client error = %v, want ErrUnreachable", err)
+	}
+
+	// After the cooldown one request goes through; a failure reopens the
+	// circuit, a success closes it
+	later := time.Now().Add(DefaultBreakerCooldown + time.Second)
+	if err := breaker.Allow(later); err != nil {
+		t.Errorf("Allow() after the cooldown = %v", err)
+	}
+	breaker.Record(later, true)
+	if err := breaker.Allow(later.Add(time.Second)); !errors.Is(err, ErrUnreachable) {
+		t.Errorf("Allow() after a failed probe = %v, want ErrUnreachable", err)
+	}
+	breaker.Record(later, false)
+	if err := breaker.Allow(time.Now()); err != nil {
+		t.Errorf("Allow() after a success = %v", err)
+	}
+
+	// Answers with an error status mean the player is up
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	up := NewClient(server.URL, WithRetries(0, 0), WithBreaker(breaker))
+	for i := 0; i < 3; i++ {
+		if _, err := up.GetState(); errors.Is(err, ErrUnreachable) {
+			t.Fatalf("GetState() error = %v, a 500 should not open the circuit", err)
+		}
+	}
+}
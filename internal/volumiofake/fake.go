@@ -0,0 +1,573 @@
+package volumiofake
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/riclib/volu/internal/volumio"
+)
+
+// Events Volumio pushes to socket.io clients, recorded by the fake
+const (
+	EventPushState = "pushState"
+	EventPushQueue = "pushQueue"
+)
+
+// Event is a push the real Volumio would have sent to socket.io clients
+// after a change. volu only talks REST, so the fake records them for
+// tests instead of serving socket.io.
+type Event struct {
+	Name  string
+	State volumio.PlayerState
+	Queue []Track
+}
+
+// Request is an API call the fake received, e.g. "commands/next" or
+// "replaceAndPlay".
+type Request struct {
+	Endpoint string
+	Params   map[string]string // query parameters or JSON body fields
+}
+
+// Fake is a stateful stand-in for Volumio's REST API with a queue,
+// playback clock and library. It is safe for concurrent use.
+type Fake struct {
+	mu       sync.Mutex
+	lib      Library
+	queue    []Track
+	position int
+	status   string
+	seek     time.Duration
+	volume   int
+	mute     bool
+	random   bool
+	repeat   bool
+
+	favourites []Track
+	requests   []Request
+	events     []Event
+	failures   map[string]int
+}
+
+// New creates a fake Volumio serving lib, stopped with an empty queue at
+// volume 40.
+func New(lib Library) *Fake {
+	if lib.Playlists == nil {
+		lib.Playlists = map[string][]Track{}
+	}
+	return &Fake{lib: lib, status: "stop", volume: 40, failures: map[string]int{}}
+}
+
+// Fail makes requests to endpoint (e.g. "getState" or "commands/next")
+// fail with status until Fail is called again with 0.
+func (f *Fake) Fail(endpoint string, status int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if status == 0 {
+		delete(f.failures, endpoint)
+		return
+	}
+	f.failures[endpoint] = status
+}
+
+// State returns the player state as getState would.
+func (f *Fake) State() volumio.PlayerState {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.state()
+}
+
+// Queue returns the current queue.
+func (f *Fake) Queue() []Track {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Track(nil), f.queue...)
+}
+
+// Requests returns the API calls received so far.
+func (f *Fake) Requests() []Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Request(nil), f.requests...)
+}
+
+// Commands returns the names of the commands received so far, e.g.
+// ["replaceAndPlay", "next", "volume"].
+func (f *Fake) Commands() []string {
+	var names []string
+	for _, r := range f.Requests() {
+		names = append(names, strings.TrimPrefix(r.Endpoint, "commands/"))
+	}
+	return names
+}
+
+// Events returns the pushes recorded so far.
+func (f *Fake) Events() []Event {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Event(nil), f.events...)
+}
+
+// Load replaces the queue with tracks and sets the playback state, for
+// tests that start mid-playback.
+func (f *Fake) Load(tracks []Track, position int, status string, seek time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.queue = append([]Track(nil), tracks...)
+	f.position = position
+	f.status = status
+	f.seek = seek
+	f.pushQueue()
+	f.pushState()
+}
+
+// SetVolume sets the volume as if changed on the device.
+func (f *Fake) SetVolume(volume int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.volume = volume
+	f.pushState()
+}
+
+// Advance moves the playback clock on by d. Tracks that end move on to
+// the next one, and playback stops at the end of the queue unless repeat
+// is on.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.status != "play" || len(f.queue) == 0 {
+		return
+	}
+	f.seek += d
+	for {
+		length := time.Duration(f.queue[f.position].Duration) * time.Second
+		if length == 0 || f.seek < length {
+			return
+		}
+		f.seek -= length
+		playing := f.skip(1)
+		f.pushState()
+		if !playing {
+			return
+		}
+	}
+}
+
+// RunClock advances the playback clock in real time until ctx is done,
+// for running the fake interactively.
+func (f *Fake) RunClock(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			f.Advance(time.Second)
+		}
+	}
+}
+
+// ServeHTTP serves the Volumio REST API.
+func (f *Fake) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.URL.Path == "/albumart" {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(albumArt)
+		return
+	}
+
+	endpoint := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/"), "/")
+	params := map[string]string{}
+	for key := range r.URL.Query() {
+		params[key] = r.URL.Query().Get(key)
+	}
+	if r.Method == http.MethodPost {
+		json.NewDecoder(r.Body).Decode(&params)
+	}
+	if endpoint == "commands" {
+		endpoint += "/" + params["cmd"]
+	}
+	f.requests = append(f.requests, Request{Endpoint: endpoint, Params: params})
+
+	if status, ok := f.failures[endpoint]; ok {
+		w.WriteHeader(status)
+		return
+	}
+
+	var response interface{} = map[string]string{"response": "success"}
+	switch endpoint {
+	case "getState":
+		response = f.state()
+	case "getQueue":
+		response = map[string]interface{}{"queue": f.queueItems()}
+	case "browse":
+		response = f.browse(params["uri"])
+	case "search":
+		response = f.search(params["query"])
+	case "listplaylists":
+		names := []string{}
+		for name := range f.lib.Playlists {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		response = names
+	case "replaceAndPlay":
+		f.replace(f.resolve(params["uri"], params["service"]))
+	case "addToQueue":
+		f.queue = append(f.queue, f.resolve(params["uri"], params["service"])...)
+		f.pushQueue()
+	case "addToPlaylist":
+		f.lib.Playlists[params["name"]] = append(f.lib.Playlists[params["name"]], f.resolve(params["uri"], params["service"])...)
+	case "addToFavourites":
+		f.favourites = append(f.favourites, f.resolve(params["uri"], params["service"])...)
+	default:
+		cmd, ok := strings.CutPrefix(endpoint, "commands/")
+		if !ok || !f.command(cmd, params) {
+			http.Error(w, `{"error": "unknown endpoint"}`, http.StatusNotFound)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// command runs a /api/v1/commands/ command, reporting whether it is known.
+func (f *Fake) command(cmd string, params map[string]string) bool {
+	switch cmd {
+	case "play":
+		if len(f.queue) > 0 {
+			f.status = "play"
+		}
+	case "pause":
+		if f.status == "play" {
+			f.status = "pause"
+		}
+	case "toggle":
+		if f.status == "play" {
+			f.status = "pause"
+		} else if len(f.queue) > 0 {
+			f.status = "play"
+		}
+	case "stop":
+		f.status = "stop"
+		f.seek = 0
+	case "next":
+		f.seek = 0
+		f.skip(1)
+	case "prev":
+		if f.seek > 3*time.Second {
+			f.seek = 0
+		} else {
+			f.skip(-1)
+		}
+	case "volume":
+		volume, err := strconv.Atoi(params["volume"])
+		if err != nil || volume < 0 || volume > 100 {
+			return false
+		}
+		f.volume = volume
+	case "mute":
+		f.mute = true
+	case "unmute":
+		f.mute = false
+	case "random":
+		f.random = !f.random
+	case "repeat":
+		f.repeat = !f.repeat
+	case "clearQueue":
+		f.queue = nil
+		f.position = 0
+		f.status = "stop"
+		f.seek = 0
+		f.pushQueue()
+	case "moveQueue":
+		from, errFrom := strconv.Atoi(params["from"])
+		to, errTo := strconv.Atoi(params["to"])
+		if errFrom != nil || errTo != nil || from < 0 || to < 0 || from >= len(f.queue) || to >= len(f.queue) {
+			return false
+		}
+		f.move(from, to)
+		f.pushQueue()
+	case "playplaylist":
+		tracks, ok := f.lib.Playlists[params["name"]]
+		if !ok {
+			return false
+		}
+		f.replace(tracks)
+	default:
+		return false
+	}
+	f.pushState()
+	return true
+}
+
+// skip moves by step tracks, returning false if playback stopped at the
+// end of the queue.
+func (f *Fake) skip(step int) bool {
+	if len(f.queue) == 0 {
+		return false
+	}
+	next := f.position + step
+	switch {
+	case next >= len(f.queue) && f.repeat:
+		next = 0
+	case next >= len(f.queue):
+		f.status = "stop"
+		f.seek = 0
+		return false
+	case next < 0:
+		next = 0
+	}
+	f.position = next
+	return true
+}
+
+// move moves a queue item, keeping the current track current.
+func (f *Fake) move(from, to int) {
+	item := f.queue[from]
+	rest := append(append([]Track(nil), f.queue[:from]...), f.queue[from+1:]...)
+	f.queue = append(append(append([]Track(nil), rest[:to]...), item), rest[to:]...)
+
+	switch {
+	case f.position == from:
+		f.position = to
+	case from < f.position && to >= f.position:
+		f.position--
+	case from > f.position && to <= f.position:
+		f.position++
+	}
+}
+
+// replace replaces the queue with tracks and plays the first.
+func (f *Fake) replace(tracks []Track) {
+	f.queue = append([]Track(nil), tracks...)
+	f.position = 0
+	f.seek = 0
+	f.status = "stop"
+	if len(f.queue) > 0 {
+		f.status = "play"
+	}
+	f.pushQueue()
+	f.pushState()
+}
+
+// resolve returns the tracks an album, track, station or playlist URI
+// stands for. Unknown URIs play as a single track titled by the URI.
+func (f *Fake) resolve(uri, service string) []Track {
+	for _, a := range f.lib.Albums {
+		if a.URI == uri {
+			return a.Tracks
+		}
+		for _, t := range a.Tracks {
+			if t.URI == uri {
+				return []Track{t}
+			}
+		}
+	}
+	for _, s := range f.lib.Stations {
+		if s.URI == uri {
+			return []Track{s}
+		}
+	}
+	if name, ok := strings.CutPrefix(uri, "playlists/"); ok {
+		return f.lib.Playlists[name]
+	}
+	if service == "" {
+		service = "mpd"
+	}
+	return []Track{{URI: uri, Title: uri, Service: service}}
+}
+
+func (f *Fake) state() volumio.PlayerState {
+	s := volumio.PlayerState{
+		Status:   f.status,
+		Position: f.position,
+		Seek:     int(f.seek.Milliseconds()),
+		Volume:   f.volume,
+		Mute:     f.mute,
+		Random:   f.random,
+		Repeat:   f.repeat,
+	}
+	if f.position < len(f.queue) {
+		t := f.queue[f.position]
+		s.Title, s.Artist, s.Album, s.AlbumArt = t.Title, t.Artist, t.Album, t.AlbumArt
+		s.URI, s.Service, s.Duration = t.URI, service(t), t.Duration
+	}
+	return s
+}
+
+func (f *Fake) pushState() {
+	f.events = append(f.events, Event{Name: EventPushState, State: f.state()})
+}
+
+func (f *Fake) pushQueue() {
+	f.events = append(f.events, Event{Name: EventPushQueue, Queue: append([]Track(nil), f.queue...)})
+}
+
+func (f *Fake) queueItems() []map[string]interface{} {
+	items := []map[string]interface{}{}
+	for _, t := range f.queue {
+		items = append(items, map[string]interface{}{
+			"uri": t.URI, "name": t.Title, "artist": t.Artist, "album": t.Album,
+			"albumart": t.AlbumArt, "service": service(t), "duration": t.Duration,
+		})
+	}
+	return items
+}
+
+func service(t Track) string {
+	if t.Service == "" {
+		return "mpd"
+	}
+	return t.Service
+}
+
+// Browsing and search
+
+func (f *Fake) browse(uri string) interface{} {
+	var prev string
+	var info *volumio.BrowseInfo
+	var items []volumio.BrowseItem
+
+	switch uri {
+	case "", "/":
+		items = []volumio.BrowseItem{
+			{Title: "Music Library", URI: "music-library", Type: "folder", Service: "mpd"},
+			{Title: "Web Radio", URI: "radio", Type: "folder", Service: "webradio"},
+			{Title: "Playlists", URI: "playlists", Type: "folder", Service: "mpd"},
+			{Title: "Favourites", URI: "favourites", Type: "folder", Service: "mpd"},
+		}
+	case "music-library":
+		prev = "/"
+		for _, a := range f.lib.Albums {
+			items = append(items, albumItem(a))
+		}
+	case "radio":
+		prev = "/"
+		for _, s := range f.lib.Stations {
+			items = append(items, trackItem(s))
+		}
+	case "playlists":
+		prev = "/"
+		names := make([]string, 0, len(f.lib.Playlists))
+		for name := range f.lib.Playlists {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			items = append(items, volumio.BrowseItem{Title: name, URI: "playlists/" + name, Type: "playlist", Service: "mpd"})
+		}
+	case "favourites":
+		prev = "/"
+		for _, t := range f.favourites {
+			items = append(items, trackItem(t))
+		}
+	default:
+		if name, ok := strings.CutPrefix(uri, "playlists/"); ok {
+			prev = "playlists"
+			for _, t := range f.lib.Playlists[name] {
+				items = append(items, trackItem(t))
+			}
+			break
+		}
+		for _, a := range f.lib.Albums {
+			if a.URI == uri {
+				prev = "music-library"
+				info = &volumio.BrowseInfo{URI: a.URI, Service: "mpd", Type: "album", Title: a.Title, Artist: a.Artist, Album: a.Title, AlbumArt: a.AlbumArt}
+				for _, t := range a.Tracks {
+					items = append(items, trackItem(t))
+				}
+			}
+		}
+	}
+
+	navigation := map[string]interface{}{
+		"lists": []volumio.BrowseList{{AvailableListViews: []string{"list"}, Items: nonNil(items)}},
+	}
+	if prev != "" {
+		navigation["prev"] = map[string]string{"uri": prev}
+	}
+	if info != nil {
+		navigation["info"] = info
+	}
+	return map[string]interface{}{"navigation": navigation}
+}
+
+func (f *Fake) search(query string) interface{} {
+	q := strings.ToLower(query)
+	matches := func(fields ...string) bool {
+		for _, field := range fields {
+			if strings.Contains(strings.ToLower(field), q) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var albums, tracks, stations []volumio.BrowseItem
+	for _, a := range f.lib.Albums {
+		if matches(a.Title, a.Artist) {
+			albums = append(albums, albumItem(a))
+		}
+		for _, t := range a.Tracks {
+			if matches(t.Title, t.Artist) {
+				tracks = append(tracks, trackItem(t))
+			}
+		}
+	}
+	for _, s := range f.lib.Stations {
+		if matches(s.Title) {
+			stations = append(stations, trackItem(s))
+		}
+	}
+
+	lists := []volumio.SearchList{}
+	for _, list := range []volumio.SearchList{
+		{Title: "Music Library Albums", Items: albums},
+		{Title: "Music Library Tracks", Items: tracks},
+		{Title: "Webradio", Items: stations},
+	} {
+		if len(list.Items) > 0 {
+			lists = append(lists, list)
+		}
+	}
+	return map[string]interface{}{"navigation": map[string]interface{}{"isSearchResult": true, "lists": lists}}
+}
+
+func albumItem(a Album) volumio.BrowseItem {
+	return volumio.BrowseItem{Title: a.Title, Artist: a.Artist, Album: a.Title, URI: a.URI, AlbumArt: a.AlbumArt, Type: "folder", Service: "mpd"}
+}
+
+func trackItem(t Track) volumio.BrowseItem {
+	kind := "song"
+	if service(t) == "webradio" {
+		kind = "webradio"
+	}
+	return volumio.BrowseItem{Title: t.Title, Artist: t.Artist, Album: t.Album, URI: t.URI, AlbumArt: t.AlbumArt, Type: kind, Service: service(t)}
+}
+
+func nonNil(items []volumio.BrowseItem) []volumio.BrowseItem {
+	if items == nil {
+		return []volumio.BrowseItem{}
+	}
+	return items
+}
+
+// albumArt is a 1x1 grey PNG served for every cover.
+var albumArt = []byte{
+	0x89, 0x50, 0x4e, 0x47, 0x0d, 0x0a, 0x1a, 0x0a, 0x00, 0x00, 0x00, 0x0d, 0x49, 0x48, 0x44, 0x52,
+	0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01, 0x08, 0x00, 0x00, 0x00, 0x00, 0x3a, 0x7e, 0x9b,
+	0x55, 0x00, 0x00, 0x00, 0x0a, 0x49, 0x44, 0x41, 0x54, 0x78, 0x9c, 0x63, 0x68, 0x00, 0x00, 0x00,
+	0x82, 0x00, 0x81, 0x4c, 0x17, 0xd7, 0xdf, 0x00, 0x00, 0x00, 0x00, 0x49, 0x45, 0x4e, 0x44, 0xae,
+	0x42, 0x60, 0x82,
+}
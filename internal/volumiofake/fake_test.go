@@ -0,0 +1,198 @@
+package volumiofake
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/riclib/volu/internal/volumio"
+)
+
+func TestPlayback(t *testing.T) {
+	server := newServer(DefaultLibrary())
+	defer server.Close()
+	client := volumio.NewClient(server.URL)
+
+	albums, err := client.SearchAlbums("asot")
+	if err != nil {
+		t.Fatalf("SearchAlbums() error = %v", err)
+	}
+	if len(albums) != 6 || albums[0].Title != "ASOT 1090" {
+		t.Fatalf("SearchAlbums() = %v, want the 6 ASOT episodes", albums)
+	}
+
+	if err := client.ReplaceAndPlay(albums[0].URI, albums[0].Service); err != nil {
+		t.Fatalf("ReplaceAndPlay() error = %v", err)
+	}
+	if err := client.AddToQueue(albums[1].URI, albums[1].Service); err != nil {
+		t.Fatalf("AddToQueue() error = %v", err)
+	}
+	state, err := client.GetState()
+	if err != nil {
+		t.Fatalf("GetState() error = %v", err)
+	}
+	if state.Status != "play" || state.Title != "ASOT 1090 - Part 1" || state.Duration != 1260 {
+		t.Errorf("state = %+v, want part 1 of ASOT 1090 playing", state)
+	}
+	if queue, _ := client.GetQueue(); len(queue) != 6 || queue[3].Album != "ASOT 1091" {
+		t.Errorf("queue = %v, want both episodes", queue)
+	}
+
+	client.Next()
+	server.Advance(1330 * time.Second) // past the end of part 2
+	state, _ = client.GetState()
+	if state.Position != 2 || state.Seek != 10000 {
+		t.Errorf("after Advance() position = %d, seek = %d, want 2, 10000", state.Position, state.Seek)
+	}
+	client.Previous()
+	if state, _ = client.GetState(); state.Position != 2 || state.Seek != 0 {
+		t.Errorf("Previous() past 3s should restart the track, got position %d seek %d", state.Position, state.Seek)
+	}
+
+	client.SetVolume(25)
+	client.ToggleRandom()
+	client.Pause()
+	state, _ = client.GetState()
+	if state.Volume != 25 || !state.Random || state.Status != "pause" {
+		t.Errorf("state = %+v, want volume 25, random, paused", state)
+	}
+
+	if got, want := strings.Join(server.Commands(), ","), "search,replaceAndPlay,addToQueue,getState,getQueue,next,getState,prev,getState,volume,random,pause,getState"; got != want {
+		t.Errorf("Commands() = %s, want %s", got, want)
+	}
+}
+
+func TestQueueEnd(t *testing.T) {
+	f := New(DefaultLibrary())
+	lib := DefaultLibrary()
+	f.Load(lib.Albums[len(lib.Albums)-1].Tracks, 3, "play", 0)
+
+	f.Advance(time.Hour)
+	if s := f.State(); s.Status != "stop" || s.Position != 3 {
+		t.Errorf("state at queue end = %s at %d, want stop at 3", s.Status, s.Position)
+	}
+
+	f.Load(lib.Albums[len(lib.Albums)-1].Tracks, 3, "play", 0)
+	f.repeat = true
+	f.Advance(260 * time.Second)
+	if s := f.State(); s.Status != "play" || s.Position != 0 {
+		t.Errorf("state with repeat = %s at %d, want play at 0", s.Status, s.Position)
+	}
+
+	var names []string
+	for _, e := range f.Events() {
+		names = append(names, e.Name)
+	}
+	if got := strings.Join(names, ","); got != "pushQueue,pushState,pushState,pushQueue,pushState,pushState" {
+		t.Errorf("events = %s", got)
+	}
+}
+
+func TestBrowse(t *testing.T) {
+	server := newServer(DefaultLibrary())
+	defer server.Close()
+	client := volumio.NewClient(server.URL)
+
+	root, err := client.Browse("")
+	if err != nil || len(root) != 4 || root[0].URI != "music-library" {
+		t.Fatalf("Browse(\"\") = %v, %v", root, err)
+	}
+
+	result, err := client.BrowseResult("music-library/NAS/Bonobo/Migration")
+	if err != nil {
+		t.Fatalf("BrowseResult() error = %v", err)
+	}
+	if result.Prev != "music-library" || result.Info == nil || result.Info.Artist != "Bonobo" || len(result.Items()) != 4 {
+		t.Errorf("album page = %+v", result)
+	}
+
+	stations, err := client.SearchWebRadio("paradise")
+	if err != nil || len(stations) != 1 || stations[0].Service != "webradio" {
+		t.Errorf("SearchWebRadio() = %v, %v", stations, err)
+	}
+
+	if err := client.PlayPlaylist("Morning"); err != nil {
+		t.Fatalf("PlayPlaylist() error = %v", err)
+	}
+	if state, _ := client.GetState(); state.Title != "Migration" {
+		t.Errorf("playlist started with %q", state.Title)
+	}
+	client.AddToPlaylist("Evening", stations[0].URI, stations[0].Service)
+	if names, _ := client.ListPlaylists(); strings.Join(names, ",") != "Evening,Morning" {
+		t.Errorf("ListPlaylists() = %v", names)
+	}
+
+	if _, err := client.Browse("music-library/missing"); err != nil {
+		t.Errorf("Browse() of an unknown folder error = %v, want an empty list", err)
+	}
+}
+
+func TestPlayNext(t *testing.T) {
+	server := newServer(DefaultLibrary())
+	defer server.Close()
+	client := volumio.NewClient(server.URL)
+
+	client.ReplaceAndPlay("music-library/NAS/Bonobo/Migration", "mpd")
+	if err := client.PlayNext("music-library/NAS/Gouryella/Anahera", "mpd"); err != nil {
+		t.Fatalf("PlayNext() error = %v", err)
+	}
+	var titles []string
+	for _, track := range server.Queue() {
+		titles = append(titles, track.Title)
+	}
+	if got, want := strings.Join(titles, ","), "Migration,Anahera,Surga,Neba,Break Apart,Outlier,Kerala"; got != want {
+		t.Errorf("queue = %s, want %s", got, want)
+	}
+}
+
+func TestFail(t *testing.T) {
+	server := newServer(DefaultLibrary())
+	defer server.Close()
+	client := volumio.NewClient(server.URL)
+
+	server.Fail("commands/next", 500)
+	if err := client.Next(); err == nil {
+		t.Error("Next() should fail while commands/next is failing")
+	}
+	if _, err := client.GetState(); err != nil {
+		t.Errorf("GetState() error = %v, only next should fail", err)
+	}
+	server.Fail("commands/next", 0)
+	if err := client.Next(); err != nil {
+		t.Errorf("Next() error = %v after clearing the failure", err)
+	}
+}
+
+func TestLoadLibrary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "library.json")
+	os.WriteFile(path, []byte(`{"albums": [{"uri": "music-library/A", "title": "A", "tracks": [{"uri": "music-library/A/1.flac", "title": "One", "duration": 60}]}]}`), 0644)
+
+	lib, err := LoadLibrary(path)
+	if err != nil {
+		t.Fatalf("LoadLibrary() error = %v", err)
+	}
+	f := New(lib)
+	f.Load(lib.Albums[0].Tracks, 0, "play", 0)
+	if s := f.State(); s.Title != "One" || s.Service != "mpd" {
+		t.Errorf("state = %+v", s)
+	}
+
+	os.WriteFile(path, []byte(`{`), 0644)
+	if _, err := LoadLibrary(path); err == nil {
+		t.Error("LoadLibrary() of broken JSON should fail")
+	}
+}
+
+// server is a fake Volumio listening on a local port.
+type server struct {
+	*Fake
+	*httptest.Server
+}
+
+func newServer(lib Library) *server {
+	f := New(lib)
+	return &server{Fake: f, Server: httptest.NewServer(f)}
+}
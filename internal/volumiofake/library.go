@@ -0,0 +1,111 @@
+package volumiofake
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Track is a song, or a web radio station when Service is "webradio".
+type Track struct {
+	URI      string `json:"uri"`
+	Title    string `json:"title"`
+	Artist   string `json:"artist,omitempty"`
+	Album    string `json:"album,omitempty"`
+	AlbumArt string `json:"albumart,omitempty"`
+	Service  string `json:"service,omitempty"`  // default: mpd
+	Duration int    `json:"duration,omitempty"` // seconds; 0 for streams
+}
+
+// Album is a folder of tracks in the music library.
+type Album struct {
+	URI      string  `json:"uri"`
+	Title    string  `json:"title"`
+	Artist   string  `json:"artist,omitempty"`
+	AlbumArt string  `json:"albumart,omitempty"`
+	Tracks   []Track `json:"tracks"`
+}
+
+// Library is what the fake server can browse, search and play.
+type Library struct {
+	Albums    []Album            `json:"albums"`
+	Stations  []Track            `json:"stations,omitempty"`
+	Playlists map[string][]Track `json:"playlists,omitempty"`
+}
+
+// LoadLibrary reads a library fixture written as JSON.
+func LoadLibrary(path string) (Library, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Library{}, fmt.Errorf("failed to read library fixture: %w", err)
+	}
+	var lib Library
+	if err := json.Unmarshal(data, &lib); err != nil {
+		return Library{}, fmt.Errorf("failed to parse library fixture: %w", err)
+	}
+	return lib, nil
+}
+
+// DefaultLibrary returns a small library with episodes of the radio
+// series in config.example.yaml, a few regular albums, stations and a
+// playlist.
+func DefaultLibrary() Library {
+	lib := Library{
+		Stations: []Track{
+			{URI: "http://stream.radioparadise.com/mp3-192", Title: "Radio Paradise", Service: "webradio"},
+			{URI: "http://ice1.somafm.com/groovesalad-128-mp3", Title: "SomaFM Groove Salad", Service: "webradio"},
+		},
+		Playlists: map[string][]Track{},
+	}
+
+	for i := 1090; i < 1096; i++ {
+		lib.Albums = append(lib.Albums, episode("ASOT "+strconv.Itoa(i), "Armin van Buuren", 3))
+	}
+	for i := 550; i < 554; i++ {
+		lib.Albums = append(lib.Albums, episode("Group Therapy "+strconv.Itoa(i), "Above & Beyond", 3))
+	}
+	for i := 1; i < 4; i++ {
+		lib.Albums = append(lib.Albums, episode("Tritonia "+strconv.Itoa(i), "Tritonia", 2))
+	}
+	lib.Albums = append(lib.Albums,
+		album("Ferry Corsten", "Blueprint", "Blueprint", "Wherever You Are", "Trust", "Ready"),
+		album("Gouryella", "Anahera", "Anahera", "Surga", "Neba"),
+		album("Bonobo", "Migration", "Migration", "Break Apart", "Outlier", "Kerala"),
+	)
+
+	lib.Playlists["Morning"] = []Track{lib.Albums[len(lib.Albums)-1].Tracks[0], lib.Albums[len(lib.Albums)-2].Tracks[0]}
+	return lib
+}
+
+// episode is a radio show episode with numbered parts.
+func episode(title, artist string, parts int) Album {
+	a := Album{URI: "music-library/NAS/Radio/" + title, Title: title, Artist: artist, AlbumArt: "/albumart?path=" + title}
+	for i := 1; i <= parts; i++ {
+		a.Tracks = append(a.Tracks, Track{
+			URI:      a.URI + "/" + strconv.Itoa(i) + ".flac",
+			Title:    title + " - Part " + strconv.Itoa(i),
+			Artist:   artist,
+			Album:    title,
+			AlbumArt: a.AlbumArt,
+			Duration: 1200 + 60*i,
+		})
+	}
+	return a
+}
+
+// album is an album with the given track titles.
+func album(artist, title string, tracks ...string) Album {
+	a := Album{URI: "music-library/NAS/" + artist + "/" + title, Title: title, Artist: artist, AlbumArt: "/albumart?path=" + title}
+	for i, name := range tracks {
+		a.Tracks = append(a.Tracks, Track{
+			URI:      a.URI + "/" + strconv.Itoa(i+1) + " " + name + ".flac",
+			Title:    name,
+			Artist:   artist,
+			Album:    title,
+			AlbumArt: a.AlbumArt,
+			Duration: 200 + 17*i,
+		})
+	}
+	return a
+}
@@ -0,0 +1,19 @@
+package volumiotest
+
+import (
+	"testing"
+
+	"github.com/riclib/volu/internal/volumio"
+)
+
+// ReplayClient returns a Volumio client answered from the cassette at
+// path, failing the test if it can't be read. Requests missing from the
+// cassette fail straight away rather than being retried.
+func ReplayClient(t testing.TB, path string) *volumio.Client {
+	t.Helper()
+	c, err := volumio.LoadCassette(path)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	return volumio.NewClient("http://"+volumio.RedactedHost+":3000", volumio.WithTransport(volumio.NewReplayer(c)), volumio.WithRetries(0, 0))
+}
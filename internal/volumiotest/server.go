@@ -0,0 +1,20 @@
+package volumiotest
+
+import (
+	"net/http/httptest"
+
+	"github.com/riclib/volu/internal/volumiofake"
+)
+
+// Server is a fake Volumio listening on a local port.
+type Server struct {
+	*volumiofake.Fake
+	*httptest.Server
+}
+
+// NewServer starts a fake Volumio serving lib; call Close when done.
+// Point a client at it with volumio.NewClient(server.URL).
+func NewServer(lib volumiofake.Library) *Server {
+	f := volumiofake.New(lib)
+	return &Server{Fake: f, Server: httptest.NewServer(f)}
+}
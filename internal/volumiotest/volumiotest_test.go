@@ -0,0 +1,37 @@
+package volumiotest
+
+import (
+	"testing"
+
+	"github.com/riclib/volu/internal/volumio"
+	"github.com/riclib/volu/internal/volumiofake"
+)
+
+func TestNewServer(t *testing.T) {
+	lib := volumiofake.DefaultLibrary()
+	server := NewServer(lib)
+	defer server.Close()
+
+	client := volumio.NewClient(server.URL, volumio.WithRetries(0, 0))
+	album := lib.Albums[0]
+	if err := client.ReplaceAndPlay(album.URI, "mpd"); err != nil {
+		t.Fatalf("ReplaceAndPlay() error = %v", err)
+	}
+	state, err := client.GetState()
+	if err != nil {
+		t.Fatalf("GetState() error = %v", err)
+	}
+	if state.Status != "play" || state.Title != album.Tracks[0].Title {
+		t.Errorf("state = %s %q, want playing %q", state.Status, state.Title, album.Tracks[0].Title)
+	}
+	if got := server.Commands(); len(got) == 0 {
+		t.Error("fake recorded no commands")
+	}
+}
+
+func TestReplayClient(t *testing.T) {
+	client := ReplayClient(t, "../volumio/testdata/cassettes/live-status.json")
+	if _, err := client.GetState(); err != nil {
+		t.Errorf("GetState() from cassette error = %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package walker
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/riclib/volu/internal/launcher"
+)
+
+// SessionTimeout is how long a browse session survives without activity.
+const SessionTimeout = 30 * time.Minute
+
+// UserSession is the key of the user's session. Walker may start volu
+// through a shell or other wrapper, so no process ID is stable across
+// selections; instead there is one session per user, started afresh
+// whenever the main menu opens.
+const UserSession = "session"
+
+// Session is the navigation state of one Walker session. Walker starts
+// volu afresh for every selection, so the stack is kept on disk between
+// invocations.
+type Session struct {
+	launcher.Navigator
+	Updated time.Time `json:"updated"`
+
+	path string
+}
+
+// SessionDir returns the directory holding Walker session files,
+// preferring $XDG_RUNTIME_DIR so sessions vanish on logout.
+func SessionDir() (string, error) {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "volu", "walker"), nil
+	}
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user cache directory: %w", err)
+	}
+	return filepath.Join(cacheDir, "volu", "walker"), nil
+}
+
+// LoadSession returns the session stored under key in dir. A missing,
+// unreadable or expired session yields a fresh one at the main menu.
+func LoadSession(dir, key string) *Session {
+	s := &Session{path: filepath.Join(dir, key+".json")}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return s
+	}
+	var stored Session
+	if err := json.Unmarshal(data, &stored); err != nil || time.Since(stored.Updated) > SessionTimeout {
+		return s
+	}
+
+	stored.path = s.path
+	return &stored
+}
+
+// Save writes the session back to disk and removes expired sessions.
+func (s *Session) Save() error {
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create session directory: %w", err)
+	}
+
+	s.Updated = time.Now()
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to marshal session: %w", err)
+	}
+	if err := os.WriteFile(s.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+
+	// Remove expired sessions, such as those older versions kept per
+	// Walker process
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err == nil && time.Since(info.ModTime()) > SessionTimeout {
+			os.Remove(filepath.Join(dir, entry.Name()))
+		}
+	}
+
+	return nil
+}
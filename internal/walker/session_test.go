@@ -0,0 +1,54 @@
+package walker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/riclib/volu/internal/launcher"
+)
+
+func TestSessionRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+
+	s := LoadSession(dir, "42")
+	if s.Current() != "" {
+		t.Fatalf("new session current = %q", s.Current())
+	}
+	s.Stack = []launcher.Frame{{View: "browse:music-library", Title: "Music Library"}}
+	if err := s.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	loaded := LoadSession(dir, "42")
+	if loaded.Current() != "browse:music-library" || loaded.Breadcrumbs() != "Volumio › Music Library" {
+		t.Errorf("loaded = %+v", loaded.Navigator)
+	}
+	if other := LoadSession(dir, "43"); other.Current() != "" {
+		t.Errorf("other session current = %q", other.Current())
+	}
+}
+
+func TestSessionExpiry(t *testing.T) {
+	dir := t.TempDir()
+
+	s := LoadSession(dir, "1")
+	s.Stack = []launcher.Frame{{View: "browse:playlists"}}
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Backdate the file as if Walker had been closed long ago
+	old := time.Now().Add(-2 * SessionTimeout)
+	stale := filepath.Join(dir, "1.json")
+	os.Chtimes(stale, old, old)
+
+	fresh := LoadSession(dir, "2")
+	if err := fresh.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("expired session not pruned: %v", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/riclib/volu/internal/volumio"
 )
@@ -149,6 +150,12 @@ func CreateOutput(state *volumio.PlayerState, baseURL string) Output {
 	}
 }
 
+// WithSleepTimer adds the sleep timer's remaining time to the tooltip
+func WithSleepTimer(output Output, remaining time.Duration) Output {
+	output.Tooltip += fmt.Sprintf("\nSleep: 💤 %s remaining", FormatTime(int(remaining.Seconds())))
+	return output
+}
+
 // CreateErrorOutput creates error output for Waybar
 func CreateErrorOutput(errorMsg string) Output {
 	return Output{
@@ -1,9 +1,13 @@
 package waybar
 
 import (
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/riclib/volu/internal/volumio"
+	"github.com/riclib/volu/internal/volumiotest"
 )
 
 func TestEscapeMarkup(t *testing.T) {
@@ -99,3 +103,30 @@ func containsSubstring(s, substr string) bool {
 	}
 	return false
 }
+
+func TestWithSleepTimer(t *testing.T) {
+	output := CreateOutput(&volumio.PlayerState{Status: "play", Title: "Song", Volume: 20}, "")
+	output = WithSleepTimer(output, 12*time.Minute+5*time.Second)
+
+	if !strings.HasSuffix(output.Tooltip, "\nSleep: 💤 12:05 remaining") {
+		t.Errorf("tooltip = %q", output.Tooltip)
+	}
+}
+
+// TestLiveStatus replays the player state behind the live test results
+// in STATUS.md.
+func TestLiveStatus(t *testing.T) {
+	client := volumiotest.ReplayClient(t, filepath.Join("..", "volumio", "testdata", "cassettes", "live-status.json"))
+	state, err := client.GetState()
+	if err != nil {
+		t.Fatalf("GetState() error = %v", err)
+	}
+
+	output := CreateOutput(state, "http://volumio.local:3000")
+	if want := "♫ Arno Elias - The Dance Of The Flames ⏸"; output.Text != want {
+		t.Errorf("text = %q, want %q", output.Text, want)
+	}
+	if output.Class != "volumio-pause" || !strings.Contains(output.Tooltip, "Volume: 🔊 90%") {
+		t.Errorf("output = %+v", output)
+	}
+}